@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/jidckii/kolor-keyboard/pkg/hid"
+	"github.com/jidckii/kolor-keyboard/pkg/render"
+	"github.com/spf13/cobra"
+)
+
+var calibrateConfigPath string
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Tune color calibration with a test card on the keyboard",
+	Long: `Show a test card on the keyboard and adjust the calibration interactively.
+
+Test card rows (top to bottom, repeated on taller keyboards):
+  1. white                   - white point
+  2. gray ramp               - gamma
+  3. red / green / blue      - channel balance
+  4. dark blue ramp          - dark tones
+  5. yellow / cyan / magenta - channel mixing
+  6. white
+
+Stock firmware has no per-key control, so only plain white is shown.
+
+Commands:
+  r+ r- g+ g- b+ b-   change channel gain by 0.05
+  y+ y-               change gamma by 0.1
+  k+ k-               change white point color temperature by 500K
+  reset               start over from no calibration
+  q                   finish and print the calibration block
+  x                   quit without printing`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := calibrateConfigPath
+		if path == "" {
+			path = findConfig()
+		}
+		if path == "" {
+			return fmt.Errorf("config file not found (use -c or run 'kolor-keyboard discover')")
+		}
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		return runCalibrate(cfg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(calibrateCmd)
+	calibrateCmd.Flags().StringVarP(&calibrateConfigPath, "config", "c", "", "path to config file")
+}
+
+func runCalibrate(cfg *config.Config) error {
+	device := hid.NewVIARGBDevice(cfg.Device.VendorID, cfg.Device.ProductID, cfg.Device.UsagePage, cfg.Device.Usage)
+	device.SetSerial(cfg.Device.Serial)
+	if err := device.Open(); err != nil {
+		return fmt.Errorf("failed to open device: %w", err)
+	}
+	defer device.Close()
+
+	if cfg.Brightness != nil {
+		if err := device.SetBrightness(*cfg.Brightness); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to set brightness: %v\n", err)
+		}
+	}
+
+	var show func(c *config.Calibration) error
+	switch cfg.Firmware {
+	case config.FirmwareVial:
+		if err := device.EnableVialDirectModeWithSpeed(cfg.GetSpeed()); err != nil {
+			return fmt.Errorf("failed to enable Vial direct mode: %w", err)
+		}
+		ledCount, err := device.GetLEDCount()
+		if err != nil {
+			return fmt.Errorf("failed to get LED count: %w", err)
+		}
+		card := render.TestCard(cfg, ledCount)
+		show = func(c *config.Calibration) error {
+			updates := make([]hid.LEDUpdate, len(card))
+			for i, color := range card {
+				color = c.Apply(color)
+				updates[i] = hid.LEDUpdate{Index: i, Color: hid.RGBToHSV(color.R, color.G, color.B)}
+			}
+			return device.SetLEDs(updates)
+		}
+	default:
+		if err := device.EnableSolidColor(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to enable solid color mode: %v\n", err)
+		}
+		show = func(c *config.Calibration) error {
+			color := c.Apply(config.RGBColor{R: 255, G: 255, B: 255})
+			return device.SetColorRGB(color.R, color.G, color.B)
+		}
+	}
+
+	return render.Calibrate(os.Stdin, os.Stdout, cfg.Device.Calibration, show)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateConfigPath string
+	migrateDryRun     bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage config files",
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade a config file to the current format version",
+	Long: fmt.Sprintf(`Upgrade a config file to the current format version (%d) in place.
+
+Older configs keep working: they are upgraded in memory on every load.
+This command writes the upgrade to disk, so the file states the version
+it was written for. Comments, blank lines and alignment are preserved.
+Files referenced by geometry and include are not changed.`, config.CurrentVersion),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := migrateConfigPath
+		if path == "" {
+			path = findConfig()
+		}
+		if path == "" {
+			return fmt.Errorf("config file not found (use -c)")
+		}
+
+		applied, data, err := config.MigrateFile(path, migrateDryRun)
+		if err != nil {
+			return err
+		}
+
+		if migrateDryRun {
+			if _, err := os.Stdout.Write(data); err != nil {
+				return fmt.Errorf("failed to write config: %w", err)
+			}
+			return nil
+		}
+
+		if len(applied) == 0 {
+			fmt.Printf("%s: already at version %d\n", path, config.CurrentVersion)
+			return nil
+		}
+		for _, m := range applied {
+			fmt.Printf("%s: version %d -> %d: %s\n", path, m.From, m.From+1, m.Description)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	configMigrateCmd.Flags().StringVarP(&migrateConfigPath, "config", "c", "", "path to config file")
+	configMigrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "print the upgraded config instead of writing it")
+}
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/jidckii/kolor-keyboard/pkg/app"
+	"github.com/jidckii/kolor-keyboard/pkg/config"
 	"github.com/jidckii/kolor-keyboard/pkg/discover"
 	"github.com/spf13/cobra"
 )
@@ -27,7 +29,8 @@ By default, generates config files in the current directory with names:
   - vial_draw.yaml    (for Vial firmware, per-key RGB mode)
 
 With --global flag, generates a single config.yaml in:
-  ~/.config/kolor-keyboard/keyboards/<vendor>/<model>/<variant>/config.yaml`,
+  $XDG_CONFIG_HOME/kolor-keyboard/keyboards/<vendor>/<model>/<variant>/config.yaml
+  ($XDG_CONFIG_HOME defaults to ~/.config)`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runDiscover()
 	},
@@ -35,7 +38,7 @@ With --global flag, generates a single config.yaml in:
 
 func init() {
 	rootCmd.AddCommand(discoverCmd)
-	discoverCmd.Flags().BoolVarP(&globalConfig, "global", "g", false, "save to global config directory (~/.config/kolor-keyboard/)")
+	discoverCmd.Flags().BoolVarP(&globalConfig, "global", "g", false, "save to global config directory ($XDG_CONFIG_HOME/kolor-keyboard/)")
 	discoverCmd.Flags().StringVarP(&outputDir, "output", "o", "", "output directory (default: current directory)")
 }
 
@@ -136,8 +139,7 @@ func runDiscover() error {
 	// Determine output path
 	var outDir string
 	if globalConfig {
-		home, _ := os.UserHomeDir()
-		outDir = filepath.Join(home, ".config/kolor-keyboard/keyboards", vendor, model, variant)
+		outDir = filepath.Join(discover.KeyboardsDir(), vendor, model, variant)
 	} else if outputDir != "" {
 		outDir = filepath.Join(outputDir, vendor, model, variant)
 	} else {
@@ -180,6 +182,7 @@ func saveGlobalConfig(outDir string, cfg *discover.DiscoveredConfig) error {
 	}
 
 	fmt.Printf("\n✓ Config saved to: %s\n", outPath)
+	checkSystemLayouts([]string{outPath})
 	fmt.Println("\nNext steps:")
 	fmt.Println("  1. Edit the config to customize colors for different layouts")
 	fmt.Println("  2. Run: kolor-keyboard run")
@@ -232,6 +235,7 @@ func saveLocalConfigs(outDir string, cfg *discover.DiscoveredConfig) error {
 	for _, f := range savedFiles {
 		fmt.Printf("  - %s\n", filepath.Base(f))
 	}
+	checkSystemLayouts(savedFiles)
 
 	fmt.Println("\nNext steps:")
 	fmt.Println("  1. Copy desired config to ~/.config/kolor-keyboard/config.yaml")
@@ -241,3 +245,44 @@ func saveLocalConfigs(outDir string, cfg *discover.DiscoveredConfig) error {
 
 	return nil
 }
+
+// checkSystemLayouts показывает раскладки системы, для которых в сохранённых конфигах
+// нет цвета или рисунка, или подходит только запись "*"
+func checkSystemLayouts(paths []string) {
+	layouts, watcher, err := app.SystemLayouts(config.WatcherAuto)
+	if err != nil || layouts == nil {
+		fmt.Println("\nSystem layouts are unknown, check layout colors after: kolor-keyboard run")
+		return
+	}
+
+	fmt.Printf("\nSystem layouts (%s):", watcher)
+	for _, l := range layouts {
+		fmt.Printf(" %s", l)
+	}
+	fmt.Println()
+
+	for _, path := range paths {
+		cfg, err := config.Load(path)
+		if err != nil {
+			fmt.Printf("  %s: %v\n", filepath.Base(path), err)
+			continue
+		}
+		what := "color"
+		if cfg.Mode == config.ModeDraw {
+			what = "drawing"
+		}
+
+		missing, wildcard := cfg.Uncovered(layouts)
+		if len(missing) == 0 && len(wildcard) == 0 {
+			fmt.Printf("  ✓ %s: all %d system layouts have a %s\n", filepath.Base(path), len(layouts), what)
+			continue
+		}
+		fmt.Printf("  %s:\n", filepath.Base(path))
+		for _, l := range missing {
+			fmt.Printf("    ✗ %s (%s) has no %s\n", l, l.Name, what)
+		}
+		for _, l := range wildcard {
+			fmt.Printf("    ! %s (%s) uses the \"*\" entry\n", l, l.Name)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jidckii/kolor-keyboard/pkg/app"
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/jidckii/kolor-keyboard/pkg/render"
+	"github.com/spf13/cobra"
+)
+
+// defaultLEDCount - число LED, если его нельзя вывести из конфига (Keychron V3)
+const defaultLEDCount = 87
+
+var (
+	previewConfigPath string
+	previewLayout     string
+	previewAll        bool
+	previewLEDs       int
+)
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Show layout colors in the terminal without a keyboard",
+	Long: `Render the colors the daemon would send for a layout as truecolor blocks
+in the terminal. Keys are placed by keyboard.geometry or keyboard.rows.
+
+Colors are shown as the LEDs would display them: calibration, the firmware
+HSV conversion and brightness are applied.
+
+Without --layout the first configured layout is shown.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := previewConfigPath
+		if path == "" {
+			path = findConfig()
+		}
+		if path == "" {
+			return fmt.Errorf("config file not found (use -c or run 'kolor-keyboard discover')")
+		}
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		layouts := cfg.Layouts()
+		if len(layouts) == 0 {
+			return fmt.Errorf("no layouts configured for mode %s", cfg.Mode)
+		}
+
+		ledCount := previewLEDs
+		if ledCount <= 0 {
+			ledCount = configLEDCount(cfg)
+		}
+
+		show := func(title string, colors []config.RGBColor) error {
+			fmt.Printf("%s:\n", title)
+			if colors == nil {
+				fmt.Println("  (nothing configured)")
+				return nil
+			}
+			return render.WriteANSI(os.Stdout, cfg, app.DisplayedColors(cfg, colors))
+		}
+
+		switch {
+		case previewAll:
+			for i, layout := range layouts {
+				if i > 0 {
+					fmt.Println()
+				}
+				if err := show(layout, app.EntryColors(cfg, i, ledCount)); err != nil {
+					return err
+				}
+			}
+		case previewLayout != "":
+			return show(previewLayout, app.LayoutColors(cfg, config.ParseLayout(previewLayout), ledCount))
+		default:
+			return show(layouts[0], app.EntryColors(cfg, 0, ledCount))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+	previewCmd.Flags().StringVarP(&previewConfigPath, "config", "c", "", "path to config file")
+	previewCmd.Flags().StringVarP(&previewLayout, "layout", "l", "", "layout to preview (e.g. us, ru, us(dvorak))")
+	previewCmd.Flags().BoolVarP(&previewAll, "all", "a", false, "preview every configured layout")
+	previewCmd.Flags().IntVar(&previewLEDs, "leds", 0, "number of LEDs (default: highest LED index in the config + 1)")
+	previewCmd.MarkFlagsMutuallyExclusive("all", "layout")
+}
+
+// configLEDCount выводит число LED из keyboard.rows и keyboard.geometry
+func configLEDCount(cfg *config.Config) int {
+	count := 0
+	for _, led := range cfg.GetAllLEDIndices() {
+		count = max(count, led+1)
+	}
+	for _, k := range cfg.Keyboard.Geometry {
+		count = max(count, k.LED+1)
+	}
+	if count == 0 {
+		return defaultLEDCount
+	}
+	return count
+}
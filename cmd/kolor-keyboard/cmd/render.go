@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jidckii/kolor-keyboard/pkg/app"
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/jidckii/kolor-keyboard/pkg/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderConfigPath string
+	renderLayout     string
+	renderFormat     string
+	renderOutput     string
+	renderLEDs       int
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Export a layout as an SVG or PNG image",
+	Long: `Draw the keyboard with the colors of a layout and save it as an image.
+
+Keys are placed by keyboard.geometry (labeled with their name) or as
+a uniform grid from keyboard.rows. Colors are shown as the LEDs would
+display them, like in 'preview'.
+
+The format is taken from --format or the output file extension.
+Without -o the image is written to stdout.
+
+Examples:
+  kolor-keyboard render -c config.yaml --layout us -o us.svg
+  kolor-keyboard render -c config.yaml --layout ru --format png > ru.png`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := renderConfigPath
+		if path == "" {
+			path = findConfig()
+		}
+		if path == "" {
+			return fmt.Errorf("config file not found (use -c or run 'kolor-keyboard discover')")
+		}
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		format := strings.ToLower(renderFormat)
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(renderOutput)), ".")
+		}
+		var write func(io.Writer, *config.Config, []config.RGBColor) error
+		switch format {
+		case "svg":
+			write = render.WriteSVG
+		case "png":
+			write = render.WritePNG
+		case "":
+			return fmt.Errorf("output format is not set (use --format svg|png)")
+		default:
+			return fmt.Errorf("unknown format %q (expected svg or png)", format)
+		}
+
+		ledCount := renderLEDs
+		if ledCount <= 0 {
+			ledCount = configLEDCount(cfg)
+		}
+
+		var colors []config.RGBColor
+		layout := renderLayout
+		if layout == "" {
+			layouts := cfg.Layouts()
+			if len(layouts) == 0 {
+				return fmt.Errorf("no layouts configured for mode %s", cfg.Mode)
+			}
+			layout = layouts[0]
+			colors = app.EntryColors(cfg, 0, ledCount)
+		} else {
+			colors = app.LayoutColors(cfg, config.ParseLayout(layout), ledCount)
+		}
+		if colors == nil {
+			return fmt.Errorf("nothing configured for layout %q", layout)
+		}
+		colors = app.DisplayedColors(cfg, colors)
+
+		if renderOutput == "" || renderOutput == "-" {
+			return write(os.Stdout, cfg, colors)
+		}
+
+		f, err := os.Create(renderOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		if err := write(f, cfg, colors); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write image: %w", err)
+		}
+		return f.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().StringVarP(&renderConfigPath, "config", "c", "", "path to config file")
+	renderCmd.Flags().StringVarP(&renderLayout, "layout", "l", "", "layout to render (default: first configured layout)")
+	renderCmd.Flags().StringVarP(&renderFormat, "format", "f", "", "image format: svg or png (default: from output extension)")
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "output file (default: stdout)")
+	renderCmd.Flags().IntVar(&renderLEDs, "leds", 0, "number of LEDs (default: highest LED index in the config + 1)")
+}
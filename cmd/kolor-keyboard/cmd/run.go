@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/jidckii/kolor-keyboard/pkg/app"
+	"github.com/jidckii/kolor-keyboard/pkg/discover"
 	"github.com/spf13/cobra"
 )
 
@@ -22,18 +23,28 @@ and updates the RGB backlight accordingly.
 The config file is searched in the following order:
   1. Path specified with -c/--config flag
   2. ./kolor-keyboard.yaml (current directory)
-  3. ~/.config/kolor-keyboard/config.yaml
-  4. Auto-generated configs in ~/.config/kolor-keyboard/keyboards/`,
+  3. $XDG_CONFIG_HOME/kolor-keyboard/config.yaml
+  4. Configs saved by 'discover --global' in $XDG_CONFIG_HOME/kolor-keyboard/keyboards/:
+     the one whose device matches a connected keyboard (vendor_id, product_id
+     and serial, if set)
+
+$XDG_CONFIG_HOME defaults to ~/.config.
+
+With a config from the keyboards directory the daemon follows the keyboard:
+when another keyboard is plugged in, it switches to that keyboard's config.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := configPath
+		auto := false
 		if cfg == "" {
-			cfg = findConfig()
+			cfg, auto = locateConfig()
 		}
 
 		if cfg == "" {
 			fmt.Fprintln(os.Stderr, "Config file not found. Searched locations:")
-			fmt.Fprintln(os.Stderr, "  - ./kolor-keyboard.yaml")
-			fmt.Fprintln(os.Stderr, "  - ~/.config/kolor-keyboard/config.yaml")
+			for _, p := range explicitConfigs() {
+				fmt.Fprintf(os.Stderr, "  - %s\n", p)
+			}
+			fmt.Fprintf(os.Stderr, "  - %s (no config matches a connected keyboard)\n", discover.KeyboardsDir())
 			fmt.Fprintln(os.Stderr, "")
 			fmt.Fprintln(os.Stderr, "Tip: Run 'kolor-keyboard discover' to detect your keyboard and generate a config")
 			return fmt.Errorf("config file not found")
@@ -48,6 +59,10 @@ The config file is searched in the following order:
 		}
 		defer application.Close()
 
+		if auto {
+			application.SetConfigSelector(discover.ConnectedConfig)
+		}
+
 		return application.Run()
 	},
 }
@@ -57,50 +72,38 @@ func init() {
 	runCmd.Flags().StringVarP(&configPath, "config", "c", "", "path to config file")
 }
 
-func findConfig() string {
-	home, _ := os.UserHomeDir()
-
-	// Explicit paths first
-	paths := []string{
+// explicitConfigs - конфиги, которые используются независимо от подключённой клавиатуры
+func explicitConfigs() []string {
+	return []string{
 		"kolor-keyboard.yaml",
-		filepath.Join(home, ".config/kolor-keyboard/config.yaml"),
+		filepath.Join(discover.ConfigDir(), "config.yaml"),
 	}
+}
 
-	for _, p := range paths {
-		if _, err := os.Stat(p); err == nil {
-			return p
-		}
+// findConfig ищет конфиг для команд, которым не нужна подключённая клавиатура
+// Если ни одна клавиатура не подходит, берётся первый конфиг из каталога keyboards
+func findConfig() string {
+	if path, _ := locateConfig(); path != "" {
+		return path
+	}
+	if paths := discover.FindConfigs(discover.KeyboardsDir()); len(paths) > 0 {
+		return paths[0]
 	}
+	return ""
+}
 
-	// Search in keyboards directory (auto-generated configs)
-	keyboardsDir := filepath.Join(home, ".config/kolor-keyboard/keyboards")
-	if entries, err := os.ReadDir(keyboardsDir); err == nil {
-		for _, vendor := range entries {
-			if !vendor.IsDir() {
-				continue
-			}
-			vendorPath := filepath.Join(keyboardsDir, vendor.Name())
-			if models, err := os.ReadDir(vendorPath); err == nil {
-				for _, model := range models {
-					if !model.IsDir() {
-						continue
-					}
-					modelPath := filepath.Join(vendorPath, model.Name())
-					if variants, err := os.ReadDir(modelPath); err == nil {
-						for _, variant := range variants {
-							if !variant.IsDir() {
-								continue
-							}
-							configPath := filepath.Join(modelPath, variant.Name(), "config.yaml")
-							if _, err := os.Stat(configPath); err == nil {
-								return configPath
-							}
-						}
-					}
-				}
-			}
+// locateConfig ищет конфиг для запуска демона
+// auto сообщает, что конфиг выбран по подключённой клавиатуре и может смениться вместе с ней
+func locateConfig() (path string, auto bool) {
+	for _, p := range explicitConfigs() {
+		if _, err := os.Stat(p); err == nil {
+			return p, false
 		}
 	}
 
-	return ""
+	path, err := discover.ConnectedConfig()
+	if err != nil {
+		GetLogger().Warn("failed to enumerate keyboards", "error", err)
+	}
+	return path, path != ""
 }
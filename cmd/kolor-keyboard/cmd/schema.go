@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the config format",
+	Long: `Print the JSON Schema of the config file format.
+
+Editors with yaml-language-server (VS Code YAML extension, Neovim, Helix)
+use it for autocompletion and linting. Add this line at the top of a config:
+
+  # yaml-language-server: $schema=` + config.SchemaID + `
+
+or save the schema locally:
+
+  kolor-keyboard schema > ~/.config/kolor-keyboard/config.schema.json`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := config.Schema()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stdout.Write(data); err != nil {
+			return fmt.Errorf("failed to write schema: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/jidckii/kolor-keyboard/pkg/hid"
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateConfigPath string
+	validateDevice     bool
+	validateStrict     bool
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a config file and report all problems",
+	Long: `Check a config file and print every error and warning with its line and column.
+
+Besides the checks done on startup it reports:
+  - duplicate layouts
+  - LEDs set more than once in one drawing
+  - LED indices outside keyboard.rows (or the device LED count with --device)
+  - entries listed after the "*" wildcard
+  - keyboard rows that no drawing uses
+
+Exits with a non-zero status if there are errors (or warnings with --strict),
+so it can be used in CI.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := validateConfigPath
+		if path == "" {
+			path = findConfig()
+		}
+		if path == "" {
+			return fmt.Errorf("config file not found (use -c)")
+		}
+
+		ledCount := 0
+		if validateDevice {
+			count, err := deviceLEDCount(path)
+			if err != nil {
+				return err
+			}
+			ledCount = count
+		}
+
+		diags, err := config.Check(path, ledCount)
+		if err != nil {
+			return err
+		}
+
+		errorsCount := 0
+		for _, d := range diags {
+			fmt.Printf("%s:%s\n", path, d)
+			if d.Severity == config.SeverityError {
+				errorsCount++
+			}
+		}
+
+		if len(diags) == 0 {
+			fmt.Printf("%s: ok\n", path)
+			return nil
+		}
+		fmt.Printf("\n%d error(s), %d warning(s)\n", errorsCount, len(diags)-errorsCount)
+
+		// Все проблемы - ошибки или предупреждения, с --strict подходит любая
+		if config.HasErrors(diags) || validateStrict {
+			return fmt.Errorf("config has problems")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().StringVarP(&validateConfigPath, "config", "c", "", "path to config file")
+	validateCmd.Flags().BoolVar(&validateDevice, "device", false, "check LED indices against the connected keyboard")
+	validateCmd.Flags().BoolVar(&validateStrict, "strict", false, "treat warnings as errors")
+}
+
+// deviceLEDCount открывает клавиатуру из конфига и запрашивает число LED
+// Конфиг здесь читается без проверки: validate должен работать и для сломанных конфигов
+func deviceLEDCount(path string) (int, error) {
+	cfg, err := config.LoadDevice(path)
+	if err != nil {
+		return 0, err
+	}
+
+	device := hid.NewVIARGBDevice(cfg.VendorID, cfg.ProductID, cfg.UsagePage, cfg.Usage)
+	device.SetSerial(cfg.Serial)
+	if err := device.Open(); err != nil {
+		return 0, fmt.Errorf("failed to open device: %w", err)
+	}
+	defer device.Close()
+
+	count, err := device.GetLEDCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get LED count: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,12 @@
+// Package keyboards - каталог конфигов клавиатур, встроенный в бинарник
+//
+// Структура: <vendor>/<model>/<variant>/keyboard.yaml - описание клавиатуры (device и keyboard),
+// рядом - готовые конфиги, которые подключают его через geometry
+package keyboards
+
+import "embed"
+
+// FS - файлы каталога, пути вида "keychron/v3/ansi_encoder/keyboard.yaml"
+//
+//go:embed */*/*/*.yaml
+var FS embed.FS
@@ -6,19 +6,37 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"reflect"
+	"slices"
 	"syscall"
+	"time"
 
 	"github.com/jidckii/kolor-keyboard/pkg/config"
-	"github.com/jidckii/kolor-keyboard/pkg/dbus"
+	"github.com/jidckii/kolor-keyboard/pkg/discover"
 	"github.com/jidckii/kolor-keyboard/pkg/hid"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/render"
 )
 
+// animationTick - период перерисовки анимированных рисунков
+const animationTick = 50 * time.Millisecond
+
 // App - главное приложение
 type App struct {
-	cfg     *config.Config
-	watcher *dbus.KDELayoutWatcher
-	device  *hid.VIARGBDevice
-	logger  *slog.Logger
+	cfg        *config.Config
+	configPath string
+	watcher    *chainWatcher
+	device     *hid.VIARGBDevice
+	logger     *slog.Logger
+
+	// layout - последняя применённая раскладка (для повторного применения после reload)
+	layout *config.ActiveLayout
+
+	// selectConfig выбирает конфиг для подключённой клавиатуры (nil - конфиг задан явно)
+	selectConfig func() (string, error)
+
+	// stopAnimation останавливает текущую анимацию и ждёт её завершения
+	stopAnimation func()
 }
 
 // New создаёт новое приложение
@@ -37,28 +55,35 @@ func New(configPath string, logger *slog.Logger) (*App, error) {
 
 	logger.Info("loaded config", "mode", cfg.Mode)
 
-	// Инициализация D-Bus watcher
-	watcher, err := dbus.NewKDELayoutWatcher()
+	// Инициализация watcher раскладки
+	watcher, err := newLayoutWatcher(cfg, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create layout watcher: %w", err)
 	}
 
-	// Инициализация HID устройства
-	device := hid.NewVIARGBDevice(
-		cfg.Device.VendorID,
-		cfg.Device.ProductID,
-		cfg.Device.UsagePage,
-		cfg.Device.Usage,
-	)
-
 	return &App{
-		cfg:     cfg,
-		watcher: watcher,
-		device:  device,
-		logger:  logger,
+		cfg:        cfg,
+		configPath: configPath,
+		watcher:    watcher,
+		device:     newDevice(&cfg.Device),
+		logger:     logger,
 	}, nil
 }
 
+// newDevice создаёт HID устройство по секции device конфига
+func newDevice(dev *config.DeviceConfig) *hid.VIARGBDevice {
+	device := hid.NewVIARGBDevice(dev.VendorID, dev.ProductID, dev.UsagePage, dev.Usage)
+	device.SetSerial(dev.Serial)
+	return device
+}
+
+// SetConfigSelector включает смену конфига вместе с клавиатурой
+// При подключении и отключении HID устройств selector выбирает конфиг заново;
+// "" - подходящей клавиатуры нет, текущий конфиг остаётся
+func (a *App) SetConfigSelector(selector func() (string, error)) {
+	a.selectConfig = selector
+}
+
 // Run запускает приложение
 func (a *App) Run() error {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -74,6 +99,11 @@ func (a *App) Run() error {
 		cancel()
 	}()
 
+	// SIGHUP - перечитать конфиг
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
 	// Открытие устройства
 	a.logger.Info("opening HID device",
 		"vid", fmt.Sprintf("%04X", a.cfg.Device.VendorID),
@@ -83,6 +113,7 @@ func (a *App) Run() error {
 		return fmt.Errorf("failed to open device: %w", err)
 	}
 	defer a.device.Close()
+	defer a.cancelAnimation()
 
 	// Инициализация режима в зависимости от конфигурации
 	if err := a.initializeMode(); err != nil {
@@ -94,11 +125,12 @@ func (a *App) Run() error {
 	if err != nil {
 		a.logger.Warn("failed to get initial layout", "error", err)
 	} else {
-		a.logger.Info("current layout", "layout", layout.Layout, "name", layout.Name)
-		if err := a.applyLayout(layout.Layout); err != nil {
+		a.logger.Info("current layout", "layout", layout.Layout, "variant", layout.Variant, "name", layout.Name)
+		if err := a.applyLayout(activeLayout(layout)); err != nil {
 			a.logger.Error("failed to apply initial layout", "error", err)
 		}
 	}
+	a.checkLayouts()
 
 	// Запуск отслеживания
 	events, err := a.watcher.Watch(ctx)
@@ -106,6 +138,28 @@ func (a *App) Run() error {
 		return fmt.Errorf("failed to start watching: %w", err)
 	}
 
+	// Отслеживание изменений конфига и файлов из его geometry и include
+	// После перезагрузки и смены клавиатуры наблюдение переключается на файлы нового конфига
+	stopWatch := func() {}
+	defer func() { stopWatch() }()
+	watchConfig := func() <-chan struct{} {
+		stopWatch()
+		var watchCtx context.Context
+		watchCtx, stopWatch = context.WithCancel(ctx)
+		changes, err := config.Watch(watchCtx, a.cfg.Files()...)
+		if err != nil {
+			a.logger.Warn("config hot reload disabled", "error", err)
+		}
+		return changes
+	}
+	configChanges := watchConfig()
+
+	// Переподключение клавиатуры, а для конфига, выбранного по клавиатуре, - её смена
+	deviceChanges, err := discover.WatchDevices(ctx)
+	if err != nil {
+		a.logger.Warn("keyboard reconnect and switching disabled", "error", err)
+	}
+
 	a.logger.Info("watching for layout changes...")
 
 	for {
@@ -113,22 +167,172 @@ func (a *App) Run() error {
 		case <-ctx.Done():
 			a.logger.Info("shutting down")
 			return nil
+		case <-hupCh:
+			a.logger.Info("received SIGHUP, reloading config")
+			if a.reload() {
+				configChanges = watchConfig()
+			}
+		case _, ok := <-configChanges:
+			if !ok {
+				configChanges = nil
+				continue
+			}
+			a.logger.Info("config file changed, reloading", "path", a.configPath)
+			if a.reload() {
+				configChanges = watchConfig()
+			}
+		case _, ok := <-deviceChanges:
+			if !ok {
+				deviceChanges = nil
+				continue
+			}
+			if a.switchConfig() {
+				configChanges = watchConfig()
+			}
 		case event, ok := <-events:
 			if !ok {
 				return nil
 			}
 			a.logger.Info("layout changed",
 				"layout", event.Layout,
+				"variant", event.Variant,
 				"name", event.Name,
-				"index", event.Index)
+				"index", event.Index,
+				"engine", event.Engine,
+				"ime", event.IME)
+			if event.Layout == "" && event.Name == "" {
+				a.logger.Warn("layout reported by index only, entries by layout or name will not match", "index", event.Index)
+			}
 
-			if err := a.applyLayout(event.Layout); err != nil {
+			if err := a.applyLayout(activeLayout(event)); err != nil {
 				a.logger.Error("failed to apply layout", "error", err)
 			}
 		}
 	}
 }
 
+// switchConfig выбирает конфиг для подключённой клавиатуры и переключается на него
+// Если конфиг тот же (или задан явно), клавиатура открывается заново: после
+// переподключения старый дескриптор hidraw не работает
+// Возвращает true, если конфиг сменился
+func (a *App) switchConfig() bool {
+	if a.selectConfig == nil {
+		a.reconnect()
+		return false
+	}
+	path, err := a.selectConfig()
+	if err != nil {
+		a.logger.Warn("failed to select config", "error", err)
+		return false
+	}
+	if path == "" {
+		return false
+	}
+	if path == a.configPath {
+		a.reconnect()
+		return false
+	}
+
+	a.logger.Info("keyboard changed, switching config", "from", a.configPath, "to", path)
+	previous := a.configPath
+	a.configPath = path
+	if !a.reload() {
+		a.configPath = previous
+		return false
+	}
+	return true
+}
+
+// reconnect открывает клавиатуру заново и восстанавливает режим и раскладку
+// Если клавиатура не найдена (отключена), она откроется при следующем подключении
+func (a *App) reconnect() {
+	a.cancelAnimation()
+	a.device.Close()
+	a.device = newDevice(&a.cfg.Device)
+	if err := a.device.Open(); err != nil {
+		a.logger.Debug("keyboard is not available", "error", err)
+		return
+	}
+	a.logger.Info("keyboard device reopened")
+
+	if err := a.initializeMode(); err != nil {
+		a.logger.Error("failed to initialize mode", "error", err)
+	}
+	if a.layout == nil {
+		return
+	}
+	if err := a.applyLayout(*a.layout); err != nil {
+		a.logger.Error("failed to apply layout", "error", err)
+	}
+}
+
+// reload перечитывает конфиг и применяет его к текущей раскладке
+// Если новый конфиг не загружается или не проходит проверку, остаётся старый (возвращает false)
+// Конфиг меняется только в горутине Run, анимация перед заменой останавливается,
+// поэтому никто не видит старый и новый конфиг одновременно
+func (a *App) reload() bool {
+	cfg, err := config.Load(a.configPath)
+	if err != nil {
+		a.logger.Error("failed to reload config, keeping previous one", "error", err)
+		return false
+	}
+
+	a.cancelAnimation()
+
+	if cfg.Device.VendorID != a.cfg.Device.VendorID || cfg.Device.ProductID != a.cfg.Device.ProductID ||
+		cfg.Device.UsagePage != a.cfg.Device.UsagePage || cfg.Device.Usage != a.cfg.Device.Usage ||
+		cfg.Device.Serial != a.cfg.Device.Serial {
+		device := newDevice(&cfg.Device)
+		if err := device.Open(); err != nil {
+			a.logger.Error("failed to open device from new config, keeping previous one", "error", err)
+			if a.layout != nil {
+				if err := a.applyLayout(*a.layout); err != nil {
+					a.logger.Error("failed to apply layout", "error", err)
+				}
+			}
+			return false
+		}
+		a.device.Close()
+		a.device = device
+	}
+	a.watcher.SetConfig(cfg)
+	if cfg.Watcher != a.cfg.Watcher || !reflect.DeepEqual(cfg.External, a.cfg.External) {
+		a.logger.Warn("watcher setting changed, restart to apply", "watcher", cfg.Watcher, "active", a.watcher.name())
+	}
+
+	a.cfg = cfg
+	a.logger.Info("config reloaded", "mode", cfg.Mode)
+
+	if err := a.initializeMode(); err != nil {
+		a.logger.Error("failed to initialize mode", "error", err)
+	}
+	a.checkLayouts()
+	if a.layout == nil {
+		return true
+	}
+	if err := a.applyLayout(*a.layout); err != nil {
+		a.logger.Error("failed to apply layout", "error", err)
+	}
+	return true
+}
+
+// checkLayouts предупреждает о раскладках системы, для которых в конфиге нет цвета или рисунка
+func (a *App) checkLayouts() {
+	layouts, err := a.watcher.Layouts()
+	if err != nil {
+		a.logger.Warn("failed to get layout list", "error", err)
+		return
+	}
+	missing, _ := a.cfg.Uncovered(activeLayouts(layouts))
+	for _, l := range missing {
+		msg := "no color configured for layout"
+		if a.cfg.Mode == config.ModeDraw {
+			msg = "no drawing configured for layout"
+		}
+		a.logger.Warn(msg, "layout", l, "name", l.Name, "index", l.Index)
+	}
+}
+
 // initializeMode инициализирует режим RGB
 func (a *App) initializeMode() error {
 	a.logger.Info("initializing", "firmware", a.cfg.Firmware, "mode", a.cfg.Mode)
@@ -170,8 +374,27 @@ func (a *App) initializeMode() error {
 	return nil
 }
 
+// activeLayout переводит событие D-Bus в раскладку для выбора записи конфига
+func activeLayout(e layout.LayoutEvent) config.ActiveLayout {
+	return config.ActiveLayout{Layout: e.Layout, Variant: e.Variant, Name: e.Name, Index: e.Index, IME: e.IME}
+}
+
+// activeLayouts переводит список раскладок источника в раскладки для выбора записи конфига
+// Номер в списке - индекс раскладки
+func activeLayouts(infos []layout.LayoutInfo) []config.ActiveLayout {
+	layouts := make([]config.ActiveLayout, len(infos))
+	for i, info := range infos {
+		layouts[i] = config.ActiveLayout{Layout: info.Code, Variant: info.Variant, Name: info.Name, Index: i}
+	}
+	return layouts
+}
+
 // applyLayout применяет цвет/флаг для указанной раскладки
-func (a *App) applyLayout(layout string) error {
+func (a *App) applyLayout(layout config.ActiveLayout) error {
+	// Анимация предыдущей раскладки не должна перерисовать новую
+	a.cancelAnimation()
+	a.layout = &layout
+
 	switch a.cfg.Mode {
 	case config.ModeMono:
 		return a.applyMonoLayout(layout)
@@ -183,12 +406,14 @@ func (a *App) applyLayout(layout string) error {
 }
 
 // applyMonoLayout применяет глобальный цвет для раскладки
-func (a *App) applyMonoLayout(layout string) error {
-	color := a.cfg.GetColorForLayout(layout)
+func (a *App) applyMonoLayout(layout config.ActiveLayout) error {
+	color := a.cfg.ColorFor(layout)
 	if color == nil {
 		a.logger.Warn("no color configured for layout", "layout", layout)
 		return nil
 	}
+	calibrated := a.cfg.Device.Calibration.Apply(*color)
+	color = &calibrated
 
 	switch a.cfg.Firmware {
 	case config.FirmwareStock:
@@ -242,8 +467,8 @@ func (a *App) applyMonoVial(color *config.RGBColor) error {
 }
 
 // applyFlagLayout применяет флаг (per-key RGB) для раскладки
-func (a *App) applyFlagLayout(layout string) error {
-	flag := a.cfg.GetFlagForLayout(layout)
+func (a *App) applyFlagLayout(layout config.ActiveLayout) error {
+	flag := a.cfg.FlagFor(layout)
 	if flag == nil {
 		a.logger.Warn("no flag configured for layout", "layout", layout)
 		return nil
@@ -262,53 +487,85 @@ func (a *App) applyFlagLayout(layout string) error {
 		ledCount = 87 // fallback для Keychron V3
 	}
 
-	// Создаём массив для ВСЕХ LED, инициализируем чёрным (выключено)
+	// Рассчитываем цвета для ВСЕХ LED (не затронутые рисунком - чёрные)
 	// Это гарантирует что все LED будут обновлены и в правильном порядке
-	ledColors := make([]hid.HSVColor, ledCount)
-	for i := range ledColors {
-		ledColors[i] = hid.HSVColor{H: 0, S: 0, V: 0} // чёрный
-	}
+	// Анимация начинается с первого кадра: бегущая строка въезжает справа
+	ledColors := render.FlagAt(a.cfg, flag, ledCount, 0)
+	updates := a.ledUpdates(ledColors)
 
-	// Заполняем цветами из конфига флага
-	for _, stripe := range flag.Stripes {
-		hsvColor := hid.RGBToHSV(stripe.Color.R, stripe.Color.G, stripe.Color.B)
+	a.logger.Debug("applying flag", "layout", layout, "led_count", len(updates))
 
-		// Если указаны конкретные LED - используем их
-		if len(stripe.LEDs) > 0 {
-			for _, ledIdx := range stripe.LEDs {
-				if ledIdx >= 0 && ledIdx < ledCount {
-					ledColors[ledIdx] = hsvColor
-				}
-			}
-		} else {
-			// Иначе используем ряды
-			for _, rowIdx := range stripe.Rows {
-				ledIndices := a.cfg.GetLEDsForRow(rowIdx)
-				for _, ledIdx := range ledIndices {
-					if ledIdx >= 0 && ledIdx < ledCount {
-						ledColors[ledIdx] = hsvColor
-					}
-				}
-			}
-		}
+	if err := a.device.SetLEDs(updates); err != nil {
+		return fmt.Errorf("failed to set LEDs: %w", err)
 	}
 
-	// Формируем обновления в порядке индексов (0, 1, 2, ..., ledCount-1)
-	updates := make([]hid.LEDUpdate, ledCount)
-	for i := 0; i < ledCount; i++ {
+	if render.Animated(flag) {
+		a.startAnimation(flag, ledCount, ledColors)
+	}
+
+	return nil
+}
+
+// ledUpdates формирует обновления в порядке индексов (0, 1, 2, ..., ledCount-1)
+// Перед конвертацией в HSV к цветам применяется калибровка устройства
+func (a *App) ledUpdates(ledColors []config.RGBColor) []hid.LEDUpdate {
+	updates := make([]hid.LEDUpdate, len(ledColors))
+	for i, c := range ledColors {
+		c = a.cfg.Device.Calibration.Apply(c)
 		updates[i] = hid.LEDUpdate{
 			Index: i,
-			Color: ledColors[i],
+			Color: hid.RGBToHSV(c.R, c.G, c.B),
 		}
 	}
+	return updates
+}
 
-	a.logger.Debug("applying flag", "layout", layout, "led_count", len(updates))
-
-	if err := a.device.SetLEDs(updates); err != nil {
-		return fmt.Errorf("failed to set LEDs: %w", err)
+// startAnimation запускает перерисовку анимированного рисунка (GIF, бегущая строка)
+// Анимация работает до следующей смены раскладки или остановки приложения
+func (a *App) startAnimation(flag *config.FlagMapping, ledCount int, first []config.RGBColor) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	a.stopAnimation = func() {
+		cancel()
+		<-done
 	}
 
-	return nil
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(animationTick)
+		defer ticker.Stop()
+
+		start := time.Now()
+		last := first
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				ledColors := render.FlagAt(a.cfg, flag, ledCount, now.Sub(start))
+				// Не отправляем кадр, если он не изменился
+				if slices.Equal(ledColors, last) {
+					continue
+				}
+				if err := a.device.SetLEDs(a.ledUpdates(ledColors)); err != nil {
+					a.logger.Warn("failed to draw animation frame", "error", err)
+					continue
+				}
+				last = ledColors
+			}
+		}
+	}()
+
+	a.logger.Debug("animation started", "layout", flag.LayoutSelector)
+}
+
+// cancelAnimation останавливает текущую анимацию (если есть)
+func (a *App) cancelAnimation() {
+	if a.stopAnimation != nil {
+		a.stopAnimation()
+		a.stopAnimation = nil
+	}
 }
 
 // Close закрывает все ресурсы
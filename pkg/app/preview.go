@@ -0,0 +1,73 @@
+package app
+
+import (
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/jidckii/kolor-keyboard/pkg/hid"
+	"github.com/jidckii/kolor-keyboard/pkg/render"
+)
+
+// LayoutColors рассчитывает цвета LED, которые демон отправит для раскладки
+// В mono режиме все LED одного цвета, в draw - цвета рисунка (статичный кадр, см. render.Still)
+// Возвращает nil, если для раскладки ничего не настроено
+func LayoutColors(cfg *config.Config, layout config.ActiveLayout, ledCount int) []config.RGBColor {
+	switch cfg.Mode {
+	case config.ModeMono:
+		return monoColors(cfg.ColorFor(layout), ledCount)
+	case config.ModeDraw:
+		flag := cfg.FlagFor(layout)
+		if flag == nil {
+			return nil
+		}
+		return render.Flag(cfg, flag, ledCount)
+	default:
+		return nil
+	}
+}
+
+// EntryColors рассчитывает цвета LED для i-й записи colors или draw (порядок Config.Layouts)
+// Нужна для записей, которые выбираются не по коду раскладки (name, index)
+func EntryColors(cfg *config.Config, i int, ledCount int) []config.RGBColor {
+	switch {
+	case cfg.Mode == config.ModeMono && i < len(cfg.Colors):
+		return monoColors(&cfg.Colors[i].Color, ledCount)
+	case cfg.Mode == config.ModeDraw && i < len(cfg.Drawings):
+		return render.Flag(cfg, &cfg.Drawings[i], ledCount)
+	default:
+		return nil
+	}
+}
+
+// monoColors заполняет все LED одним цветом
+func monoColors(color *config.RGBColor, ledCount int) []config.RGBColor {
+	if color == nil {
+		return nil
+	}
+	leds := make([]config.RGBColor, ledCount)
+	for i := range leds {
+		leds[i] = *color
+	}
+	return leds
+}
+
+// DisplayedColors возвращает цвета, которые покажут светодиоды
+// Повторяет путь до прошивки: калибровка, подбор HSV, обратная конвертация QMK и яркость
+// Stock прошивка берёт из HSV только оттенок и насыщенность, V задаётся яркостью
+func DisplayedColors(cfg *config.Config, colors []config.RGBColor) []config.RGBColor {
+	brightness := uint8(255)
+	if cfg.Brightness != nil {
+		brightness = *cfg.Brightness
+	}
+
+	shown := make([]config.RGBColor, len(colors))
+	for i, c := range colors {
+		c = cfg.Device.Calibration.Apply(c)
+		if cfg.Firmware == config.FirmwareStock {
+			hsv := hid.RGBToHSV(c.R, c.G, c.B)
+			hsv.V = brightness
+			shown[i].R, shown[i].G, shown[i].B = hid.HSVToRGB(hsv)
+			continue
+		}
+		shown[i].R, shown[i].G, shown[i].B = hid.DisplayedRGB(c.R, c.G, c.B, brightness)
+	}
+	return shown
+}
@@ -0,0 +1,405 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/jidckii/kolor-keyboard/pkg/dbus"
+	"github.com/jidckii/kolor-keyboard/pkg/external"
+	"github.com/jidckii/kolor-keyboard/pkg/hyprland"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/sway"
+	"github.com/jidckii/kolor-keyboard/pkg/x11"
+)
+
+// Повтор подключения, когда ни один источник раскладки не работает
+const (
+	retryDelay    = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// environment - признаки сессии для автоматического выбора источника раскладки
+type environment struct {
+	desktops []string        // XDG_CURRENT_DESKTOP ("ubuntu:GNOME" -> ubuntu, GNOME)
+	session  string          // XDG_SESSION_TYPE: x11, wayland
+	im       string          // метод ввода: "@im=fcitx" в XMODIFIERS или GTK_IM_MODULE=fcitx -> fcitx
+	display  bool            // задан $DISPLAY
+	sway     bool            // сокет $SWAYSOCK существует
+	hyprland bool            // сокеты Hyprland найдены
+	busNames map[string]bool // имена на сессионной шине D-Bus
+}
+
+// probeEnvironment собирает признаки сессии из переменных окружения, сокетов и D-Bus
+func probeEnvironment() *environment {
+	env := &environment{
+		desktops: strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":"),
+		session:  os.Getenv("XDG_SESSION_TYPE"),
+		display:  os.Getenv("DISPLAY") != "",
+	}
+
+	if _, im, ok := strings.Cut(os.Getenv("XMODIFIERS"), "@im="); ok {
+		env.im = strings.ToLower(im)
+	}
+	for _, name := range []string{"GTK_IM_MODULE", "QT_IM_MODULE"} {
+		if env.im == "" {
+			env.im = strings.ToLower(os.Getenv(name))
+		}
+	}
+
+	if socket := os.Getenv("SWAYSOCK"); socket != "" {
+		_, err := os.Stat(socket)
+		env.sway = err == nil
+	}
+	_, err := hyprland.SocketDir()
+	env.hyprland = err == nil
+
+	// Без сессионной шины остаются только переменные окружения
+	env.busNames, _ = dbus.SessionBusNames()
+	return env
+}
+
+// desktop сообщает, что сессия - указанный рабочий стол (без учёта регистра)
+func (e *environment) desktop(name string) bool {
+	for _, d := range e.desktops {
+		if strings.EqualFold(d, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// backend - источник раскладки
+type backend struct {
+	name   config.Watcher
+	detect func(env *environment) bool // nil - источник выбирается только явно
+	open   func(cfg *config.Config) (layout.LayoutWatcher, error)
+}
+
+// backends - источники в порядке автоматического выбора
+// Методы ввода первые: они переключают раскладку поверх рабочего стола.
+// В GNOME движки IBus переключаются как источники ввода, поэтому IBus - только вне GNOME
+var backends = []backend{
+	{
+		name:   config.WatcherFcitx5,
+		detect: func(e *environment) bool { return e.im == "fcitx" || e.busNames[dbus.Fcitx5Service] },
+		open:   opener(dbus.NewFcitx5Watcher),
+	},
+	{
+		name:   config.WatcherIBus,
+		detect: func(e *environment) bool { return e.im == "ibus" && !e.desktop("GNOME") },
+		open:   opener(dbus.NewIBusWatcher),
+	},
+	{
+		name:   config.WatcherSway,
+		detect: func(e *environment) bool { return e.sway },
+		// В Sway раскладка берётся с клавиатуры из device
+		open: func(cfg *config.Config) (layout.LayoutWatcher, error) {
+			return opener(func() (*sway.Watcher, error) {
+				return sway.NewWatcher(sway.InputID(cfg.Device.VendorID, cfg.Device.ProductID))
+			})(cfg)
+		},
+	},
+	{
+		name:   config.WatcherHyprland,
+		detect: func(e *environment) bool { return e.hyprland },
+		open: opener(func() (*hyprland.Watcher, error) {
+			return hyprland.NewWatcher("")
+		}),
+	},
+	{
+		name:   config.WatcherGNOME,
+		detect: func(e *environment) bool { return e.desktop("GNOME") || e.busNames[dbus.GNOMEService] },
+		open:   opener(dbus.NewGNOMELayoutWatcher),
+	},
+	{
+		name:   config.WatcherKDE,
+		detect: func(e *environment) bool { return e.desktop("KDE") || e.busNames[dbus.KDEService] },
+		open:   opener(dbus.NewKDELayoutWatcher),
+	},
+	{
+		name:   config.WatcherX11,
+		detect: func(e *environment) bool { return e.display && e.session != "wayland" },
+		open:   opener(x11.NewWatcher),
+	},
+	{
+		// Команда или канал из секции external, только при watcher: external
+		name: config.WatcherExternal,
+		open: func(cfg *config.Config) (layout.LayoutWatcher, error) {
+			switch {
+			case cfg.External == nil:
+				return nil, fmt.Errorf("external source is not configured")
+			case len(cfg.External.Command) > 0:
+				return opener(func() (*external.Watcher, error) {
+					return external.NewCommandWatcher(cfg.External.Command)
+				})(cfg)
+			default:
+				return opener(func() (*external.Watcher, error) {
+					return external.NewPipeWatcher(cfg.External.Pipe)
+				})(cfg)
+			}
+		},
+	},
+}
+
+// opener приводит конструктор watcher'а к общему виду
+// (nil-указатель конкретного типа не должен стать не-nil интерфейсом)
+func opener[W layout.LayoutWatcher](newWatcher func() (W, error)) func(*config.Config) (layout.LayoutWatcher, error) {
+	return func(*config.Config) (layout.LayoutWatcher, error) {
+		w, err := newWatcher()
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+}
+
+// selectBackends возвращает цепочку источников для настройки watcher
+// auto - найденные в окружении по порядку приоритета, а если не найдено ничего - все,
+// кроме выбираемых только явно
+func selectBackends(kind config.Watcher, env *environment, all []backend) []backend {
+	var chain, auto []backend
+	for _, b := range all {
+		if b.detect != nil {
+			auto = append(auto, b)
+		}
+		if kind == b.name || kind == config.WatcherAuto && b.detect != nil && b.detect(env) {
+			chain = append(chain, b)
+		}
+	}
+	if len(chain) == 0 && kind == config.WatcherAuto {
+		return auto
+	}
+	return chain
+}
+
+// chainWatcher - LayoutWatcher поверх цепочки источников
+// Работает первый источник, который отвечает. Если он перестаёт работать
+// (например, перезапуск KWin), chainWatcher переходит к следующему по кругу
+type chainWatcher struct {
+	backends []backend
+	logger   *slog.Logger
+	retry    time.Duration // первая пауза, когда не работает ни один источник
+	cancel   context.CancelFunc
+
+	mu      sync.Mutex
+	cfg     *config.Config
+	current layout.LayoutWatcher // nil - ни один источник не работает
+	active  int                  // номер текущего источника в backends
+}
+
+// newLayoutWatcher создаёт watcher по настройке watcher конфига
+func newLayoutWatcher(cfg *config.Config, logger *slog.Logger) (*chainWatcher, error) {
+	var env *environment
+	if cfg.Watcher == config.WatcherAuto {
+		env = probeEnvironment()
+	}
+	chain := selectBackends(cfg.Watcher, env, backends)
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("unknown watcher: %s", cfg.Watcher)
+	}
+
+	names := make([]string, len(chain))
+	for i, b := range chain {
+		names[i] = string(b.name)
+	}
+	logger.Debug("layout watcher candidates", "watcher", cfg.Watcher, "chain", strings.Join(names, ", "))
+
+	return newChainWatcher(chain, cfg, logger)
+}
+
+// SystemLayouts возвращает раскладки системы из источника по настройке watcher
+// и имя источника, который ответил. nil без ошибки - источник не знает списка
+func SystemLayouts(kind config.Watcher) ([]config.ActiveLayout, config.Watcher, error) {
+	cfg := &config.Config{Watcher: kind}
+	w, err := newLayoutWatcher(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		return nil, "", err
+	}
+	defer w.Close()
+
+	layouts, err := w.Layouts()
+	if err != nil || layouts == nil {
+		return nil, w.name(), err
+	}
+	return activeLayouts(layouts), w.name(), nil
+}
+
+func newChainWatcher(chain []backend, cfg *config.Config, logger *slog.Logger) (*chainWatcher, error) {
+	w := &chainWatcher{backends: chain, logger: logger, retry: retryDelay, cfg: cfg}
+	if err := w.open(0); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open подключается к первому работающему источнику, начиная с номера start (по кругу)
+// Вызывается под mu (или до запуска Watch)
+func (w *chainWatcher) open(start int) error {
+	var errs []error
+	for i := range w.backends {
+		n := (start + i) % len(w.backends)
+		b := w.backends[n]
+
+		watcher, err := b.open(w.cfg)
+		if err == nil {
+			// Подключение к шине ещё не значит, что источник работает:
+			// KDE отвечает, только пока запущен KWin
+			if _, err = watcher.GetCurrentLayout(); err != nil {
+				watcher.Close()
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+			continue
+		}
+
+		w.current, w.active = watcher, n
+		w.logger.Info("layout watcher active", "backend", b.name)
+		return nil
+	}
+	return fmt.Errorf("no layout watcher available: %w", errors.Join(errs...))
+}
+
+// name возвращает имя текущего источника
+func (w *chainWatcher) name() config.Watcher {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.backends[w.active].name
+}
+
+// SetConfig сообщает источнику новый конфиг (после перезагрузки конфига):
+// Sway берёт раскладку с клавиатуры из device, следующие подключения используют новый конфиг
+func (w *chainWatcher) SetConfig(cfg *config.Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cfg = cfg
+	if s, ok := w.current.(*sway.Watcher); ok {
+		s.SetInput(sway.InputID(cfg.Device.VendorID, cfg.Device.ProductID))
+	}
+}
+
+// GetCurrentLayout возвращает раскладку из текущего источника
+func (w *chainWatcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	w.mu.Lock()
+	current := w.current
+	w.mu.Unlock()
+
+	if current == nil {
+		return layout.LayoutEvent{}, fmt.Errorf("no layout watcher available")
+	}
+	return current.GetCurrentLayout()
+}
+
+// Layouts возвращает список раскладок текущего источника
+func (w *chainWatcher) Layouts() ([]layout.LayoutInfo, error) {
+	w.mu.Lock()
+	current := w.current
+	w.mu.Unlock()
+
+	if current == nil {
+		return nil, fmt.Errorf("no layout watcher available")
+	}
+	return current.Layouts()
+}
+
+// Watch отслеживает раскладку через текущий источник, а когда он перестаёт работать - через следующий
+func (w *chainWatcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	w.mu.Lock()
+	events, err := w.current.Watch(ctx)
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	out := make(chan layout.LayoutEvent, 10)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					if events = w.fallback(ctx, out); events == nil {
+						return
+					}
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// fallback переключается на следующий работающий источник и возвращает его события
+// Пока не работает ни один, повторяет попытки с растущей паузой; nil - ctx отменён
+func (w *chainWatcher) fallback(ctx context.Context, out chan<- layout.LayoutEvent) <-chan layout.LayoutEvent {
+	w.logger.Warn("layout watcher stopped", "backend", w.name())
+
+	delay := w.retry
+	for {
+		w.mu.Lock()
+		if w.current != nil {
+			w.current.Close()
+			w.current = nil
+		}
+		err := w.open(w.active + 1)
+		var events <-chan layout.LayoutEvent
+		if err == nil {
+			if events, err = w.current.Watch(ctx); err != nil {
+				w.current.Close()
+				w.current = nil
+			}
+		}
+		w.mu.Unlock()
+
+		if err == nil {
+			// Раскладка могла смениться, пока источник не работал
+			if event, err := w.GetCurrentLayout(); err == nil {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			return events
+		}
+
+		w.logger.Warn("no layout watcher available, retrying", "error", err, "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// Close останавливает отслеживание и закрывает текущий источник
+func (w *chainWatcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current == nil {
+		return nil
+	}
+	err := w.current.Close()
+	w.current = nil
+	return err
+}
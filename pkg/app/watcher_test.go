@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+)
+
+func TestSelectBackends(t *testing.T) {
+	tests := []struct {
+		name string
+		kind config.Watcher
+		env  environment
+		want []config.Watcher
+	}{
+		{
+			name: "kde on wayland",
+			kind: config.WatcherAuto,
+			env:  environment{desktops: []string{"KDE"}, session: "wayland", display: true},
+			want: []config.Watcher{config.WatcherKDE},
+		},
+		{
+			name: "kde on x11 falls back to xkb",
+			kind: config.WatcherAuto,
+			env:  environment{desktops: []string{"KDE"}, session: "x11", display: true},
+			want: []config.Watcher{config.WatcherKDE, config.WatcherX11},
+		},
+		{
+			name: "ubuntu gnome with ibus",
+			kind: config.WatcherAuto,
+			env:  environment{desktops: []string{"ubuntu", "GNOME"}, session: "wayland", im: "ibus"},
+			want: []config.Watcher{config.WatcherGNOME},
+		},
+		{
+			name: "fcitx5 found on the bus",
+			kind: config.WatcherAuto,
+			env:  environment{desktops: []string{"KDE"}, session: "wayland", busNames: map[string]bool{"org.fcitx.Fcitx5": true}},
+			want: []config.Watcher{config.WatcherFcitx5, config.WatcherKDE},
+		},
+		{
+			name: "sway",
+			kind: config.WatcherAuto,
+			env:  environment{desktops: []string{"sway"}, session: "wayland", sway: true, display: true},
+			want: []config.Watcher{config.WatcherSway},
+		},
+		{
+			name: "xfce",
+			kind: config.WatcherAuto,
+			env:  environment{desktops: []string{"XFCE"}, session: "x11", display: true},
+			want: []config.Watcher{config.WatcherX11},
+		},
+		{
+			name: "plasma found on the bus without XDG_CURRENT_DESKTOP",
+			kind: config.WatcherAuto,
+			env:  environment{busNames: map[string]bool{"org.kde.keyboard": true}},
+			want: []config.Watcher{config.WatcherKDE},
+		},
+		{
+			name: "nothing detected tries everything",
+			kind: config.WatcherAuto,
+			want: nil, // все backends по порядку, кроме external
+		},
+		{
+			name: "external only when explicit",
+			kind: config.WatcherExternal,
+			env:  environment{desktops: []string{"KDE"}},
+			want: []config.Watcher{config.WatcherExternal},
+		},
+		{
+			name: "explicit",
+			kind: config.WatcherGNOME,
+			env:  environment{desktops: []string{"KDE"}},
+			want: []config.Watcher{config.WatcherGNOME},
+		},
+	}
+
+	var order []config.Watcher
+	for _, b := range backends {
+		if b.name != config.WatcherExternal {
+			order = append(order, b.name)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []config.Watcher
+			for _, b := range selectBackends(tt.kind, &tt.env, backends) {
+				got = append(got, b.name)
+			}
+			want := tt.want
+			if want == nil {
+				want = order
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("selectBackends() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// fakeWatcher - источник раскладки для тестов
+type fakeWatcher struct {
+	current layout.LayoutEvent
+	err     error // ошибка GetCurrentLayout
+	layouts []layout.LayoutInfo
+	events  chan layout.LayoutEvent
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newFakeWatcher(current layout.LayoutEvent, err error) *fakeWatcher {
+	return &fakeWatcher{current: current, err: err, events: make(chan layout.LayoutEvent)}
+}
+
+func (f *fakeWatcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	return f.events, nil
+}
+
+func (f *fakeWatcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	return f.current, f.err
+}
+
+func (f *fakeWatcher) Layouts() ([]layout.LayoutInfo, error) {
+	return f.layouts, nil
+}
+
+func (f *fakeWatcher) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeWatcher) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// fakeBackend возвращает источник, который при каждом подключении отдаёт следующий watcher из списка
+func fakeBackend(name config.Watcher, watchers ...*fakeWatcher) backend {
+	var mu sync.Mutex
+	return backend{
+		name: name,
+		open: func(*config.Config) (layout.LayoutWatcher, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(watchers) == 0 {
+				return nil, fmt.Errorf("%s is not running", name)
+			}
+			w := watchers[0]
+			watchers = watchers[1:]
+			return w, nil
+		},
+	}
+}
+
+func TestChainWatcher(t *testing.T) {
+	us := layout.LayoutEvent{Layout: "us"}
+	ru := layout.LayoutEvent{Index: 1, Layout: "ru"}
+	de := layout.LayoutEvent{Index: 2, Layout: "de"}
+
+	broken := newFakeWatcher(layout.LayoutEvent{}, fmt.Errorf("KWin is not running"))
+	kde := newFakeWatcher(us, nil)
+	x11 := newFakeWatcher(ru, nil)
+	kdeAgain := newFakeWatcher(de, nil)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	chain := []backend{
+		fakeBackend(config.WatcherKDE, broken, kde, kdeAgain),
+		fakeBackend(config.WatcherX11, x11),
+	}
+	w, err := newChainWatcher(chain, &config.Config{}, logger)
+	if err != nil {
+		t.Fatalf("newChainWatcher() error = %v", err)
+	}
+	w.retry = time.Millisecond
+	defer w.Close()
+
+	// Источник, который не отвечает, пропускается
+	if !broken.isClosed() || w.name() != config.WatcherX11 {
+		t.Fatalf("active backend = %s, want x11 after kde failed", w.name())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	expect := func(want layout.LayoutEvent) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+
+	x11.events <- ru
+	expect(ru)
+
+	// x11 перестал работать: по кругу следующий - kde, он сообщает текущую раскладку
+	close(x11.events)
+	expect(us)
+	if !x11.isClosed() || w.name() != config.WatcherKDE {
+		t.Errorf("active backend = %s, want kde", w.name())
+	}
+
+	kde.events <- de
+	expect(de)
+
+	// x11 больше не запускается, следующий по кругу - перезапущенный kde
+	close(kde.events)
+	expect(de)
+	if got, err := w.GetCurrentLayout(); err != nil || got != de {
+		t.Errorf("GetCurrentLayout() = %+v, %v, want %+v", got, err, de)
+	}
+}
+
+func TestChainWatcherNoBackend(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := newChainWatcher([]backend{fakeBackend(config.WatcherKDE), fakeBackend(config.WatcherGNOME)},
+		&config.Config{}, logger)
+	if err == nil {
+		t.Fatal("newChainWatcher() succeeded without working backends")
+	}
+	for _, name := range []string{"kde", "gnome"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"math"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Calibration - коррекция цветов под светодиоды конкретной клавиатуры
+// Применяется к RGB перед конвертацией в HSV для прошивки
+type Calibration struct {
+	// Gamma - гамма-коррекция: <1 поднимает тёмные тона, >1 затемняет (0 = 1.0, без изменений)
+	Gamma float64 `yaml:"gamma,omitempty"`
+	// Gain - множители каналов, компенсируют разную яркость кристаллов
+	Gain *ChannelGain `yaml:"gain,omitempty"`
+	// WhitePoint - цвет, который светодиоды должны показывать вместо чистого белого
+	// Например {kelvin: 5500}, если белый уходит в синеву
+	WhitePoint *RGBColor `yaml:"white_point,omitempty"`
+}
+
+// ChannelGain - множители каналов R, G, B (по умолчанию 1.0)
+type ChannelGain struct {
+	R float64 `yaml:"r"`
+	G float64 `yaml:"g"`
+	B float64 `yaml:"b"`
+}
+
+// channelGainRaw - псевдоним без UnmarshalYAML для декодирования полей
+type channelGainRaw ChannelGain
+
+// UnmarshalYAML заполняет неуказанные каналы значением 1.0
+func (g *ChannelGain) UnmarshalYAML(value *yaml.Node) error {
+	raw := channelGainRaw{R: 1, G: 1, B: 1}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*g = ChannelGain(raw)
+	return nil
+}
+
+// Validate проверяет значения калибровки
+func (c *Calibration) Validate() error {
+	if c.Gamma < 0 || c.Gamma > 5 {
+		return fmt.Errorf("calibration: gamma must be between 0 and 5, got %v", c.Gamma)
+	}
+	if c.Gain != nil {
+		for i, v := range []float64{c.Gain.R, c.Gain.G, c.Gain.B} {
+			if v < 0 || v > 2 {
+				return fmt.Errorf("calibration: gain.%c must be between 0 and 2, got %v", "rgb"[i], v)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply применяет калибровку к цвету
+// Порядок: гамма, затем множители каналов и точка белого
+// nil калибровка возвращает цвет без изменений
+func (c *Calibration) Apply(color RGBColor) RGBColor {
+	if c == nil {
+		return color
+	}
+
+	gamma := c.Gamma
+	if gamma == 0 {
+		gamma = 1
+	}
+
+	gain := ChannelGain{R: 1, G: 1, B: 1}
+	if c.Gain != nil {
+		gain = *c.Gain
+	}
+	if c.WhitePoint != nil {
+		gain.R *= float64(c.WhitePoint.R) / 255
+		gain.G *= float64(c.WhitePoint.G) / 255
+		gain.B *= float64(c.WhitePoint.B) / 255
+	}
+
+	channel := func(v uint8, k float64) uint8 {
+		return unitToByte(math.Pow(float64(v)/255, gamma) * k)
+	}
+
+	return RGBColor{
+		R: channel(color.R, gain.R),
+		G: channel(color.G, gain.G),
+		B: channel(color.B, gain.B),
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCalibrationApply(t *testing.T) {
+	warm := RGBColor{R: 255, G: 204, B: 153}
+
+	tests := []struct {
+		name  string
+		cal   *Calibration
+		input RGBColor
+		want  RGBColor
+	}{
+		{
+			name:  "nil calibration",
+			cal:   nil,
+			input: RGBColor{R: 10, G: 20, B: 30},
+			want:  RGBColor{R: 10, G: 20, B: 30},
+		},
+		{
+			name:  "zero gamma is identity",
+			cal:   &Calibration{},
+			input: RGBColor{R: 10, G: 128, B: 250},
+			want:  RGBColor{R: 10, G: 128, B: 250},
+		},
+		{
+			name:  "gamma below one lifts dark tones",
+			cal:   &Calibration{Gamma: 0.5},
+			input: RGBColor{B: 64},
+			want:  RGBColor{B: 128},
+		},
+		{
+			name:  "gamma keeps extremes",
+			cal:   &Calibration{Gamma: 2.2},
+			input: RGBColor{R: 255},
+			want:  RGBColor{R: 255},
+		},
+		{
+			name:  "channel gains",
+			cal:   &Calibration{Gain: &ChannelGain{R: 1, G: 0.5, B: 0.8}},
+			input: RGBColor{R: 255, G: 255, B: 255},
+			want:  RGBColor{R: 255, G: 128, B: 204},
+		},
+		{
+			name:  "gain is clamped",
+			cal:   &Calibration{Gain: &ChannelGain{R: 2, G: 1, B: 1}},
+			input: RGBColor{R: 200},
+			want:  RGBColor{R: 255},
+		},
+		{
+			name:  "white point maps white",
+			cal:   &Calibration{WhitePoint: &warm},
+			input: RGBColor{R: 255, G: 255, B: 255},
+			want:  warm,
+		},
+		{
+			name:  "white point scales colors",
+			cal:   &Calibration{WhitePoint: &warm},
+			input: RGBColor{B: 100},
+			want:  RGBColor{B: 60},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cal.Apply(tt.input)
+			if got != tt.want {
+				t.Errorf("Apply(%v) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalibrationValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cal     Calibration
+		wantErr bool
+	}{
+		{name: "empty", cal: Calibration{}},
+		{name: "valid", cal: Calibration{Gamma: 2.2, Gain: &ChannelGain{R: 1, G: 0.9, B: 1.1}}},
+		{name: "negative gamma", cal: Calibration{Gamma: -1}, wantErr: true},
+		{name: "huge gamma", cal: Calibration{Gamma: 6}, wantErr: true},
+		{name: "gain too high", cal: Calibration{Gain: &ChannelGain{R: 1, G: 3, B: 1}}, wantErr: true},
+		{name: "negative gain", cal: Calibration{Gain: &ChannelGain{R: 1, G: 1, B: -0.1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cal.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCalibrationUnmarshal(t *testing.T) {
+	input := `
+gamma: 1.8
+gain: {g: 0.9}
+white_point: {kelvin: 6600}
+`
+	var cal Calibration
+	if err := yaml.Unmarshal([]byte(input), &cal); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cal.Gamma != 1.8 {
+		t.Errorf("Gamma = %v, want 1.8", cal.Gamma)
+	}
+	if want := (ChannelGain{R: 1, G: 0.9, B: 1}); cal.Gain == nil || *cal.Gain != want {
+		t.Errorf("Gain = %v, want %v", cal.Gain, want)
+	}
+	if cal.WhitePoint == nil || cal.WhitePoint.R != 255 {
+		t.Errorf("WhitePoint = %v, want near white", cal.WhitePoint)
+	}
+}
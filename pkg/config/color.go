@@ -0,0 +1,205 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseColor разбирает цвет, записанный строкой
+// Поддерживаемые форматы:
+//   - "#ff8800", "#f80"
+//   - имена CSS/X11: "orange", "light blue"
+//   - "rgb(255, 136, 0)"
+//   - "hsl(30, 100%, 50%)" - оттенок в градусах, насыщенность и светлота в процентах
+//   - "hsv(30, 100%, 100%)" - оттенок в градусах, насыщенность и яркость в процентах
+func ParseColor(s string) (RGBColor, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	if str == "" {
+		return RGBColor{}, fmt.Errorf("empty color")
+	}
+
+	if strings.HasPrefix(str, "#") {
+		return parseHexColor(str[1:])
+	}
+
+	if open := strings.IndexByte(str, '('); open > 0 {
+		if !strings.HasSuffix(str, ")") {
+			return RGBColor{}, fmt.Errorf("invalid color %q: missing closing parenthesis", s)
+		}
+		fn := strings.TrimSpace(str[:open])
+		args := strings.Split(str[open+1:len(str)-1], ",")
+		if len(args) != 3 {
+			return RGBColor{}, fmt.Errorf("invalid color %q: %s() expects 3 arguments", s, fn)
+		}
+		return parseColorFunc(fn, args)
+	}
+
+	name := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(str)
+	if rgb, ok := cssColors[name]; ok {
+		return RGBColor{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb)}, nil
+	}
+
+	return RGBColor{}, fmt.Errorf("unknown color %q (expected #rrggbb, a color name, rgb(), hsl() or hsv())", s)
+}
+
+// parseHexColor разбирает "rrggbb" или "rgb"
+func parseHexColor(hex string) (RGBColor, error) {
+	digits := hex
+	if len(digits) == 3 {
+		digits = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(digits) != 6 {
+		return RGBColor{}, fmt.Errorf("invalid hex color #%s (expected #rrggbb or #rgb)", hex)
+	}
+
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return RGBColor{}, fmt.Errorf("invalid hex color #%s", hex)
+	}
+	return RGBColor{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}, nil
+}
+
+// parseColorFunc разбирает rgb(), hsl() и hsv()
+func parseColorFunc(fn string, args []string) (RGBColor, error) {
+	switch fn {
+	case "rgb":
+		var c [3]uint8
+		for i, arg := range args {
+			v, err := strconv.ParseUint(strings.TrimSpace(arg), 10, 8)
+			if err != nil {
+				return RGBColor{}, fmt.Errorf("invalid rgb() component %q (expected 0-255)", strings.TrimSpace(arg))
+			}
+			c[i] = uint8(v)
+		}
+		return RGBColor{R: c[0], G: c[1], B: c[2]}, nil
+
+	case "hsl", "hsv":
+		h, err := parseHue(args[0])
+		if err != nil {
+			return RGBColor{}, err
+		}
+		s, err := parsePercent(args[1])
+		if err != nil {
+			return RGBColor{}, err
+		}
+		lv, err := parsePercent(args[2])
+		if err != nil {
+			return RGBColor{}, err
+		}
+		if fn == "hsl" {
+			return hslToRGB(h, s, lv), nil
+		}
+		return hsv360ToRGB(h, s, lv), nil
+
+	default:
+		return RGBColor{}, fmt.Errorf("unknown color function %s() (expected rgb, hsl or hsv)", fn)
+	}
+}
+
+// parseHue разбирает оттенок в градусах ("30", "30deg")
+func parseHue(arg string) (float64, error) {
+	str := strings.TrimSuffix(strings.TrimSpace(arg), "deg")
+	h, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hue %q (expected degrees 0-360)", strings.TrimSpace(arg))
+	}
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h, nil
+}
+
+// parsePercent разбирает процент ("50%" или "50") и возвращает долю 0..1
+func parsePercent(arg string) (float64, error) {
+	str := strings.TrimSuffix(strings.TrimSpace(arg), "%")
+	v, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("invalid percentage %q (expected 0-100%%)", strings.TrimSpace(arg))
+	}
+	return v / 100, nil
+}
+
+// hslToRGB конвертирует HSL (h в градусах, s и l 0..1) в RGB
+func hslToRGB(h, s, l float64) RGBColor {
+	c := (1 - math.Abs(2*l-1)) * s
+	return hueToRGB(h, c, l-c/2)
+}
+
+// hsv360ToRGB конвертирует HSV (h в градусах, s и v 0..1) в RGB
+func hsv360ToRGB(h, s, v float64) RGBColor {
+	c := v * s
+	return hueToRGB(h, c, v-c)
+}
+
+// hueToRGB собирает RGB из оттенка, хромы и минимальной компоненты
+func hueToRGB(h, chroma, m float64) RGBColor {
+	hp := h / 60
+	x := chroma * (1 - math.Abs(math.Mod(hp, 2)-1))
+
+	var r, g, b float64
+	switch int(hp) % 6 {
+	case 0:
+		r, g, b = chroma, x, 0
+	case 1:
+		r, g, b = x, chroma, 0
+	case 2:
+		r, g, b = 0, chroma, x
+	case 3:
+		r, g, b = 0, x, chroma
+	case 4:
+		r, g, b = x, 0, chroma
+	case 5:
+		r, g, b = chroma, 0, x
+	}
+
+	return RGBColor{R: unitToByte(r + m), G: unitToByte(g + m), B: unitToByte(b + m)}
+}
+
+// KelvinToRGB рассчитывает цвет излучения чёрного тела (аппроксимация Таннера Хелланда)
+// Применимо в диапазоне 1000-40000K: 2700K - тёплый белый, 6500K - дневной
+func KelvinToRGB(kelvin float64) RGBColor {
+	t := kelvin / 100
+
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	return RGBColor{R: clampByte(r), G: clampByte(g), B: clampByte(b)}
+}
+
+// unitToByte переводит долю 0..1 в 0..255 с округлением
+func unitToByte(v float64) uint8 {
+	return clampByte(v * 255)
+}
+
+// clampByte округляет и ограничивает значение диапазоном 0..255
+func clampByte(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(255, v))))
+}
+
+// String возвращает цвет в формате "#rrggbb"
+func (c RGBColor) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// MarshalYAML записывает цвет как "#rrggbb" - этот формат читается обратно без потерь
+func (c RGBColor) MarshalYAML() (interface{}, error) {
+	return c.String(), nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    RGBColor
+		wantErr bool
+	}{
+		{input: "#ff8800", want: RGBColor{255, 136, 0}},
+		{input: "#FF8800", want: RGBColor{255, 136, 0}},
+		{input: "#f80", want: RGBColor{255, 136, 0}},
+		{input: "  #0064ff ", want: RGBColor{0, 100, 255}},
+		{input: "orange", want: RGBColor{255, 165, 0}},
+		{input: "RebeccaPurple", want: RGBColor{102, 51, 153}},
+		{input: "light blue", want: RGBColor{173, 216, 230}},
+		{input: "gray", want: RGBColor{128, 128, 128}},
+		{input: "rgb(255, 136, 0)", want: RGBColor{255, 136, 0}},
+		{input: "hsl(30, 100%, 50%)", want: RGBColor{255, 128, 0}},
+		{input: "hsl(0, 0%, 100%)", want: RGBColor{255, 255, 255}},
+		{input: "hsl(240deg, 100%, 25%)", want: RGBColor{0, 0, 128}},
+		{input: "hsv(30, 100%, 100%)", want: RGBColor{255, 128, 0}},
+		{input: "hsv(120, 100%, 50%)", want: RGBColor{0, 128, 0}},
+		{input: "hsv(360, 100%, 100%)", want: RGBColor{255, 0, 0}},
+		{input: "#ff88", wantErr: true},
+		{input: "#gg0000", wantErr: true},
+		{input: "notacolor", wantErr: true},
+		{input: "hsl(30, 100%)", wantErr: true},
+		{input: "hsl(30, 150%, 50%)", wantErr: true},
+		{input: "rgb(256, 0, 0)", wantErr: true},
+		{input: "cmyk(0, 0, 0)", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseColor(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseColor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseColor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKelvinToRGB(t *testing.T) {
+	tests := []struct {
+		kelvin float64
+		want   RGBColor
+	}{
+		{1000, RGBColor{255, 68, 0}},
+		{2700, RGBColor{255, 167, 87}},
+		{6600, RGBColor{255, 255, 255}},
+		{10000, RGBColor{202, 218, 255}},
+	}
+
+	for _, tt := range tests {
+		got := KelvinToRGB(tt.kelvin)
+		if abs8(got.R, tt.want.R) > 2 || abs8(got.G, tt.want.G) > 2 || abs8(got.B, tt.want.B) > 2 {
+			t.Errorf("KelvinToRGB(%v) = %v, want %v (±2)", tt.kelvin, got, tt.want)
+		}
+	}
+}
+
+func TestRGBColorUnmarshalScalar(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want RGBColor
+	}{
+		{"hex", `"#ff8800"`, RGBColor{255, 136, 0}},
+		{"name", `white`, RGBColor{255, 255, 255}},
+		{"hsl", `hsl(30, 100%, 50%)`, RGBColor{255, 128, 0}},
+		{"kelvin", `{kelvin: 6600}`, RGBColor{255, 255, 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var color RGBColor
+			if err := yaml.Unmarshal([]byte(tt.yaml), &color); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if color != tt.want {
+				t.Errorf("color = %v, want %v", color, tt.want)
+			}
+		})
+	}
+}
+
+func TestRGBColorUnmarshalErrorPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "unknown name",
+			yaml:    "colors:\n  - layout: ru\n    color: reddish\n",
+			wantErr: "line 3, column 12: unknown color",
+		},
+		{
+			name:    "kelvin out of range",
+			yaml:    "colors:\n  - layout: ru\n    color: {kelvin: 100}\n",
+			wantErr: "line 3, column 12: kelvin must be between",
+		},
+		{
+			name:    "several formats",
+			yaml:    "colors:\n  - layout: ru\n    color:\n      rgb: {r: 1}\n      kelvin: 2700\n",
+			wantErr: "line 4, column 7: cannot specify more than one",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			err := yaml.Unmarshal([]byte(tt.yaml), &cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Unmarshal() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRGBColorRoundTrip(t *testing.T) {
+	inputs := []string{
+		`{rgb: {r: 1, g: 2, b: 3}}`,
+		`{hsv: {h: 170, s: 255, v: 180}}`,
+		`{kelvin: 2700}`,
+		`"#f80"`,
+		`cornflowerblue`,
+		`hsl(200, 50%, 40%)`,
+		`hsv(300, 20%, 90%)`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			var first RGBColor
+			if err := yaml.Unmarshal([]byte(input), &first); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			data, err := yaml.Marshal(ColorMapping{LayoutSelector: LayoutSelector{Layout: "ru"}, Color: first})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var second ColorMapping
+			if err := yaml.Unmarshal(data, &second); err != nil {
+				t.Fatalf("Unmarshal(%s) error = %v", data, err)
+			}
+			if second.Color != first {
+				t.Errorf("round trip = %v, want %v (yaml: %s)", second.Color, first, data)
+			}
+		})
+	}
+}
@@ -2,31 +2,33 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
 
-	"gopkg.in/yaml.v3"
+	"github.com/jidckii/kolor-keyboard/pkg/font"
 )
 
-// Load загружает конфигурацию из YAML файла
+// Load загружает конфигурацию из YAML файла вместе с файлами из geometry и include
+// Относительные пути (картинки, external.pipe) считаются от каталога файла,
+// в котором они заданы, и при загрузке становятся абсолютными
 func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	root, files, err := loadNode(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config: %w", err)
+		return nil, err
 	}
 
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := root.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	// Если firmware не указан, используем vial
-	if cfg.Firmware == "" {
-		cfg.Firmware = FirmwareVial
-	}
+	cfg.files = files
+	cfg.applyDefaults()
 
-	// Если mode не указан, используем mono
-	if cfg.Mode == "" {
-		cfg.Mode = ModeMono
+	if err := cfg.loadImages(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("failed to load images: %w", err)
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -36,13 +38,101 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// LoadDevice читает из файла только секцию device, остальной конфиг не проверяется
+func LoadDevice(path string) (*DeviceConfig, error) {
+	root, _, err := loadNode(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg struct {
+		Device DeviceConfig `yaml:"device"`
+	}
+	if err := root.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if cfg.Device.VendorID == 0 || cfg.Device.ProductID == 0 {
+		return nil, fmt.Errorf("device vendor_id and product_id are required")
+	}
+	return &cfg.Device, nil
+}
+
+// Files возвращает файлы на диске, из которых загружен конфиг: сам файл и его geometry и include
+func (c *Config) Files() []string {
+	return c.files
+}
+
+// applyDefaults заполняет значения по умолчанию
+func (c *Config) applyDefaults() {
+	// Если firmware не указан, используем vial
+	if c.Firmware == "" {
+		c.Firmware = FirmwareVial
+	}
+
+	// Если mode не указан, используем mono
+	if c.Mode == "" {
+		c.Mode = ModeMono
+	}
+
+	if c.Watcher == "" {
+		c.Watcher = WatcherAuto
+	}
+}
+
 // Validate проверяет корректность конфигурации
 func (c *Config) Validate() error {
+	if err := c.validateDevice(); err != nil {
+		return err
+	}
+	if err := c.validateFirmware(); err != nil {
+		return err
+	}
+	if err := c.validateWatcher(); err != nil {
+		return err
+	}
+
+	switch c.Mode {
+	case ModeMono:
+		if err := c.validateMono(); err != nil {
+			return err
+		}
+		for i := range c.Colors {
+			if err := c.validateColor(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ModeDraw:
+		if err := c.validateDraw(); err != nil {
+			return err
+		}
+		for i := range c.Drawings {
+			if err := c.validateFlag(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mode: %s (expected 'mono' or 'draw')", c.Mode)
+	}
+}
+
+// validateDevice проверяет секцию device
+func (c *Config) validateDevice() error {
 	if c.Device.VendorID == 0 || c.Device.ProductID == 0 {
 		return fmt.Errorf("device vendor_id and product_id are required")
 	}
 
-	// Проверяем firmware
+	if c.Device.Calibration != nil {
+		if err := c.Device.Calibration.Validate(); err != nil {
+			return fmt.Errorf("device %w", err)
+		}
+	}
+	return nil
+}
+
+// validateFirmware проверяет прошивку и её совместимость с режимом
+func (c *Config) validateFirmware() error {
 	switch c.Firmware {
 	case FirmwareStock, FirmwareVial:
 		// ok
@@ -54,15 +144,30 @@ func (c *Config) Validate() error {
 	if c.Mode == ModeDraw && c.Firmware == FirmwareStock {
 		return fmt.Errorf("draw mode requires vial firmware (stock firmware only supports mono mode)")
 	}
+	return nil
+}
 
-	switch c.Mode {
-	case ModeMono:
-		return c.validateMono()
-	case ModeDraw:
-		return c.validateDraw()
-	default:
-		return fmt.Errorf("unknown mode: %s (expected 'mono' or 'draw')", c.Mode)
+// validateWatcher проверяет источник раскладки
+func (c *Config) validateWatcher() error {
+	if !slices.Contains(Watchers, c.Watcher) {
+		return fmt.Errorf("unknown watcher: %s (expected %s)", c.Watcher, strings.Join(watcherNames(), ", "))
+	}
+
+	if c.Watcher != WatcherExternal {
+		if c.External != nil {
+			return fmt.Errorf("external is only used with watcher: %s", WatcherExternal)
+		}
+		return nil
+	}
+	switch {
+	case c.External == nil || len(c.External.Command) == 0 && c.External.Pipe == "":
+		return fmt.Errorf("watcher %s requires external.command or external.pipe", WatcherExternal)
+	case len(c.External.Command) > 0 && c.External.Pipe != "":
+		return fmt.Errorf("external: set either command or pipe, not both")
+	case len(c.External.Command) > 0 && c.External.Command[0] == "":
+		return fmt.Errorf("external.command: program name is empty")
 	}
+	return nil
 }
 
 func (c *Config) validateMono() error {
@@ -72,6 +177,15 @@ func (c *Config) validateMono() error {
 	return nil
 }
 
+// validateColor проверяет i-ю запись colors
+func (c *Config) validateColor(i int) error {
+	if err := c.Colors[i].LayoutSelector.Validate(); err != nil {
+		return fmt.Errorf("colors[%d] (%s): %w", i, c.Colors[i].LayoutSelector, err)
+	}
+	return nil
+}
+
+// validateDraw проверяет общие настройки draw режима (рисунки проверяет validateFlag)
 func (c *Config) validateDraw() error {
 	if len(c.Keyboard.Rows) == 0 {
 		return fmt.Errorf("keyboard.rows is required for draw mode")
@@ -81,18 +195,96 @@ func (c *Config) validateDraw() error {
 		return fmt.Errorf("at least one drawing mapping is required for draw mode")
 	}
 
+	for i, key := range c.Keyboard.Geometry {
+		if key.LED < 0 {
+			return fmt.Errorf("keyboard.geometry[%d]: invalid led %d", i, key.LED)
+		}
+	}
+	return nil
+}
+
+// validateFlag проверяет i-й рисунок
+func (c *Config) validateFlag(i int) error {
+	flag := &c.Drawings[i]
+	if err := flag.LayoutSelector.Validate(); err != nil {
+		return fmt.Errorf("flag[%d] (%s): %w", i, flag.LayoutSelector, err)
+	}
+	if len(flag.Stripes) == 0 && len(flag.Grid) == 0 && flag.Image == "" && flag.Text == nil {
+		return fmt.Errorf("flag[%d] (%s): at least one stripe, grid, image or text is required", i, flag.LayoutSelector)
+	}
+	if flag.Text != nil {
+		if flag.Text.Value == "" {
+			return fmt.Errorf("flag[%d] (%s) text: value is required", i, flag.LayoutSelector)
+		}
+		if flag.Text.Speed < 0 {
+			return fmt.Errorf("flag[%d] (%s) text: speed must not be negative", i, flag.LayoutSelector)
+		}
+		if missing := font.Missing(flag.Text.Value); len(missing) > 0 {
+			return fmt.Errorf("flag[%d] (%s) text: characters %q are not in the built-in font", i, flag.LayoutSelector, string(missing))
+		}
+	}
+	switch flag.Sampling {
+	case "", SamplingNearest, SamplingArea:
+		// ok
+	default:
+		return fmt.Errorf("flag[%d] (%s): unknown sampling: %s (expected 'nearest' or 'area')",
+			i, flag.LayoutSelector, flag.Sampling)
+	}
+
 	// Проверяем что все stripes ссылаются на существующие ряды
 	numRows := len(c.Keyboard.Rows)
-	for i, flag := range c.Drawings {
-		if len(flag.Stripes) == 0 {
-			return fmt.Errorf("flag[%d] (%s): at least one stripe is required", i, flag.Layout)
-		}
-		for j, stripe := range flag.Stripes {
-			for _, row := range stripe.Rows {
-				if row < 0 || row >= numRows {
-					return fmt.Errorf("flag[%d] (%s) stripe[%d]: invalid row %d (keyboard has %d rows)",
-						i, flag.Layout, j, row, numRows)
-				}
+	for j, stripe := range flag.Stripes {
+		for _, row := range stripe.Rows {
+			if row < 0 || row >= numRows {
+				return fmt.Errorf("flag[%d] (%s) stripe[%d]: invalid row %d (keyboard has %d rows)",
+					i, flag.LayoutSelector, j, row, numRows)
+			}
+		}
+	}
+	return c.validateGrid(i, flag)
+}
+
+// validateGrid проверяет что сетка рисунка совпадает с рядами клавиатуры
+// (или с колонками keyboard.geometry, если она задана, см. GridColumns)
+func (c *Config) validateGrid(i int, flag *FlagMapping) error {
+	if len(flag.Grid) == 0 {
+		return nil
+	}
+
+	for key := range flag.Palette {
+		runes := []rune(key)
+		if len(runes) != 1 {
+			return fmt.Errorf("flag[%d] (%s) palette: key %q must be a single character", i, flag.LayoutSelector, key)
+		}
+		if IsGridBlank(runes[0]) {
+			return fmt.Errorf("flag[%d] (%s) palette: key %q is reserved for empty grid cells", i, flag.LayoutSelector, key)
+		}
+	}
+
+	numRows := len(c.Keyboard.Rows)
+	if len(flag.Grid) != numRows {
+		return fmt.Errorf("flag[%d] (%s) grid%s: %d rows, keyboard.rows has %d",
+			i, flag.LayoutSelector, lineSuffix(flag.GridLine(0)), len(flag.Grid), numRows)
+	}
+
+	for row, line := range flag.Grid {
+		cells := []rune(line)
+		width, _ := c.GridColumns(row)
+		if len(cells) != width {
+			if len(c.Keyboard.Geometry) > 0 {
+				return fmt.Errorf("flag[%d] (%s) grid row %d%s: %d columns, keyboard.geometry is %d columns wide",
+					i, flag.LayoutSelector, row, lineSuffix(flag.GridLine(row)), len(cells), width)
+			}
+			return fmt.Errorf("flag[%d] (%s) grid row %d%s: %d columns, keyboard row has %d LEDs",
+				i, flag.LayoutSelector, row, lineSuffix(flag.GridLine(row)), len(cells), width)
+		}
+		for col, ch := range cells {
+			if IsGridBlank(ch) {
+				continue
+			}
+			if _, ok := flag.Palette[string(ch)]; !ok {
+				return fmt.Errorf("flag[%d] (%s) grid row %d%s column %d: symbol %q is not in palette",
+					i, flag.LayoutSelector, row, lineSuffix(flag.GridLine(row)), col, ch)
 			}
 		}
 	}
@@ -100,36 +292,87 @@ func (c *Config) validateDraw() error {
 	return nil
 }
 
-// GetColorForLayout возвращает цвет для указанной раскладки (mono mode)
-func (c *Config) GetColorForLayout(layout string) *RGBColor {
-	for i := range c.Colors {
-		if c.Colors[i].Layout == layout {
-			return &c.Colors[i].Color
-		}
+// lineSuffix форматирует номер строки YAML для сообщений об ошибках
+func lineSuffix(line int) string {
+	if line <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (line %d)", line)
+}
+
+// ColorFor возвращает цвет для раскладки (mono mode)
+// Запись выбирается по специфичности, см. LayoutSelector.Match
+func (c *Config) ColorFor(l ActiveLayout) *RGBColor {
+	i := bestMatch(len(c.Colors), func(i int) *LayoutSelector { return &c.Colors[i].LayoutSelector }, l)
+	if i < 0 {
+		return nil
 	}
-	// Fallback на wildcard
-	for i := range c.Colors {
-		if c.Colors[i].Layout == "*" {
-			return &c.Colors[i].Color
+	return &c.Colors[i].Color
+}
+
+// FlagFor возвращает флаг для раскладки (draw mode)
+// Запись выбирается по специфичности, см. LayoutSelector.Match
+func (c *Config) FlagFor(l ActiveLayout) *FlagMapping {
+	i := bestMatch(len(c.Drawings), func(i int) *LayoutSelector { return &c.Drawings[i].LayoutSelector }, l)
+	if i < 0 {
+		return nil
+	}
+	return &c.Drawings[i]
+}
+
+// entryFor возвращает условия записи текущего режима, выбранной для раскладки (nil - подходящей нет)
+func (c *Config) entryFor(l ActiveLayout) *LayoutSelector {
+	switch c.Mode {
+	case ModeMono:
+		if i := bestMatch(len(c.Colors), func(i int) *LayoutSelector { return &c.Colors[i].LayoutSelector }, l); i >= 0 {
+			return &c.Colors[i].LayoutSelector
+		}
+	case ModeDraw:
+		if i := bestMatch(len(c.Drawings), func(i int) *LayoutSelector { return &c.Drawings[i].LayoutSelector }, l); i >= 0 {
+			return &c.Drawings[i].LayoutSelector
 		}
 	}
 	return nil
 }
 
-// GetFlagForLayout возвращает флаг для указанной раскладки (draw mode)
-func (c *Config) GetFlagForLayout(layout string) *FlagMapping {
-	for i := range c.Drawings {
-		if c.Drawings[i].Layout == layout {
-			return &c.Drawings[i]
+// Uncovered проверяет раскладки системы по записям текущего режима
+// missing - раскладки без подходящей записи, wildcard - подходит только запись "*"
+func (c *Config) Uncovered(layouts []ActiveLayout) (missing, wildcard []ActiveLayout) {
+	for _, l := range layouts {
+		switch sel := c.entryFor(l); {
+		case sel == nil:
+			missing = append(missing, l)
+		case sel.IsWildcard():
+			wildcard = append(wildcard, l)
 		}
 	}
-	// Fallback на wildcard
-	for i := range c.Drawings {
-		if c.Drawings[i].Layout == "*" {
-			return &c.Drawings[i]
+	return missing, wildcard
+}
+
+// GetColorForLayout возвращает цвет для раскладки в записи "us" или "us(dvorak)" (mono mode)
+func (c *Config) GetColorForLayout(layout string) *RGBColor {
+	return c.ColorFor(ParseLayout(layout))
+}
+
+// GetFlagForLayout возвращает флаг для раскладки в записи "us" или "us(dvorak)" (draw mode)
+func (c *Config) GetFlagForLayout(layout string) *FlagMapping {
+	return c.FlagFor(ParseLayout(layout))
+}
+
+// Layouts возвращает условия записей текущего режима в порядке конфига ("us", "us(dvorak)", "name=...")
+func (c *Config) Layouts() []string {
+	var layouts []string
+	switch c.Mode {
+	case ModeMono:
+		for i := range c.Colors {
+			layouts = append(layouts, c.Colors[i].LayoutSelector.String())
+		}
+	case ModeDraw:
+		for i := range c.Drawings {
+			layouts = append(layouts, c.Drawings[i].LayoutSelector.String())
 		}
 	}
-	return nil
+	return layouts
 }
 
 // GetLEDsForRow возвращает индексы LED для указанного ряда клавиатуры
@@ -140,6 +383,43 @@ func (c *Config) GetLEDsForRow(row int) []int {
 	return c.Keyboard.Rows[row]
 }
 
+// GridColumns возвращает ширину строки сетки для ряда row и колонку сетки
+// для каждого LED ряда (по порядку keyboard.rows)
+// Без keyboard.geometry символ соответствует клавише ряда по порядку.
+// С geometry колонка - единица ширины клавиатуры (1u): клавиша берёт символ
+// колонки, в которую попадает её центр, а ширина строки - ширина клавиатуры.
+// LED без записи в geometry получает колонку -1 и сеткой не закрашивается
+func (c *Config) GridColumns(row int) (width int, columns []int) {
+	leds := c.GetLEDsForRow(row)
+	columns = make([]int, len(leds))
+	if len(c.Keyboard.Geometry) == 0 {
+		for j := range leds {
+			columns[j] = j
+		}
+		return len(leds), columns
+	}
+
+	keys := make(map[int]KeyGeometry, len(c.Keyboard.Geometry))
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, k := range c.Keyboard.Geometry {
+		w, _ := k.Size()
+		minX = min(minX, k.X)
+		maxX = max(maxX, k.X+w)
+		keys[k.LED] = k
+	}
+	for j, led := range leds {
+		k, ok := keys[led]
+		if !ok {
+			columns[j] = -1
+			continue
+		}
+		w, _ := k.Size()
+		columns[j] = int(math.Floor(k.X + w/2 - minX))
+	}
+	// Допуск на дробные координаты вроде 18.25 - 1e-9
+	return int(math.Ceil(maxX - minX - 1e-9)), columns
+}
+
 // GetAllLEDIndices возвращает все индексы LED из конфигурации клавиатуры
 func (c *Config) GetAllLEDIndices() []int {
 	var indices []int
@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -159,6 +161,27 @@ func TestGetSpeed(t *testing.T) {
 	}
 }
 
+func TestLayouts(t *testing.T) {
+	cfg := &Config{
+		Mode: ModeMono,
+		Colors: []ColorMapping{
+			{LayoutSelector: LayoutSelector{Layout: "us"}},
+			{LayoutSelector: LayoutSelector{Layout: "ru"}},
+			{LayoutSelector: LayoutSelector{Layout: "*"}},
+		},
+		Drawings: []FlagMapping{{LayoutSelector: LayoutSelector{Layout: "ua"}}},
+	}
+
+	if got := cfg.Layouts(); !slices.Equal(got, []string{"us", "ru", "*"}) {
+		t.Errorf("Layouts() in mono mode = %v", got)
+	}
+
+	cfg.Mode = ModeDraw
+	if got := cfg.Layouts(); !slices.Equal(got, []string{"ua"}) {
+		t.Errorf("Layouts() in draw mode = %v", got)
+	}
+}
+
 func TestValidation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -232,6 +255,144 @@ draw:
 `,
 			wantErr: true, // ряд 5 не существует
 		},
+		{
+			name: "text drawing",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+firmware: vial
+mode: draw
+keyboard:
+  rows: [[0,1,2]]
+draw:
+  - layout: "*"
+    text:
+      value: "RU"
+      color: {rgb: {r: 255, g: 0, b: 0}}
+      speed: 4
+`,
+			wantErr: false,
+		},
+		{
+			name: "text with characters missing from the font",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+firmware: vial
+mode: draw
+keyboard:
+  rows: [[0,1,2]]
+draw:
+  - layout: "*"
+    text:
+      value: "한국"
+      color: {rgb: {r: 255, g: 0, b: 0}}
+`,
+			wantErr: true,
+		},
+		{
+			name: "text without value",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+firmware: vial
+mode: draw
+keyboard:
+  rows: [[0,1,2]]
+draw:
+  - layout: "*"
+    text:
+      color: {rgb: {r: 255, g: 0, b: 0}}
+`,
+			wantErr: true,
+		},
+		{
+			name: "explicit watcher",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+watcher: sway
+colors:
+  - layout: "*"
+    color: red
+`,
+			wantErr: false,
+		},
+		{
+			name: "external command",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+watcher: external
+external:
+  command: [xkb-switch, -W]
+colors:
+  - layout: "*"
+    color: red
+`,
+			wantErr: false,
+		},
+		{
+			name: "external without source",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+watcher: external
+colors:
+  - layout: "*"
+    color: red
+`,
+			wantErr: true,
+		},
+		{
+			name: "external with both sources",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+watcher: external
+external:
+  command: [xkb-switch, -W]
+  pipe: /tmp/layout
+colors:
+  - layout: "*"
+    color: red
+`,
+			wantErr: true,
+		},
+		{
+			name: "external with another watcher",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+external:
+  pipe: "-"
+colors:
+  - layout: "*"
+    color: red
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown watcher",
+			config: `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+watcher: wayfire
+colors:
+  - layout: "*"
+    color: red
+`,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -249,3 +410,206 @@ draw:
 		})
 	}
 }
+
+func TestLoadDrawGrid(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "grid.yaml")
+
+	configContent := `
+device:
+  vendor_id: 0x3434
+  product_id: 0x0331
+
+firmware: vial
+mode: draw
+
+keyboard:
+  rows:
+    - [0, 1, 2, 3]
+    - [4, 5, 6]
+
+draw:
+  - layout: ua
+    palette:
+      B: {rgb: {r: 0, g: 90, b: 200}}
+      Y: {rgb: {r: 255, g: 215, b: 0}}
+    grid:
+      - "BB.B"
+      - "YYY"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	flag := cfg.GetFlagForLayout("ua")
+	if flag == nil {
+		t.Fatal("GetFlagForLayout(ua) returned nil")
+	}
+	if len(flag.Grid) != 2 {
+		t.Fatalf("len(Grid) = %d, want 2", len(flag.Grid))
+	}
+	if len(flag.Palette) != 2 {
+		t.Errorf("len(Palette) = %d, want 2", len(flag.Palette))
+	}
+	if flag.GridLine(0) != 20 || flag.GridLine(1) != 21 {
+		t.Errorf("GridLine = %d, %d, want 20, 21", flag.GridLine(0), flag.GridLine(1))
+	}
+}
+
+func TestValidateGrid(t *testing.T) {
+	header := `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+firmware: vial
+mode: draw
+keyboard:
+  rows: [[0, 1, 2], [3, 4]]
+draw:
+  - layout: "*"
+    palette:
+      R: {rgb: {r: 255, g: 0, b: 0}}
+`
+	tests := []struct {
+		name    string
+		grid    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			grid: `
+    grid:
+      - "RRR"
+      - "R."
+`,
+		},
+		{
+			name: "too many columns",
+			grid: `
+    grid:
+      - "RRR"
+      - "RRR"
+`,
+			wantErr: "grid row 1 (line 15): 3 columns, keyboard row has 2 LEDs",
+		},
+		{
+			name: "row count mismatch",
+			grid: `
+    grid:
+      - "RRR"
+`,
+			wantErr: "grid (line 14): 1 rows, keyboard.rows has 2",
+		},
+		{
+			name: "unknown symbol",
+			grid: `
+    grid:
+      - "RRR"
+      - "RX"
+`,
+			wantErr: `grid row 1 (line 15) column 1: symbol 'X' is not in palette`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "grid.yaml")
+			if err := os.WriteFile(configPath, []byte(header+tt.grid[1:]), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			_, err := Load(configPath)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateGridGeometry(t *testing.T) {
+	// Ряд 0: клавиши 1u в колонках 0 и 2, ряд 1: клавиша 2u и клавиша 1u - ширина 3 колонки
+	header := `
+device:
+  vendor_id: 0x1234
+  product_id: 0x5678
+mode: draw
+keyboard:
+  rows: [[0, 1], [2, 3]]
+  geometry:
+    - {led: 0, x: 0, y: 0}
+    - {led: 1, x: 2, y: 0}
+    - {led: 2, x: 0, y: 1, w: 2}
+    - {led: 3, x: 2, y: 1}
+draw:
+  - layout: "*"
+`
+	tests := []struct {
+		name    string
+		drawing string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			drawing: `
+    palette: {R: red}
+    grid:
+      - "R.R"
+      - "RRR"
+`,
+		},
+		{
+			name: "row shorter than the keyboard",
+			drawing: `
+    palette: {R: red}
+    grid:
+      - "RR"
+      - "RRR"
+`,
+			wantErr: "grid row 0 (line 17): 2 columns, keyboard.geometry is 3 columns wide",
+		},
+		{
+			name: "reserved palette key",
+			drawing: `
+    palette: {R: red, ".": blue}
+    grid:
+      - "R.R"
+      - "RRR"
+`,
+			wantErr: `palette: key "." is reserved for empty grid cells`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "grid.yaml")
+			if err := os.WriteFile(configPath, []byte(header+tt.drawing[1:]), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			cfg, err := Load(configPath)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				if width, columns := cfg.GridColumns(1); width != 3 || !slices.Equal(columns, []int{1, 2}) {
+					t.Errorf("GridColumns(1) = %d, %v, want 3, [1 2]", width, columns)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
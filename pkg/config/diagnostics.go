@@ -0,0 +1,498 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity - важность найденной проблемы
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // Конфиг не загрузится или работает не так, как написано
+	SeverityWarning Severity = "warning" // Конфиг рабочий, но, скорее всего, содержит ошибку
+)
+
+// Diagnostic - проблема в конфиге с позицией в файле
+type Diagnostic struct {
+	Severity Severity
+	Line     int // 0 - позиция неизвестна
+	Column   int
+	Message  string
+}
+
+// String форматирует проблему как "line:column: severity: message"
+func (d Diagnostic) String() string {
+	switch {
+	case d.Line > 0 && d.Column > 0:
+		return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
+	case d.Line > 0:
+		return fmt.Sprintf("%d: %s: %s", d.Line, d.Severity, d.Message)
+	default:
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+}
+
+// HasErrors сообщает, есть ли среди проблем ошибки
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Check проверяет файл конфига и собирает все ошибки и предупреждения
+// В отличие от Validate не останавливается на первой проблеме и указывает строку и колонку
+// ledCount - число LED подключённой клавиатуры (0 - не проверять)
+// Ошибка возвращается только если файл не удалось прочитать
+func Check(path string, ledCount int) ([]Diagnostic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	ch := &checker{ledCount: ledCount, baseDir: filepath.Dir(path), failed: make(map[*yaml.Node]bool)}
+	ch.run(data, source{path: path})
+
+	sort.SliceStable(ch.diags, func(i, j int) bool {
+		a, b := ch.diags[i], ch.diags[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return ch.diags, nil
+}
+
+// checker собирает проблемы одного файла
+type checker struct {
+	cfg      Config
+	root     *yaml.Node
+	ledCount int
+	baseDir  string
+	diags    []Diagnostic
+	failed   map[*yaml.Node]bool // секции и элементы списков, которые не удалось декодировать
+}
+
+func (ch *checker) report(sev Severity, node *yaml.Node, format string, args ...any) {
+	d := Diagnostic{Severity: sev, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		d.Line, d.Column = node.Line, node.Column
+	}
+	ch.diags = append(ch.diags, d)
+}
+
+func (ch *checker) errorf(node *yaml.Node, format string, args ...any) {
+	ch.report(SeverityError, node, format, args...)
+}
+
+func (ch *checker) warnf(node *yaml.Node, format string, args ...any) {
+	ch.report(SeverityWarning, node, format, args...)
+}
+
+// run проверяет конфиг; проблемы в файлах из geometry и include выводятся без позиции
+func (ch *checker) run(data []byte, src source) {
+	if root, err := parseNode(data); err == nil {
+		if version, err := documentVersion(root); err == nil && version < latestVersion() {
+			var node *yaml.Node
+			if len(root.Content) > 0 {
+				node = root.Content[0]
+			}
+			ch.warnf(node, "config format version %d is outdated, run 'kolor-keyboard config migrate'", version)
+		}
+	}
+
+	root, _, err := prepareNode(data, src)
+	if err != nil {
+		ch.decodeError(err)
+		return
+	}
+	ch.root = root
+	ch.decodeConfig()
+	ch.cfg.applyDefaults()
+	cfg := &ch.cfg
+
+	if ch.ok("device") {
+		if err := cfg.validateDevice(); err != nil {
+			ch.errorf(mapKey(ch.root, "device"), "%v", err)
+		}
+	}
+	if ch.ok("firmware") && ch.ok("mode") {
+		if err := cfg.validateFirmware(); err != nil {
+			ch.errorf(orNode(mapValue(ch.root, "firmware"), ch.root), "%v", err)
+		}
+	}
+	if ch.ok("watcher") && ch.ok("external") {
+		if err := cfg.validateWatcher(); err != nil {
+			// Неизвестный watcher - ошибка в watcher, остальное - в настройках external
+			node := mapValue(ch.root, "watcher")
+			if slices.Contains(Watchers, cfg.Watcher) {
+				node = orNode(mapKey(ch.root, "external"), node)
+			}
+			ch.errorf(orNode(node, ch.root), "%v", err)
+		}
+	}
+
+	switch {
+	case !ch.ok("mode"):
+		// ошибку mode уже сообщил decodeConfig
+	case cfg.Mode == ModeMono:
+		if err := cfg.validateMono(); err != nil {
+			ch.errorf(orNode(mapKey(ch.root, "colors"), ch.root), "%v", err)
+		}
+		colorsNode := mapValue(ch.root, "colors")
+		for i := range cfg.Colors {
+			itemNode := seqItem(colorsNode, i)
+			if ch.failed[itemNode] {
+				continue
+			}
+			if err := cfg.validateColor(i); err != nil {
+				ch.errorf(orNode(itemNode, ch.root), "%v", err)
+			}
+		}
+		ch.checkLayouts(colorsNode)
+	case cfg.Mode == ModeDraw:
+		// Без keyboard проверки рисунков сообщат о рядах, которых нет только из-за ошибки в keyboard
+		keyboardOK := ch.ok("keyboard")
+		if keyboardOK {
+			if err := cfg.validateDraw(); err != nil {
+				ch.errorf(orNode(mapKey(ch.root, "draw"), ch.root), "%v", err)
+			}
+		}
+		drawNode := mapValue(ch.root, "draw")
+		allDecoded := true
+		for i := range cfg.Drawings {
+			flagNode := seqItem(drawNode, i)
+			if ch.failed[flagNode] {
+				allDecoded = false
+				continue
+			}
+			ch.checkImage(i, flagNode)
+			if !keyboardOK {
+				continue
+			}
+			if err := cfg.validateFlag(i); err != nil {
+				ch.errorf(flagNode, "%v", err)
+			}
+			ch.checkStripes(i, flagNode)
+		}
+		ch.checkLayouts(drawNode)
+		if keyboardOK && allDecoded {
+			ch.checkUnusedRows()
+		}
+	default:
+		ch.errorf(orNode(mapValue(ch.root, "mode"), ch.root), "unknown mode: %s (expected 'mono' or 'draw')", cfg.Mode)
+	}
+
+	ch.checkKeyboard()
+}
+
+// ok сообщает, что секция key декодирована (или отсутствует)
+func (ch *checker) ok(key string) bool {
+	return !ch.failed[mapValue(ch.root, key)]
+}
+
+// decodeConfig декодирует конфиг в ch.cfg
+// Если весь конфиг не декодируется, секции и элементы списков декодируются по одному:
+// одна ошибка (например, неверный цвет) не скрывает остальные проблемы.
+// Неудачные узлы сообщаются, попадают в ch.failed и дальше не проверяются
+func (ch *checker) decodeConfig() {
+	if err := ch.root.Decode(&ch.cfg); err == nil {
+		return
+	}
+
+	ch.cfg = Config{}
+	doc := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(ch.root.Content); i += 2 {
+		key, value := ch.root.Content[i], ch.root.Content[i+1]
+		err := decodeSection(key, value)
+		if err != nil && value.Kind == yaml.SequenceNode && decodeSection(key, &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}) == nil {
+			value, err = ch.decodeItems(key, value), nil
+		}
+		if err != nil {
+			ch.decodeError(err)
+			ch.failed[value] = true
+			continue
+		}
+		doc.Content = append(doc.Content, key, value)
+	}
+	// Ошибки уже сообщены по частям
+	_ = doc.Decode(&ch.cfg)
+}
+
+// decodeItems декодирует элементы списка key по одному
+// Возвращает копию списка, где неудачные элементы заменены пустым mapping, чтобы индексы совпадали с файлом
+func (ch *checker) decodeItems(key, seq *yaml.Node) *yaml.Node {
+	out := *seq
+	out.Content = slices.Clone(seq.Content)
+	for i, item := range seq.Content {
+		one := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{item}}
+		if err := decodeSection(key, one); err != nil {
+			ch.decodeError(err)
+			ch.failed[item] = true
+			out.Content[i] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+	}
+	return &out
+}
+
+// decodeSection декодирует в Config одну пару ключ-значение верхнего уровня
+func decodeSection(key, value *yaml.Node) error {
+	var cfg Config
+	section := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{key, value}}
+	return section.Decode(&cfg)
+}
+
+// lineColumnRe - позиция в сообщениях yaml.v3 и nodeError
+var lineColumnRe = regexp.MustCompile(`^(?:yaml: )?line (\d+)(?:, column (\d+))?: (.*)$`)
+
+// decodeError переводит ошибки разбора YAML в проблемы с позициями
+func (ch *checker) decodeError(err error) {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	for _, msg := range messages {
+		d := Diagnostic{Severity: SeverityError, Message: msg}
+		if m := lineColumnRe.FindStringSubmatch(msg); m != nil {
+			d.Line, _ = strconv.Atoi(m[1])
+			d.Column, _ = strconv.Atoi(m[2])
+			d.Message = m[3]
+		}
+		ch.diags = append(ch.diags, d)
+	}
+}
+
+// checkLayouts ищет повторяющиеся раскладки и записи после "*"
+func (ch *checker) checkLayouts(seq *yaml.Node) {
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return
+	}
+
+	first := make(map[string]int)
+	wildcard := 0
+	for _, item := range seq.Content {
+		var sel LayoutSelector
+		if err := item.Decode(&sel); err != nil || sel.Validate() != nil {
+			continue // ошибку сообщает validateColor/validateFlag
+		}
+		node := orNode(mapValue(item, "layout"), item)
+		layout := sel.String()
+
+		if line, ok := first[sel.key()]; ok {
+			ch.errorf(node, "duplicate layout %q is never used (first defined at line %d)", layout, line)
+			continue
+		}
+		first[sel.key()] = node.Line
+
+		isWildcard := sel.IsWildcard()
+		if wildcard > 0 && !isWildcard {
+			ch.warnf(node, "layout %q is listed after \"*\" (line %d); specific layouts always win, "+
+				"keep \"*\" last to make that obvious", layout, wildcard)
+		}
+		if isWildcard {
+			wildcard = node.Line
+		}
+	}
+}
+
+// checkImage проверяет, что картинка рисунка читается
+func (ch *checker) checkImage(i int, flagNode *yaml.Node) {
+	flag := &ch.cfg.Drawings[i]
+	if flag.Image == "" {
+		return
+	}
+	path := flag.Image
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ch.baseDir, path)
+	}
+	if _, err := LoadImage(path); err != nil {
+		ch.errorf(orNode(mapValue(flagNode, "image"), flagNode), "%v", err)
+	}
+}
+
+// checkStripes ищет LED, закрашенные в рисунке несколько раз, и LED вне клавиатуры
+func (ch *checker) checkStripes(i int, flagNode *yaml.Node) {
+	flag := &ch.cfg.Drawings[i]
+	stripesNode := mapValue(flagNode, "stripes")
+	known := ch.rowLEDs()
+
+	owner := make(map[int]int) // LED -> номер полосы, которая закрасила его первой
+	for j, stripe := range flag.Stripes {
+		stripeNode := seqItem(stripesNode, j)
+		ledsNode := mapValue(stripeNode, "leds")
+
+		var leds []int
+		for _, row := range stripe.Rows {
+			leds = append(leds, ch.cfg.GetLEDsForRow(row)...)
+		}
+		for k, led := range stripe.LEDs {
+			node := orNode(seqItem(ledsNode, k), stripeNode)
+			switch {
+			case led < 0:
+				ch.errorf(node, "invalid led %d", led)
+			case ch.ledCount > 0 && led >= ch.ledCount:
+				ch.errorf(node, "led %d is beyond the device LED count (%d)", led, ch.ledCount)
+			case !known[led]:
+				ch.warnf(node, "led %d is not in keyboard.rows", led)
+			}
+			leds = append(leds, led)
+		}
+
+		overlap := make(map[int][]int) // номер полосы -> LED, закрашенные ей раньше
+		for _, led := range leds {
+			prev, ok := owner[led]
+			if !ok {
+				owner[led] = j
+				continue
+			}
+			overlap[prev] = append(overlap[prev], led)
+		}
+
+		prevs := make([]int, 0, len(overlap))
+		for prev := range overlap {
+			prevs = append(prevs, prev)
+		}
+		sort.Ints(prevs)
+		for _, prev := range prevs {
+			if prev == j {
+				ch.warnf(stripeNode, "flag[%d] (%s) stripe[%d]: leds %v are listed more than once",
+					i, flag.LayoutSelector, j, overlap[prev])
+				continue
+			}
+			ch.warnf(stripeNode, "flag[%d] (%s) stripe[%d]: leds %v are already set by stripe[%d] and will be overwritten",
+				i, flag.LayoutSelector, j, overlap[prev], prev)
+		}
+	}
+}
+
+// checkKeyboard проверяет индексы LED в keyboard.rows и keyboard.geometry
+func (ch *checker) checkKeyboard() {
+	keyboard := mapValue(ch.root, "keyboard")
+	rowsNode := mapValue(keyboard, "rows")
+
+	seen := make(map[int]bool)
+	for r, row := range ch.cfg.Keyboard.Rows {
+		rowNode := seqItem(rowsNode, r)
+		for j, led := range row {
+			node := orNode(seqItem(rowNode, j), rowNode)
+			switch {
+			case led < 0:
+				ch.errorf(node, "keyboard.rows[%d]: invalid led %d", r, led)
+			case ch.ledCount > 0 && led >= ch.ledCount:
+				ch.errorf(node, "keyboard.rows[%d]: led %d is beyond the device LED count (%d)", r, led, ch.ledCount)
+			case seen[led]:
+				ch.warnf(node, "keyboard.rows[%d]: led %d appears in keyboard.rows more than once", r, led)
+			}
+			seen[led] = true
+		}
+	}
+
+	if ch.ledCount == 0 {
+		return
+	}
+	geometryNode := mapValue(keyboard, "geometry")
+	for i, key := range ch.cfg.Keyboard.Geometry {
+		if key.LED >= ch.ledCount {
+			ch.errorf(seqItem(geometryNode, i), "keyboard.geometry[%d]: led %d is beyond the device LED count (%d)",
+				i, key.LED, ch.ledCount)
+		}
+	}
+}
+
+// checkUnusedRows ищет ряды, которые не закрашивает ни один рисунок
+func (ch *checker) checkUnusedRows() {
+	used := make(map[int]bool)
+	usedLEDs := make(map[int]bool)
+	for _, flag := range ch.cfg.Drawings {
+		// Сетка, картинка и текст покрывают всю клавиатуру
+		if len(flag.Grid) > 0 || flag.Image != "" || flag.Text != nil {
+			return
+		}
+		for _, stripe := range flag.Stripes {
+			for _, row := range stripe.Rows {
+				used[row] = true
+			}
+			for _, led := range stripe.LEDs {
+				usedLEDs[led] = true
+			}
+		}
+	}
+
+	rowsNode := mapValue(mapValue(ch.root, "keyboard"), "rows")
+	for r, row := range ch.cfg.Keyboard.Rows {
+		if used[r] {
+			continue
+		}
+		partly := false
+		for _, led := range row {
+			partly = partly || usedLEDs[led]
+		}
+		if !partly {
+			ch.warnf(seqItem(rowsNode, r), "keyboard.rows[%d] is not used by any drawing", r)
+		}
+	}
+}
+
+// rowLEDs возвращает множество LED из keyboard.rows
+func (ch *checker) rowLEDs() map[int]bool {
+	leds := make(map[int]bool)
+	for _, led := range ch.cfg.GetAllLEDIndices() {
+		leds[led] = true
+	}
+	return leds
+}
+
+// mapKey возвращает узел ключа key в mapping (nil, если ключа нет)
+func mapKey(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// mapValue возвращает узел значения key в mapping (nil, если ключа нет)
+func mapValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// seqItem возвращает i-й элемент sequence (nil, если его нет)
+func seqItem(node *yaml.Node, i int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || i < 0 || i >= len(node.Content) {
+		return nil
+	}
+	return node.Content[i]
+}
+
+// orNode возвращает node или fallback, если node не найден
+func orNode(node, fallback *yaml.Node) *yaml.Node {
+	if node != nil {
+		return node
+	}
+	return fallback
+}
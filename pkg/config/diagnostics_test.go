@@ -0,0 +1,281 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const checkHeader = `version: 1
+device: {vendor_id: 0x3434, product_id: 0x0331}
+
+`
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		ledCount int
+		want     []Diagnostic // Message сравнивается как подстрока
+	}{
+		{
+			name: "valid mono",
+			body: `colors:
+  - layout: us
+    color: blue
+  - layout: "*"
+    color: white
+`,
+		},
+		{
+			name: "duplicate layout and entry after wildcard",
+			body: `colors:
+  - layout: "*"
+    color: white
+  - layout: us
+    color: blue
+  - layout: us
+    color: red
+`,
+			want: []Diagnostic{
+				{SeverityWarning, 7, 13, `layout "us" is listed after "*" (line 5)`},
+				{SeverityError, 9, 13, `duplicate layout "us" is never used (first defined at line 7)`},
+			},
+		},
+		{
+			name: "collects several errors",
+			body: `mode: draw
+keyboard:
+  rows:
+    - [0, 1]
+    - [2, 3]
+draw:
+  - layout: us
+    stripes:
+      - rows: [5]
+        color: red
+  - layout: ru
+`,
+			want: []Diagnostic{
+				{SeverityWarning, 7, 7, "keyboard.rows[0] is not used by any drawing"},
+				{SeverityWarning, 8, 7, "keyboard.rows[1] is not used by any drawing"},
+				{SeverityError, 10, 5, "stripe[0]: invalid row 5"},
+				{SeverityError, 14, 5, "at least one stripe, grid, image or text is required"},
+			},
+		},
+		{
+			name: "leds set twice and outside rows",
+			body: `mode: draw
+keyboard:
+  rows:
+    - [0, 1]
+    - [2, 3]
+draw:
+  - layout: us
+    stripes:
+      - rows: [0, 1]
+        color: red
+      - leds: [1, 7]
+        color: blue
+`,
+			want: []Diagnostic{
+				{SeverityWarning, 14, 9, "leds [1] are already set by stripe[0]"},
+				{SeverityWarning, 14, 19, "led 7 is not in keyboard.rows"},
+			},
+		},
+		{
+			name: "unused rows",
+			body: `mode: draw
+keyboard:
+  rows:
+    - [0, 1]
+    - [2, 3]
+    - [4]
+draw:
+  - layout: us
+    stripes:
+      - rows: [0]
+        color: red
+      - leds: [3]
+        color: red
+`,
+			want: []Diagnostic{
+				{SeverityWarning, 9, 7, "keyboard.rows[2] is not used by any drawing"},
+			},
+		},
+		{
+			name:     "device led count",
+			ledCount: 3,
+			body: `mode: draw
+keyboard:
+  rows:
+    - [0, 1, 2, 3]
+draw:
+  - layout: us
+    stripes:
+      - rows: [0]
+        color: red
+      - leds: [5]
+        color: red
+`,
+			want: []Diagnostic{
+				{SeverityError, 7, 17, "led 3 is beyond the device LED count (3)"},
+				{SeverityError, 13, 16, "led 5 is beyond the device LED count (3)"},
+			},
+		},
+		{
+			name: "yaml type errors",
+			body: `colors:
+  - layout: us
+    color: "#zzz"
+`,
+			want: []Diagnostic{
+				{SeverityError, 6, 12, "invalid hex color #zzz"},
+			},
+		},
+		{
+			name: "decode errors do not hide other problems",
+			body: `watcher: bogus
+brightness: bright
+colors:
+  - layout: us
+    color: "#zz0000"
+  - layout: ru
+    color: blue
+  - layout: ru
+    color: "#zz"
+  - layout: ru
+    color: red
+`,
+			want: []Diagnostic{
+				{SeverityError, 4, 10, "unknown watcher: bogus"},
+				{SeverityError, 5, 0, "cannot unmarshal !!str `bright` into uint8"},
+				{SeverityError, 8, 12, "invalid hex color #zz0000"},
+				{SeverityError, 11, 13, `duplicate layout "ru" is never used (first defined at line 9)`},
+				{SeverityError, 12, 12, "invalid hex color #zz"},
+				{SeverityError, 13, 13, `duplicate layout "ru" is never used (first defined at line 9)`},
+			},
+		},
+		{
+			name: "decode error in one drawing",
+			body: `mode: draw
+keyboard:
+  rows:
+    - [0, 1]
+draw:
+  - layout: us
+    stripes:
+      - rows: [0]
+        color: nope
+  - layout: ru
+    stripes:
+      - rows: [3]
+        color: red
+`,
+			want: []Diagnostic{
+				{SeverityError, 12, 16, `unknown color "nope"`},
+				{SeverityError, 13, 5, "flag[1] (ru) stripe[0]: invalid row 3"},
+			},
+		},
+		{
+			name: "unknown watcher",
+			body: `watcher: bogus
+colors:
+  - layout: "*"
+    color: white
+`,
+			want: []Diagnostic{
+				{SeverityError, 4, 10, "unknown watcher: bogus"},
+			},
+		},
+		{
+			name: "external without source",
+			body: `watcher: external
+external:
+  command: []
+colors:
+  - layout: "*"
+    color: white
+`,
+			want: []Diagnostic{
+				{SeverityError, 5, 1, "requires external.command or external.pipe"},
+			},
+		},
+		{
+			name: "external without watcher",
+			body: `external:
+  pipe: /tmp/layout
+colors:
+  - layout: "*"
+    color: white
+`,
+			want: []Diagnostic{
+				{SeverityError, 4, 1, "external is only used with watcher: external"},
+			},
+		},
+		{
+			name: "syntax error",
+			body: `colors: [
+`,
+			want: []Diagnostic{
+				{SeverityError, 4, 0, "did not find expected node content"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(checkHeader+tt.body), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Check(path, tt.ledCount)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Check() returned %d diagnostics, want %d:\n%v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				d := got[i]
+				if d.Severity != want.Severity || d.Line != want.Line || d.Column != want.Column ||
+					!strings.Contains(d.Message, want.Message) {
+					t.Errorf("diagnostic[%d] = %v, want %v", i, d, want)
+				}
+			}
+			if HasErrors(got) != HasErrors(tt.want) {
+				t.Errorf("HasErrors() = %v", HasErrors(got))
+			}
+		})
+	}
+}
+
+func TestCheckMissingFile(t *testing.T) {
+	if _, err := Check(filepath.Join(t.TempDir(), "missing.yaml"), 0); err == nil {
+		t.Error("Check() expected error for missing file")
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	legacy := "device: {vendor_id: 0x3434, product_id: 0x0331}\ncolors:\n  - layout: \"*\"\n    color: white\n"
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Без version конфиг имеет версию 1, она же текущая
+	if got, err := Check(path, 0); err != nil || len(got) != 0 {
+		t.Errorf("Check() = %v, %v; want no diagnostics", got, err)
+	}
+
+	withMigrations(t, explicitMode)
+	got, err := Check(path, 0)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Severity != SeverityWarning || !strings.Contains(got[0].Message, "version 1 is outdated") {
+		t.Errorf("Check() = %v, want a warning about version 1", got)
+	}
+}
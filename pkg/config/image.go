@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	_ "image/jpeg" // регистрация декодера JPEG
+	_ "image/png"  // регистрация декодера PNG
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ImageFrame - кадр картинки для рисунка
+type ImageFrame struct {
+	Image image.Image
+	Delay time.Duration // длительность кадра (для анимированных GIF)
+}
+
+// loadImages загружает картинки всех рисунков
+// Относительные пути считаются от baseDir (директории конфига)
+func (c *Config) loadImages(baseDir string) error {
+	for i := range c.Drawings {
+		flag := &c.Drawings[i]
+		if flag.Image == "" {
+			continue
+		}
+
+		path := flag.Image
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		frames, err := LoadImage(path)
+		if err != nil {
+			return fmt.Errorf("flag[%d] (%s): %w", i, flag.LayoutSelector, err)
+		}
+		flag.Frames = frames
+	}
+	return nil
+}
+
+// LoadImage загружает картинку из файла
+// Для GIF возвращаются все кадры (уже наложенные друг на друга), для остальных форматов - один кадр
+func LoadImage(path string) ([]ImageFrame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".gif") {
+		g, err := gif.DecodeAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+		}
+		return gifFrames(g), nil
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+	}
+	return []ImageFrame{{Image: img}}, nil
+}
+
+// gifFrames собирает полные кадры GIF
+// Кадры GIF могут обновлять только часть картинки, поэтому каждый кадр
+// накладывается на предыдущее состояние холста с учётом disposal
+func gifFrames(g *gif.GIF) []ImageFrame {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewRGBA(bounds)
+	frames := make([]ImageFrame, 0, len(g.Image))
+
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, bounds.Min, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		snapshot := image.NewRGBA(bounds)
+		draw.Draw(snapshot, bounds, canvas, bounds.Min, draw.Src)
+
+		delay := time.Duration(0)
+		if i < len(g.Delay) {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+		frames = append(frames, ImageFrame{Image: snapshot, Delay: delay})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return frames
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadDrawImage(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 0, color.RGBA{B: 255, A: 255})
+
+	f, err := os.Create(filepath.Join(tmpDir, "logo.png"))
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	f.Close()
+
+	configPath := filepath.Join(tmpDir, "image.yaml")
+	configContent := `
+device:
+  vendor_id: 0x3434
+  product_id: 0x0331
+firmware: vial
+mode: draw
+keyboard:
+  rows: [[0, 1]]
+draw:
+  - layout: "*"
+    image: logo.png
+    sampling: area
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	flag := cfg.GetFlagForLayout("us")
+	if len(flag.Frames) != 1 {
+		t.Fatalf("len(Frames) = %d, want 1", len(flag.Frames))
+	}
+	if got := flag.Frames[0].Image.Bounds().Dx(); got != 2 {
+		t.Errorf("image width = %d, want 2", got)
+	}
+	if flag.Sampling != SamplingArea {
+		t.Errorf("Sampling = %s, want area", flag.Sampling)
+	}
+}
+
+func TestLoadDrawImageMissing(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "image.yaml")
+	configContent := `
+device:
+  vendor_id: 0x3434
+  product_id: 0x0331
+firmware: vial
+mode: draw
+keyboard:
+  rows: [[0, 1]]
+draw:
+  - layout: "*"
+    image: missing.png
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("Load() error = nil, want error for missing image")
+	}
+}
+
+func TestLoadImageGIF(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}}
+
+	// Кадр 1: вся картинка красная; кадр 2: зелёный только левый пиксель
+	frame1 := image.NewPaletted(image.Rect(0, 0, 2, 1), palette)
+	frame1.SetColorIndex(0, 0, 1)
+	frame1.SetColorIndex(1, 0, 1)
+	frame2 := image.NewPaletted(image.Rect(0, 0, 1, 1), palette)
+	frame2.SetColorIndex(0, 0, 2)
+
+	path := filepath.Join(t.TempDir(), "anim.gif")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create gif: %v", err)
+	}
+	err = gif.EncodeAll(f, &gif.GIF{
+		Image:  []*image.Paletted{frame1, frame2},
+		Delay:  []int{10, 20},
+		Config: image.Config{Width: 2, Height: 1, ColorModel: palette},
+	})
+	f.Close()
+	if err != nil {
+		t.Fatalf("failed to encode gif: %v", err)
+	}
+
+	frames, err := LoadImage(path)
+	if err != nil {
+		t.Fatalf("LoadImage() error = %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("len(frames) = %d, want 2", len(frames))
+	}
+	if frames[1].Delay != 200*time.Millisecond {
+		t.Errorf("frames[1].Delay = %v, want 200ms", frames[1].Delay)
+	}
+
+	// Второй кадр накладывается на первый: слева зелёный, справа остаётся красный
+	r, g, _, _ := frames[1].Image.At(0, 0).RGBA()
+	if r != 0 || g != 0xffff {
+		t.Errorf("frame 2 pixel 0 = r%d g%d, want green", r, g)
+	}
+	r, _, _, _ = frames[1].Image.At(1, 0).RGBA()
+	if r != 0xffff {
+		t.Errorf("frame 2 pixel 1 r = %d, want red from frame 1", r)
+	}
+}
@@ -0,0 +1,392 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jidckii/kolor-keyboard/keyboards"
+	"gopkg.in/yaml.v3"
+)
+
+// maxIncludeDepth - максимальная вложенность geometry и include
+const maxIncludeDepth = 8
+
+// catalog - встроенный каталог клавиатур, в котором ищутся ссылки geometry и include
+var catalog fs.FS = keyboards.FS
+
+// layoutLists - списки верхнего уровня, которые при слиянии объединяются по layout
+var layoutLists = map[string]bool{"colors": true, "draw": true}
+
+// StringList - одна строка или список строк
+type StringList []string
+
+// UnmarshalYAML принимает как "include: a.yaml", так и "include: [a.yaml, b.yaml]"
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// source - файл конфига на диске или во встроенном каталоге
+type source struct {
+	fsys fs.FS // nil - файл на диске
+	path string
+}
+
+func (s source) String() string {
+	if s.fsys != nil {
+		return "catalog:" + s.path
+	}
+	return s.path
+}
+
+// key - имя файла для поиска циклов (путь на диске приводится к абсолютному)
+func (s source) key() string {
+	if s.fsys == nil {
+		if abs, err := filepath.Abs(s.path); err == nil {
+			return abs
+		}
+	}
+	return s.String()
+}
+
+func (s source) read() ([]byte, error) {
+	if s.fsys != nil {
+		return fs.ReadFile(s.fsys, s.path)
+	}
+	return os.ReadFile(s.path)
+}
+
+// resolve ищет файл по ссылке ref из этого файла
+// Абсолютный путь берётся как есть, относительный ищется рядом с файлом, затем в каталоге.
+// Для каждого места пробуются ref, ref.yaml и ref/keyboard.yaml
+func (s source) resolve(ref string) (source, error) {
+	candidates := []string{ref, ref + ".yaml", ref + "/keyboard.yaml"}
+
+	if filepath.IsAbs(ref) {
+		for _, c := range candidates {
+			if isFile(nil, c) {
+				return source{path: c}, nil
+			}
+		}
+		return source{}, fmt.Errorf("file not found")
+	}
+
+	for _, c := range candidates {
+		if s.fsys != nil {
+			if p := path.Join(path.Dir(s.path), c); isFile(s.fsys, p) {
+				return source{fsys: s.fsys, path: p}, nil
+			}
+		} else if p := filepath.Join(filepath.Dir(s.path), filepath.FromSlash(c)); isFile(nil, p) {
+			return source{path: p}, nil
+		}
+	}
+	for _, c := range candidates {
+		if p := path.Clean(c); isFile(catalog, p) {
+			return source{fsys: catalog, path: p}, nil
+		}
+	}
+	return source{}, fmt.Errorf("not found next to %s or in the keyboard catalog", s)
+}
+
+// isFile сообщает, что по пути лежит обычный файл (fsys == nil - диск)
+func isFile(fsys fs.FS, name string) bool {
+	var info fs.FileInfo
+	var err error
+	if fsys == nil {
+		info, err = os.Stat(name)
+	} else {
+		if !fs.ValidPath(name) {
+			return false
+		}
+		info, err = fs.Stat(fsys, name)
+	}
+	return err == nil && info.Mode().IsRegular()
+}
+
+// parseNode разбирает YAML документ конфига и возвращает корневой mapping
+func parseNode(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nodeError(root, fmt.Errorf("config must be a YAML mapping"))
+	}
+	return root, nil
+}
+
+// loadNode читает файл конфига и собирает его вместе с geometry и include
+// Возвращает также файлы на диске, из которых собран конфиг (см. compose)
+func loadNode(path string) (*yaml.Node, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	root, files, err := prepareNode(data, source{path: path})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return root, files, nil
+}
+
+// prepareNode разбирает конфиг, обновляет его до текущей версии формата и подставляет geometry и include
+func prepareNode(data []byte, src source) (*yaml.Node, []string, error) {
+	root, err := parseNode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := Migrate(root); err != nil {
+		return nil, nil, err
+	}
+	return compose(root, src, nil)
+}
+
+// compose подставляет в конфиг файлы из geometry и include
+//
+// Порядок слияния (каждый следующий слой перекрывает предыдущие):
+//  1. geometry - описание клавиатуры
+//  2. include - по порядку списка
+//  3. сам файл
+//
+// Вложенные mapping сливаются по ключам, скаляры и списки заменяются целиком.
+// Исключение - colors и draw: записи с той же раскладкой заменяются на месте,
+// новые добавляются перед записью "*".
+// Ключи geometry и include остаются только у корневого файла.
+// Кроме конфига возвращает абсолютные пути файлов на диске, из которых он собран
+// (сам файл и подключённые, без встроенного каталога) - за ними следит Watch
+func compose(root *yaml.Node, src source, stack []string) (*yaml.Node, []string, error) {
+	stack = append(stack, src.key())
+	if len(stack) > maxIncludeDepth {
+		return nil, nil, fmt.Errorf("includes are nested deeper than %d levels", maxIncludeDepth)
+	}
+
+	var files []string
+	if src.fsys == nil {
+		files = append(files, src.key())
+	}
+	// Свои пути файла - до слияния, пока известно, откуда они
+	rebasePaths(root, src)
+
+	var refs []*yaml.Node
+	if node := mapValue(root, "geometry"); node != nil {
+		if node.Kind != yaml.ScalarNode || node.Value == "" {
+			return nil, nil, nodeError(node, fmt.Errorf("geometry must be a keyboard reference, e.g. keychron/v3/ansi_encoder"))
+		}
+		refs = append(refs, node)
+	}
+	if node := mapValue(root, "include"); node != nil {
+		var list StringList
+		if err := node.Decode(&list); err != nil {
+			return nil, nil, nodeError(node, fmt.Errorf("include must be a file or a list of files"))
+		}
+		switch node.Kind {
+		case yaml.ScalarNode:
+			refs = append(refs, node)
+		default:
+			refs = append(refs, node.Content...)
+		}
+	}
+	if len(refs) == 0 {
+		return root, files, nil
+	}
+
+	var merged *yaml.Node
+	for _, ref := range refs {
+		layer, layerFiles, err := includeLayer(src, ref.Value, stack)
+		if err != nil {
+			return nil, nil, nodeError(ref, fmt.Errorf("include %q: %w", ref.Value, err))
+		}
+		files = append(files, layerFiles...)
+		if merged == nil {
+			merged = layer
+		} else {
+			mergeMapping(merged, layer, layoutLists)
+		}
+	}
+	mergeMapping(merged, root, layoutLists)
+	merged.Line, merged.Column = root.Line, root.Column
+	return merged, files, nil
+}
+
+// includeLayer читает и собирает подключаемый файл
+// Позиции его узлов сбрасываются: номера строк другого файла в ошибках только запутают
+func includeLayer(from source, ref string, stack []string) (*yaml.Node, []string, error) {
+	src, err := from.resolve(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range stack {
+		if s == src.key() {
+			return nil, nil, fmt.Errorf("include cycle through %s", src)
+		}
+	}
+
+	data, err := src.read()
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := parseNode(data)
+	if err == nil {
+		_, err = Migrate(root)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", src, err)
+	}
+	// Ошибки типов проверяем до слияния, пока известны строки
+	var cfg Config
+	if err := root.Decode(&cfg); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", src, err)
+	}
+
+	root, files, err := compose(root, src, stack)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", src, err)
+	}
+	removeKeys(root, "geometry", "include")
+	clearPositions(root)
+	return root, files, nil
+}
+
+// rebasePaths делает относительные пути файла абсолютными от его каталога:
+// картинки (draw[].image), external.pipe и программу external.command с "/" в имени
+// Так пути из подключаемых файлов не зависят от того, где лежит корневой конфиг
+func rebasePaths(root *yaml.Node, src source) {
+	if src.fsys != nil {
+		return
+	}
+	dir := filepath.Dir(src.key())
+
+	if draw := mapValue(root, "draw"); draw != nil && draw.Kind == yaml.SequenceNode {
+		for _, item := range draw.Content {
+			rebasePath(mapValue(item, "image"), dir)
+		}
+	}
+
+	external := mapValue(root, "external")
+	if pipe := mapValue(external, "pipe"); pipe == nil || pipe.Value != "-" {
+		rebasePath(pipe, dir)
+	}
+	// Имя без "/" ищется в PATH
+	if program := seqItem(mapValue(external, "command"), 0); program != nil && strings.Contains(program.Value, "/") {
+		rebasePath(program, dir)
+	}
+}
+
+// rebasePath делает относительный путь в скалярном узле абсолютным от каталога dir
+func rebasePath(node *yaml.Node, dir string) {
+	if node != nil && node.Kind == yaml.ScalarNode && node.Value != "" && !filepath.IsAbs(node.Value) {
+		node.Value = filepath.Join(dir, filepath.FromSlash(node.Value))
+	}
+}
+
+// mergeMapping сливает src в dst (src перекрывает dst)
+// lists - ключи списков, которые объединяются по layout (только на верхнем уровне)
+func mergeMapping(dst, src *yaml.Node, lists map[string]bool) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+
+		j := mapIndex(dst, key.Value)
+		if j < 0 {
+			dst.Content = append(dst.Content, key, value)
+			continue
+		}
+
+		old := dst.Content[j+1]
+		switch {
+		case old.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeMapping(old, value, nil)
+		case lists[key.Value] && old.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			mergeLayouts(old, value)
+		default:
+			dst.Content[j] = key
+			dst.Content[j+1] = value
+		}
+	}
+}
+
+// mergeLayouts объединяет списки colors/draw: записи с теми же условиями выбора раскладки
+// заменяются, новые вставляются перед "*", чтобы запасной вариант оставался последним
+func mergeLayouts(dst, src *yaml.Node) {
+	index := make(map[string]int)
+	for i, item := range dst.Content {
+		if sel, ok := itemSelector(item); ok {
+			if _, ok := index[sel.key()]; !ok {
+				index[sel.key()] = i
+			}
+		}
+	}
+
+	var added []*yaml.Node
+	for _, item := range src.Content {
+		if sel, ok := itemSelector(item); ok {
+			if i, ok := index[sel.key()]; ok {
+				dst.Content[i] = item
+				// Повторы внутри одного файла не схлопываются, их найдёт Check
+				delete(index, sel.key())
+				continue
+			}
+		}
+		added = append(added, item)
+	}
+
+	at := len(dst.Content)
+	for i, item := range dst.Content {
+		if sel, ok := itemSelector(item); ok && sel.IsWildcard() {
+			at = i
+			break
+		}
+	}
+	dst.Content = append(dst.Content[:at], append(added, dst.Content[at:]...)...)
+}
+
+// itemSelector читает условия выбора раскладки из записи colors/draw
+func itemSelector(item *yaml.Node) (LayoutSelector, bool) {
+	var sel LayoutSelector
+	if item.Kind != yaml.MappingNode || item.Decode(&sel) != nil {
+		return sel, false
+	}
+	return sel, sel.Validate() == nil
+}
+
+// mapIndex возвращает индекс ключа в Content узла mapping (-1 если ключа нет)
+func mapIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeKeys удаляет ключи из узла mapping
+func removeKeys(node *yaml.Node, keys ...string) {
+	for _, key := range keys {
+		if i := mapIndex(node, key); i >= 0 {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		}
+	}
+}
+
+// clearPositions обнуляет строки и колонки узла и всех вложенных узлов
+func clearPositions(node *yaml.Node) {
+	node.Line, node.Column = 0, 0
+	for _, child := range node.Content {
+		clearPositions(child)
+	}
+}
@@ -0,0 +1,402 @@
+package config
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jidckii/kolor-keyboard/keyboards"
+)
+
+// writeFiles создаёт файлы во временном каталоге и возвращает его путь
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadIncludes(t *testing.T) {
+	testCatalog := fstest.MapFS{
+		"acme/k1/ansi/keyboard.yaml": {Data: []byte(`device:
+  vendor_id: 0x1234
+  product_id: 0x0001
+  usage_page: 0xFF60
+  usage: 0x61
+keyboard:
+  rows:
+    - [0, 1, 2]
+    - [3, 4, 5]
+`)},
+		"acme/k1/ansi/flags.yaml": {Data: []byte(`geometry: acme/k1/ansi
+mode: draw
+draw:
+  - layout: "*"
+    stripes:
+      - rows: [0, 1]
+        color: green
+`)},
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		check   func(t *testing.T, cfg *Config)
+		wantErr string
+	}{
+		{
+			name: "geometry from catalog",
+			files: map[string]string{"config.yaml": `geometry: acme/k1/ansi
+colors:
+  - layout: us
+    color: blue
+`},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Device.VendorID != 0x1234 || cfg.Device.UsagePage != 0xFF60 {
+					t.Errorf("device = %+v, want the catalog device", cfg.Device)
+				}
+				if len(cfg.Keyboard.Rows) != 2 {
+					t.Errorf("rows = %v, want 2 rows", cfg.Keyboard.Rows)
+				}
+				if cfg.Geometry != "acme/k1/ansi" {
+					t.Errorf("Geometry = %q", cfg.Geometry)
+				}
+			},
+		},
+		{
+			name: "local file shadows catalog",
+			files: map[string]string{
+				"acme/k1/ansi.yaml": `device: {vendor_id: 0x4321, product_id: 0x0002}`,
+				"config.yaml": `geometry: acme/k1/ansi
+colors: [{layout: us, color: blue}]
+`,
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Device.VendorID != 0x4321 {
+					t.Errorf("VendorID = %#x, want the local 0x4321", cfg.Device.VendorID)
+				}
+			},
+		},
+		{
+			name: "merge order",
+			files: map[string]string{
+				"base.yaml": `brightness: 100
+speed: 10
+colors:
+  - layout: us
+    color: red
+  - layout: "*"
+    color: green
+`,
+				"extra/overlay.yaml": `speed: 20
+colors:
+  - layout: ru
+    color: white
+`,
+				"config.yaml": `geometry: acme/k1/ansi
+include: [base.yaml, extra/overlay]
+device:
+  calibration: {gamma: 2}
+colors:
+  - layout: us
+    color: blue
+`,
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if *cfg.Brightness != 100 || *cfg.Speed != 20 {
+					t.Errorf("brightness, speed = %d, %d, want 100, 20", *cfg.Brightness, *cfg.Speed)
+				}
+				if want := []string{"us", "ru", "*"}; !slices.Equal(cfg.Layouts(), want) {
+					t.Errorf("Layouts() = %v, want %v", cfg.Layouts(), want)
+				}
+				if c := cfg.GetColorForLayout("us"); *c != (RGBColor{B: 255}) {
+					t.Errorf("us color = %v, want the color from config.yaml", *c)
+				}
+				if cfg.Device.VendorID != 0x1234 || cfg.Device.Calibration == nil {
+					t.Errorf("device = %+v, want catalog device with local calibration", cfg.Device)
+				}
+			},
+		},
+		{
+			name: "nested catalog include",
+			files: map[string]string{"config.yaml": `include: acme/k1/ansi/flags.yaml
+draw:
+  - layout: us
+    stripes:
+      - rows: [0]
+        color: blue
+`},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Mode != ModeDraw || cfg.Device.VendorID != 0x1234 {
+					t.Errorf("mode = %s, device = %+v", cfg.Mode, cfg.Device)
+				}
+				if want := []string{"us", "*"}; !slices.Equal(cfg.Layouts(), want) {
+					t.Errorf("Layouts() = %v, want %v", cfg.Layouts(), want)
+				}
+			},
+		},
+		{
+			name:    "missing include",
+			files:   map[string]string{"config.yaml": "include: nope.yaml\n"},
+			wantErr: `line 1, column 10: include "nope.yaml": not found`,
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"a.yaml":      "include: config.yaml\n",
+				"config.yaml": "include: a.yaml\n",
+			},
+			wantErr: "include cycle",
+		},
+		{
+			name: "error in included file",
+			files: map[string]string{
+				"colors.yaml": "colors:\n  - layout: us\n    color: nocolor\n",
+				"config.yaml": "geometry: acme/k1/ansi\ninclude: colors.yaml\n",
+			},
+			wantErr: "colors.yaml: line 3, column 12: unknown color",
+		},
+		{
+			name:    "geometry must be a string",
+			files:   map[string]string{"config.yaml": "geometry: [a, b]\n"},
+			wantErr: "geometry must be a keyboard reference",
+		},
+	}
+
+	saved := catalog
+	catalog = testCatalog
+	t.Cleanup(func() { catalog = saved })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			cfg, err := Load(filepath.Join(dir, "config.yaml"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+// TestLoadIncludePaths проверяет пути картинок подключаемых файлов и список файлов конфига
+func TestLoadIncludePaths(t *testing.T) {
+	var data bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	if err := png.Encode(&data, img); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := writeFiles(t, map[string]string{
+		"shared/drawings.yaml": `include: nested/inner.yaml
+draw:
+  - layout: us
+    image: logo.png
+`,
+		"shared/logo.png": data.String(),
+		"shared/nested/inner.yaml": `draw:
+  - layout: ru
+    image: ../logo.png
+  - layout: de
+    image: inner.png
+`,
+		"shared/nested/inner.png": data.String(),
+		"config.yaml": `geometry: keychron/v3/ansi_encoder
+include: shared/drawings.yaml
+mode: draw
+draw:
+  - layout: "*"
+    stripes:
+      - rows: [0]
+        color: white
+`,
+	})
+
+	cfg, err := Load(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	images := map[string]string{
+		"us": filepath.Join(dir, "shared", "logo.png"),
+		"ru": filepath.Join(dir, "shared", "logo.png"),
+		"de": filepath.Join(dir, "shared", "nested", "inner.png"),
+	}
+	for layout, want := range images {
+		flag := cfg.GetFlagForLayout(layout)
+		if flag.Image != want || len(flag.Frames) != 1 {
+			t.Errorf("%s: image = %q with %d frames, want %q", layout, flag.Image, len(flag.Frames), want)
+		}
+	}
+
+	// Встроенный каталог (geometry) на диске не лежит и в список не входит
+	want := []string{
+		filepath.Join(dir, "config.yaml"),
+		filepath.Join(dir, "shared", "drawings.yaml"),
+		filepath.Join(dir, "shared", "nested", "inner.yaml"),
+	}
+	if !slices.Equal(cfg.Files(), want) {
+		t.Errorf("Files() = %v, want %v", cfg.Files(), want)
+	}
+}
+
+func TestLoadExternalPaths(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantPipe    string // относительно каталога теста
+		wantCommand string
+	}{
+		{
+			name:     "pipe next to the config",
+			files:    map[string]string{"config.yaml": "external: {pipe: run/layout.fifo}\n"},
+			wantPipe: "run/layout.fifo",
+		},
+		{
+			name:  "stdin",
+			files: map[string]string{"config.yaml": "external: {pipe: \"-\"}\n"},
+		},
+		{
+			name: "pipe from an included file",
+			files: map[string]string{
+				"shared/external.yaml": "external: {pipe: layout.fifo}\n",
+				"config.yaml":          "include: shared/external.yaml\n",
+			},
+			wantPipe: "shared/layout.fifo",
+		},
+		{
+			name:        "command with a path",
+			files:       map[string]string{"config.yaml": "external: {command: [./bin/layout-source, --follow]}\n"},
+			wantCommand: "bin/layout-source",
+		},
+		{
+			name:  "command from PATH",
+			files: map[string]string{"config.yaml": "external: {command: [layout-source]}\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			root, _, err := loadNode(filepath.Join(dir, "config.yaml"))
+			if err != nil {
+				t.Fatalf("loadNode() error = %v", err)
+			}
+			var cfg Config
+			if err := root.Decode(&cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			wantPipe, wantCommand := "-", "layout-source"
+			if tt.wantPipe != "" {
+				wantPipe = filepath.Join(dir, filepath.FromSlash(tt.wantPipe))
+			}
+			if tt.wantCommand != "" {
+				wantCommand = filepath.Join(dir, filepath.FromSlash(tt.wantCommand))
+			}
+			switch {
+			case len(cfg.External.Command) > 0:
+				if cfg.External.Command[0] != wantCommand {
+					t.Errorf("command = %q, want %q", cfg.External.Command[0], wantCommand)
+				}
+			case cfg.External.Pipe != wantPipe:
+				t.Errorf("pipe = %q, want %q", cfg.External.Pipe, wantPipe)
+			}
+		})
+	}
+}
+
+func TestMergeLayouts(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  string
+		src  string
+		want []string
+	}{
+		{"replace in place", "[{layout: us, n: 1}, {layout: ru, n: 1}]", "[{layout: us, n: 2}]", []string{"us:2", "ru:1"}},
+		{"append", "[{layout: us, n: 1}]", "[{layout: ru, n: 2}]", []string{"us:1", "ru:2"}},
+		{"insert before wildcard", "[{layout: us, n: 1}, {layout: '*', n: 1}]", "[{layout: ru, n: 2}, {layout: '*', n: 2}]",
+			[]string{"us:1", "ru:2", "*:2"}},
+		{"same selector in another form", "[{layout: us(dvorak), n: 1}]", "[{layout: us, variant: dvorak, n: 2}]", []string{"us:2"}},
+		{"duplicates are kept", "[{layout: us, n: 1}]", "[{layout: us, n: 2}, {layout: us, n: 3}]", []string{"us:2", "us:3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, err := parseNode([]byte("colors: " + tt.dst))
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := parseNode([]byte("colors: " + tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			mergeMapping(dst, src, layoutLists)
+
+			var got []string
+			for _, item := range mapValue(dst, "colors").Content {
+				got = append(got, mapValue(item, "layout").Value+":"+mapValue(item, "n").Value)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("merged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCatalogConfigs проверяет, что все конфиги каталога загружаются
+func TestCatalogConfigs(t *testing.T) {
+	err := fs.WalkDir(keyboards.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == "keyboard.yaml" {
+			return err
+		}
+		t.Run(path, func(t *testing.T) {
+			if _, err := Load(filepath.Join("..", "..", "keyboards", filepath.FromSlash(path))); err != nil {
+				t.Errorf("Load() error = %v", err)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml": "colors:\n  - layout: \"*\"\n    color: white\n",
+		"config.yaml": `version: 1
+geometry: keychron/v3/ansi_encoder
+include: [base.yaml, missing.yaml]
+`,
+	})
+
+	diags, err := Check(filepath.Join(dir, "config.yaml"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Line != 3 || !strings.Contains(diags[0].Message, `include "missing.yaml"`) {
+		t.Errorf("Check() = %v, want one error at line 3 about missing.yaml", diags)
+	}
+}
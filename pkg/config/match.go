@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ActiveLayout - раскладка, для которой ищется цвет или рисунок
+type ActiveLayout struct {
+	Layout  string // Код XKB ("us", "ru")
+	Variant string // Вариант XKB ("dvorak", "" - основной)
+	Name    string // Отображаемое имя ("English (Dvorak)")
+	Index   int    // Позиция в списке раскладок (-1 - неизвестна)
+	IME     bool   // Метод ввода включён (IBus, Fcitx5)
+}
+
+// IMEState - состояние метода ввода в условиях выбора раскладки
+type IMEState string
+
+const (
+	IMEActive   IMEState = "active"   // ввод через движок (Mozc, Pinyin)
+	IMEInactive IMEState = "inactive" // прямой ввод раскладкой
+)
+
+// ParseLayout разбирает раскладку в записи "us" или "us(dvorak)"
+func ParseLayout(s string) ActiveLayout {
+	l := ActiveLayout{Layout: s, Index: -1}
+	if code, variant, ok := strings.Cut(s, "("); ok && strings.HasSuffix(variant, ")") {
+		l.Layout, l.Variant = code, strings.TrimSuffix(variant, ")")
+	}
+	return l
+}
+
+// String возвращает раскладку в записи "us" или "us(dvorak)"
+func (l ActiveLayout) String() string {
+	if l.Variant == "" {
+		return l.Layout
+	}
+	return l.Layout + "(" + l.Variant + ")"
+}
+
+// LayoutSelector - условия, по которым запись colors или draw выбирается для раскладки
+// Все указанные условия должны выполняться одновременно.
+// layout, variant и name могут быть шаблонами: "de*", "English*" (см. path.Match)
+type LayoutSelector struct {
+	Layout  string   `yaml:"layout,omitempty"`  // "us", "us(dvorak)", "de*" или "*" для любой раскладки
+	Variant string   `yaml:"variant,omitempty"` // вариант XKB
+	Name    string   `yaml:"name,omitempty"`    // отображаемое имя раскладки
+	Index   *int     `yaml:"index,omitempty"`   // позиция в списке раскладок (с 0)
+	IME     IMEState `yaml:"ime,omitempty"`     // состояние метода ввода
+}
+
+// Очки специфичности условий: побеждает запись с наибольшей суммой,
+// при равенстве - первая в конфиге
+const (
+	scoreIndex       = 16 // index
+	scoreNameExact   = 8  // name без шаблона
+	scoreExact       = 4  // layout или variant без шаблона
+	scoreIME         = 3  // ime (слабее кода: layout: mozc-jp точнее, чем ime: active)
+	scoreNamePattern = 2  // name с шаблоном
+	scorePattern     = 1  // layout или variant с шаблоном
+	// layout: "*" не добавляет очков
+)
+
+// isPattern сообщает, что значение - шаблон, а не точное имя
+func isPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// code возвращает код раскладки и вариант из поля layout ("us(dvorak)" -> "us", "dvorak")
+func (s *LayoutSelector) code() (string, string) {
+	l := ParseLayout(s.Layout)
+	if s.Variant != "" {
+		l.Variant = s.Variant
+	}
+	return l.Layout, l.Variant
+}
+
+// String описывает условия для логов и сообщений: "us(dvorak)", "name=English*", "index=1"
+func (s LayoutSelector) String() string {
+	var parts []string
+	if s.Layout != "" {
+		parts = append(parts, s.Layout)
+	}
+	if s.Variant != "" {
+		parts = append(parts, "variant="+s.Variant)
+	}
+	if s.Name != "" {
+		parts = append(parts, "name="+s.Name)
+	}
+	if s.Index != nil {
+		parts = append(parts, "index="+strconv.Itoa(*s.Index))
+	}
+	if s.IME != "" {
+		parts = append(parts, "ime="+string(s.IME))
+	}
+	return strings.Join(parts, " ")
+}
+
+// key - условия в нормализованном виде ("us(dvorak)" и layout: us + variant: dvorak совпадают)
+func (s *LayoutSelector) key() string {
+	code, variant := s.code()
+	index := ""
+	if s.Index != nil {
+		index = strconv.Itoa(*s.Index)
+	}
+	return strings.Join([]string{code, variant, s.Name, index, string(s.IME)}, "\x00")
+}
+
+// IsWildcard сообщает, что запись подходит для любой раскладки (layout: "*" без других условий)
+func (s *LayoutSelector) IsWildcard() bool {
+	return s.Layout == "*" && s.Variant == "" && s.Name == "" && s.Index == nil && s.IME == ""
+}
+
+// Validate проверяет условия выбора раскладки
+func (s *LayoutSelector) Validate() error {
+	if s.Layout == "" && s.Variant == "" && s.Name == "" && s.Index == nil && s.IME == "" {
+		return fmt.Errorf("layout, variant, name, index or ime is required")
+	}
+	if s.IME != "" && s.IME != IMEActive && s.IME != IMEInactive {
+		return fmt.Errorf("unknown ime state %q (expected %s or %s)", s.IME, IMEActive, IMEInactive)
+	}
+	if s.Variant != "" && ParseLayout(s.Layout).Variant != "" {
+		return fmt.Errorf("layout %q already has a variant, remove variant: %s", s.Layout, s.Variant)
+	}
+	if s.Index != nil && *s.Index < 0 {
+		return fmt.Errorf("index must not be negative")
+	}
+
+	code, variant := s.code()
+	for _, p := range []string{code, variant, s.Name} {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", p)
+		}
+	}
+	return nil
+}
+
+// Match проверяет, подходит ли запись для раскладки, и возвращает её специфичность
+func (s *LayoutSelector) Match(l ActiveLayout) (int, bool) {
+	code, variant := s.code()
+	score := 0
+	matched := true
+
+	add := func(pattern, value string, exact, glob int) {
+		if pattern == "" || !matched {
+			return
+		}
+		if !isPattern(pattern) {
+			matched = pattern == value
+			score += exact
+			return
+		}
+		ok, err := path.Match(pattern, value)
+		matched = ok && err == nil
+		score += glob
+	}
+
+	if code != "*" {
+		add(code, l.Layout, scoreExact, scorePattern)
+	}
+	add(variant, l.Variant, scoreExact, scorePattern)
+	add(s.Name, l.Name, scoreNameExact, scoreNamePattern)
+	if s.Index != nil {
+		matched = matched && *s.Index == l.Index
+		score += scoreIndex
+	}
+	if s.IME != "" {
+		matched = matched && (s.IME == IMEActive) == l.IME
+		score += scoreIME
+	}
+
+	if !matched {
+		return 0, false
+	}
+	return score, true
+}
+
+// bestMatch возвращает номер записи с наибольшей специфичностью (-1 - подходящих нет)
+// Общий порядок выбора для всех режимов
+func bestMatch(n int, selector func(i int) *LayoutSelector, l ActiveLayout) int {
+	best, bestScore := -1, -1
+	for i := 0; i < n; i++ {
+		score, ok := selector(i).Match(l)
+		if ok && score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
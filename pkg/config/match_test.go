@@ -0,0 +1,213 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseLayout(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ActiveLayout
+	}{
+		{"us", ActiveLayout{Layout: "us", Index: -1}},
+		{"us(dvorak)", ActiveLayout{Layout: "us", Variant: "dvorak", Index: -1}},
+		{"de(nodeadkeys)", ActiveLayout{Layout: "de", Variant: "nodeadkeys", Index: -1}},
+		{"us(dvorak", ActiveLayout{Layout: "us(dvorak", Index: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := ParseLayout(tt.input)
+			if got != tt.want {
+				t.Errorf("ParseLayout() = %+v, want %+v", got, tt.want)
+			}
+			if tt.want.Variant != "" && got.String() != tt.input {
+				t.Errorf("String() = %q, want %q", got.String(), tt.input)
+			}
+		})
+	}
+}
+
+func TestColorFor(t *testing.T) {
+	cfg := mustParse(t, `
+colors:
+  - layout: "*"
+    color: white
+  - layout: us
+    color: blue
+  - layout: us(dvorak)
+    color: orange
+  - layout: de*
+    color: yellow
+  - layout: de
+    color: black
+  - name: "English*"
+    color: green
+  - name: "English (Colemak)"
+    color: purple
+  - index: 3
+    color: red
+  - layout: us
+    variant: colemak
+    index: 4
+    color: cyan
+`)
+
+	tests := []struct {
+		name   string
+		layout ActiveLayout
+		want   string
+	}{
+		{"wildcard only", ActiveLayout{Layout: "fr", Index: 0}, "white"},
+		{"name pattern", ActiveLayout{Layout: "gb", Name: "English (UK)", Index: 0}, "green"},
+		{"exact layout beats name pattern", ActiveLayout{Layout: "us", Name: "English (US)", Index: 0}, "blue"},
+		{"variant beats layout", ActiveLayout{Layout: "us", Variant: "dvorak", Index: 0}, "orange"},
+		{"exact name beats variant", ActiveLayout{Layout: "us", Variant: "colemak", Name: "English (Colemak)", Index: 0}, "purple"},
+		{"glob layout", ActiveLayout{Layout: "dev", Index: 0}, "yellow"},
+		{"exact beats glob", ActiveLayout{Layout: "de", Variant: "nodeadkeys", Index: 0}, "black"},
+		{"index beats everything", ActiveLayout{Layout: "us", Variant: "dvorak", Name: "English (Colemak)", Index: 3}, "red"},
+		{"all conditions must hold", ActiveLayout{Layout: "us", Variant: "colemak", Index: 4}, "cyan"},
+		{"unknown index", ActiveLayout{Layout: "ru", Index: -1}, "white"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := ParseColor(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := cfg.ColorFor(tt.layout)
+			if got == nil || *got != want {
+				t.Errorf("ColorFor(%+v) = %v, want %s", tt.layout, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorForTie(t *testing.T) {
+	cfg := mustParse(t, `
+colors:
+  - layout: "u*"
+    color: red
+  - layout: "*s"
+    color: blue
+`)
+	if got := cfg.GetColorForLayout("us"); got == nil || *got != (RGBColor{R: 255}) {
+		t.Errorf("GetColorForLayout() = %v, want the first entry", got)
+	}
+	if got := cfg.GetColorForLayout("ru"); got != nil {
+		t.Errorf("GetColorForLayout(ru) = %v, want nil", got)
+	}
+}
+
+func TestColorForIME(t *testing.T) {
+	cfg := mustParse(t, `
+colors:
+  - layout: "*"
+    color: white
+  - ime: active
+    color: red
+  - layout: mozc-jp
+    color: green
+  - layout: mozc-jp
+    ime: inactive
+    color: blue
+`)
+
+	tests := []struct {
+		name   string
+		layout ActiveLayout
+		want   string
+	}{
+		{"plain layout", ActiveLayout{Layout: "us"}, "white"},
+		{"any engine on", ActiveLayout{Layout: "pinyin", IME: true}, "red"},
+		{"engine by name", ActiveLayout{Layout: "mozc-jp", IME: true}, "green"},
+		{"engine in direct input", ActiveLayout{Layout: "mozc-jp"}, "blue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := ParseColor(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.ColorFor(tt.layout); got == nil || *got != want {
+				t.Errorf("ColorFor(%+v) = %v, want %s", tt.layout, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUncovered(t *testing.T) {
+	cfg := mustParse(t, `
+mode: mono
+colors:
+  - layout: us
+    color: blue
+  - name: "Russian*"
+    color: white
+`)
+	layouts := []ActiveLayout{
+		{Layout: "us", Name: "English (US)", Index: 0},
+		{Layout: "ru", Variant: "phonetic", Name: "Russian (phonetic)", Index: 1},
+		{Layout: "de", Name: "German", Index: 2},
+	}
+
+	missing, wildcard := cfg.Uncovered(layouts)
+	if len(missing) != 1 || missing[0] != layouts[2] || len(wildcard) != 0 {
+		t.Errorf("Uncovered() = %v, %v, want only de missing", missing, wildcard)
+	}
+
+	cfg.Colors = append(cfg.Colors, ColorMapping{LayoutSelector: LayoutSelector{Layout: "*"}})
+	missing, wildcard = cfg.Uncovered(layouts)
+	if len(missing) != 0 || len(wildcard) != 1 || wildcard[0] != layouts[2] {
+		t.Errorf("Uncovered() with \"*\" = %v, %v, want de covered by \"*\" only", missing, wildcard)
+	}
+
+	// В режиме draw проверяются записи draw
+	cfg.Mode = ModeDraw
+	if missing, _ = cfg.Uncovered(layouts); len(missing) != len(layouts) {
+		t.Errorf("Uncovered() in draw mode = %v, want all layouts missing", missing)
+	}
+}
+
+func TestLayoutSelectorValidate(t *testing.T) {
+	index := func(i int) *int { return &i }
+	tests := []struct {
+		name    string
+		sel     LayoutSelector
+		wantErr bool
+	}{
+		{"layout", LayoutSelector{Layout: "us"}, false},
+		{"variant in layout", LayoutSelector{Layout: "us(dvorak)"}, false},
+		{"name only", LayoutSelector{Name: "English*"}, false},
+		{"index only", LayoutSelector{Index: index(0)}, false},
+		{"empty", LayoutSelector{}, true},
+		{"double variant", LayoutSelector{Layout: "us(dvorak)", Variant: "colemak"}, true},
+		{"negative index", LayoutSelector{Index: index(-1)}, true},
+		{"bad pattern", LayoutSelector{Name: "English["}, true},
+		{"ime only", LayoutSelector{IME: IMEActive}, false},
+		{"unknown ime state", LayoutSelector{IME: "on"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sel.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// mustParse разбирает конфиг без проверки устройства
+func mustParse(t *testing.T, data string) *Config {
+	t.Helper()
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return &cfg
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion - текущая версия формата конфига
+// Файл без version считается версией 1
+const CurrentVersion = 1
+
+// Migration - шаг обновления документа с версии From на From+1
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(root *yaml.Node) error
+}
+
+// migrations - шаги обновления по порядку версий, i-й шаг обновляет версию i+1
+// При изменении формата добавьте шаг сюда и увеличьте CurrentVersion
+var migrations = []Migration{}
+
+// latestVersion возвращает версию, до которой обновляют шаги из migrations (равна CurrentVersion)
+func latestVersion() int {
+	return len(migrations) + 1
+}
+
+// documentVersion возвращает версию формата документа
+func documentVersion(root *yaml.Node) (int, error) {
+	node := mapValue(root, "version")
+	if node == nil {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(node.Value)
+	if node.Kind != yaml.ScalarNode || err != nil || version < 1 {
+		return 0, nodeError(node, fmt.Errorf("version must be a positive integer"))
+	}
+	if latest := latestVersion(); version > latest {
+		return 0, nodeError(node, fmt.Errorf("config version %d is newer than supported %d, update kolor-keyboard",
+			version, latest))
+	}
+	return version, nil
+}
+
+// Migrate обновляет документ до CurrentVersion и возвращает выполненные шаги
+// root - корневой mapping документа, изменяется на месте (комментарии сохраняются)
+func Migrate(root *yaml.Node) ([]Migration, error) {
+	version, err := documentVersion(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.From < version {
+			continue
+		}
+		if err := m.Apply(root); err != nil {
+			return applied, fmt.Errorf("migration from version %d: %w", m.From, err)
+		}
+		version = m.From + 1
+		applied = append(applied, m)
+	}
+
+	if len(applied) > 0 {
+		setKey(root, "version", strconv.Itoa(version), "")
+	}
+	return applied, nil
+}
+
+// MigrateFile обновляет файл конфига на месте
+// Комментарии, пустые строки и выравнивание неизменённых строк сохраняются.
+// Подключённые через geometry и include файлы не изменяются.
+// Возвращает выполненные шаги и новое содержимое (шагов нет - файл не тронут)
+func MigrateFile(path string, dryRun bool) ([]Migration, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("failed to parse config: config must be a YAML mapping")
+	}
+
+	applied, err := Migrate(doc.Content[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to migrate config: %w", err)
+	}
+	if len(applied) == 0 {
+		return nil, data, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	out := restoreLayout(data, buf.Bytes())
+
+	if dryRun {
+		return applied, out, nil
+	}
+	if err := writeFileAtomic(path, out); err != nil {
+		return nil, nil, err
+	}
+	return applied, out, nil
+}
+
+// setKey задаёт скалярное значение ключа в mapping
+// Новый ключ вставляется после ключа after ("" - в начало)
+func setKey(root *yaml.Node, key, value, after string) {
+	if node := mapValue(root, key); node != nil {
+		node.Kind, node.Tag, node.Value, node.Style = yaml.ScalarNode, "", value, 0
+		return
+	}
+
+	at := 0
+	if i := mapIndex(root, after); after != "" && i >= 0 {
+		at = i + 2
+	}
+	pair := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		{Kind: yaml.ScalarNode, Value: value},
+	}
+	root.Content = append(root.Content[:at], append(pair, root.Content[at:]...)...)
+}
+
+// layoutWindow - на сколько строк вперёд ищется совпадение строки исходного файла
+const layoutWindow = 8
+
+// restoreLayout возвращает в перекодированный YAML пустые строки и выравнивание исходного файла
+// yaml.v3 сохраняет комментарии, но теряет пустые строки и пробелы перед комментариями в строке.
+// Строки, которые не изменились (с точностью до пробелов), берутся из исходного файла как есть
+func restoreLayout(original, encoded []byte) []byte {
+	orig := strings.Split(string(original), "\n")
+	out := strings.Split(string(encoded), "\n")
+	blankBefore := make([]bool, len(out)+1)
+
+	next := 0 // первая строка out после последнего совпадения
+	for i, line := range orig {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key := normalizeLine(line)
+		for k := next; k < len(out) && k < next+layoutWindow; k++ {
+			if normalizeLine(out[k]) != key {
+				continue
+			}
+			out[k] = line
+			// Пустая строка ставится перед добавленными строками, а не между ними и совпавшей
+			if i > 0 && strings.TrimSpace(orig[i-1]) == "" && next > 0 &&
+				strings.TrimSpace(out[next-1]) != "" && strings.TrimSpace(out[next]) != "" {
+				blankBefore[next] = true
+			}
+			next = k + 1
+			break
+		}
+	}
+
+	var sb strings.Builder
+	for k, line := range out {
+		if blankBefore[k] {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(line)
+		if k < len(out)-1 {
+			sb.WriteString("\n")
+		}
+	}
+	return []byte(sb.String())
+}
+
+// normalizeLine сжимает пробелы внутри строки, сохраняя отступ
+func normalizeLine(line string) string {
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	return line[:indent] + strings.Join(strings.Fields(line), " ")
+}
+
+// writeFileAtomic записывает файл через временный файл и rename с прежними правами
+// Символическая ссылка сохраняется: записывается файл, на который она указывает
+func writeFileAtomic(path string, data []byte) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "without version",
+			input: "device: {vendor_id: 1, product_id: 2}\ncolors: []\n",
+		},
+		{
+			name:  "current version",
+			input: "version: 1\ndevice: {vendor_id: 1, product_id: 2}\ncolors: []\n",
+		},
+		{
+			name:    "newer version",
+			input:   "version: 2\n",
+			wantErr: "line 1, column 10: config version 2 is newer than supported 1",
+		},
+		{
+			name:    "invalid version",
+			input:   "version: two\n",
+			wantErr: "version must be a positive integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.input), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			applied, err := Migrate(doc.Content[0])
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Migrate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+			if len(applied) != 0 {
+				t.Errorf("Migrate() applied %d steps, want none", len(applied))
+			}
+
+			out, err := yaml.Marshal(&doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.input {
+				t.Errorf("Migrate() result:\n%s\nwant:\n%s", out, tt.input)
+			}
+		})
+	}
+}
+
+func TestMigrationsMatchCurrentVersion(t *testing.T) {
+	for i, m := range migrations {
+		if m.From != i+1 {
+			t.Errorf("migrations[%d].From = %d, want %d", i, m.From, i+1)
+		}
+	}
+	if latestVersion() != CurrentVersion {
+		t.Errorf("migrations upgrade to version %d, CurrentVersion = %d", latestVersion(), CurrentVersion)
+	}
+}
+
+// withMigrations подменяет шаги обновления на время теста
+func withMigrations(t *testing.T, steps ...Migration) {
+	t.Helper()
+	saved := migrations
+	migrations = steps
+	t.Cleanup(func() { migrations = saved })
+}
+
+func TestMigrateSteps(t *testing.T) {
+	var order []int
+	step := func(from int) Migration {
+		return Migration{From: from, Apply: func(root *yaml.Node) error {
+			order = append(order, from)
+			return nil
+		}}
+	}
+
+	withMigrations(t, step(1), step(2), step(3))
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("version: 2\n"), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(doc.Content[0]); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if len(order) != 2 || order[0] != 2 || order[1] != 3 {
+		t.Errorf("steps applied = %v, want [2 3]", order)
+	}
+	if v := mapValue(doc.Content[0], "version").Value; v != "4" {
+		t.Errorf("version = %s, want 4", v)
+	}
+}
+
+// explicitMode - тестовый шаг обновления, записывающий mode явно
+var explicitMode = Migration{From: 1, Apply: func(root *yaml.Node) error {
+	setKey(root, "mode", string(ModeMono), "device")
+	return nil
+}}
+
+func TestMigrateFile(t *testing.T) {
+	withMigrations(t, explicitMode)
+
+	input := `# Мой конфиг
+
+device:
+  vendor_id: 0x3434   # Keychron
+  product_id: 0x0331
+
+# Цвета раскладок
+colors:
+  - layout: us
+    color: "#0064ff"  # синий
+
+  - layout: "*"
+    color: green
+`
+	want := `# Мой конфиг
+
+version: 2
+device:
+  vendor_id: 0x3434   # Keychron
+  product_id: 0x0331
+
+mode: mono
+# Цвета раскладок
+colors:
+  - layout: us
+    color: "#0064ff"  # синий
+
+  - layout: "*"
+    color: green
+`
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(input), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, _, err := MigrateFile(path, false)
+	if err != nil {
+		t.Fatalf("MigrateFile() error = %v", err)
+	}
+	if len(applied) != 1 {
+		t.Errorf("MigrateFile() applied %d steps, want 1", len(applied))
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("migrated file:\n%s\nwant:\n%s", got, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// Повторный запуск ничего не меняет
+	applied, _, err = MigrateFile(path, false)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("second MigrateFile() = %d steps, %v; want no steps", len(applied), err)
+	}
+}
+
+func TestMigrateFileSymlink(t *testing.T) {
+	withMigrations(t, explicitMode)
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "config.yaml")
+	if err := os.Mkdir(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("device: {vendor_id: 1, product_id: 2}\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := MigrateFile(link, false); err != nil {
+		t.Fatalf("MigrateFile() error = %v", err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("config is no longer a symlink: %v, %v", info, err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("file mode = %v, want 0640", info.Mode().Perm())
+	}
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "version: 2\ndevice: {vendor_id: 1, product_id: 2}\nmode: mono\n"; string(got) != want {
+		t.Errorf("migrated file:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaID - адрес опубликованной схемы (docs/config.schema.json в репозитории)
+const SchemaID = "https://raw.githubusercontent.com/jidckii/kolor-keyboard/main/docs/config.schema.json"
+
+// schemaEnums - допустимые значения строковых типов-перечислений
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(Mode("")):     {string(ModeMono), string(ModeDraw)},
+	reflect.TypeOf(Firmware("")): {string(FirmwareStock), string(FirmwareVial)},
+	reflect.TypeOf(Sampling("")): {string(SamplingNearest), string(SamplingArea)},
+	reflect.TypeOf(IMEState("")): {string(IMEActive), string(IMEInactive)},
+	reflect.TypeOf(Watcher("")):  watcherNames(),
+}
+
+// watcherNames возвращает допустимые значения watcher строками
+func watcherNames() []string {
+	names := make([]string, len(Watchers))
+	for i, w := range Watchers {
+		names[i] = string(w)
+	}
+	return names
+}
+
+// schemaRequired - обязательные поля структур (остальные можно не указывать)
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeOf(DeviceConfig{}): {"vendor_id", "product_id"},
+	reflect.TypeOf(ColorMapping{}): {"color"},
+	reflect.TypeOf(FlagStripe{}):   {"color"},
+	reflect.TypeOf(KeyGeometry{}):  {"led", "x", "y"},
+	reflect.TypeOf(TextDrawing{}):  {"value", "color"},
+}
+
+// schemaDescriptions - описания полей для подсказок в редакторе, ключ "Тип.поле_yaml"
+// У каждого поля конфига должно быть описание (проверяется тестом)
+var schemaDescriptions = map[string]string{
+	"Config.version":    "Config format version; older files are upgraded on load, 'kolor-keyboard config migrate' rewrites them",
+	"Config.geometry":   "Keyboard definition to build on, e.g. keychron/v3/ansi_encoder; merged first",
+	"Config.include":    "Config files merged after geometry, in order; this file overrides them",
+	"Config.device":     "HID device of the keyboard",
+	"Config.firmware":   "Keyboard firmware: stock (QMK/VIA) or vial (default)",
+	"Config.mode":       "mono - one color for the whole keyboard (default), draw - per-key RGB drawings (vial only)",
+	"Config.watcher":    "Where the current layout comes from: auto (default) detects the desktop and falls back to the next available source",
+	"Config.external":   "Layout source for watcher: external - a long-running command or a named pipe printing one layout per line",
+	"Config.brightness": "Backlight brightness 0-255 (not changed if omitted)",
+	"Config.speed":      "Effect speed 0-255 (default 128, vial only)",
+	"Config.colors":     "Layout colors for mono mode",
+	"Config.keyboard":   "Physical LED layout for draw mode",
+	"Config.draw":       "Layout drawings for draw mode",
+
+	"ExternalSource.command": "Program and arguments; each stdout line is a layout code (us, us(dvorak)) or a JSON event; restarted when it exits",
+	"ExternalSource.pipe":    "Named pipe (FIFO) to read layouts from, created if missing; a relative path is resolved against the config directory; \"-\" reads standard input",
+
+	"DeviceConfig.vendor_id":   "USB vendor ID, e.g. 0x3434",
+	"DeviceConfig.product_id":  "USB product ID, e.g. 0x0331",
+	"DeviceConfig.usage_page":  "HID usage page of the raw HID interface (0xFF60 for VIA)",
+	"DeviceConfig.usage":       "HID usage of the raw HID interface (0x61 for VIA)",
+	"DeviceConfig.serial":      "USB serial number, to tell apart several keyboards with the same vendor and product ID",
+	"DeviceConfig.calibration": "Color correction for the LEDs of this keyboard",
+
+	"Calibration.gamma":       "Gamma correction: <1 lifts dark tones, >1 darkens them (0 or omitted = 1)",
+	"Calibration.gain":        "Per-channel multipliers 0-2",
+	"Calibration.white_point": "Color the LEDs show instead of pure white, e.g. {kelvin: 5500}",
+
+	"ChannelGain.r": "Red channel multiplier (default 1)",
+	"ChannelGain.g": "Green channel multiplier (default 1)",
+	"ChannelGain.b": "Blue channel multiplier (default 1)",
+
+	"ColorMapping.layout":  "Layout code (us, ru, ...), code with variant (us(dvorak)), a glob (de*) or \"*\" for any other layout",
+	"ColorMapping.variant": "XKB layout variant (dvorak, phonetic, ...); a glob is allowed",
+	"ColorMapping.name":    "Layout display name (English (Dvorak)); a glob is allowed",
+	"ColorMapping.index":   "Position of the layout in the system layout list, starting at 0",
+	"ColorMapping.ime":     "Input method state (IBus, Fcitx5): active while typing through an engine such as Mozc or Pinyin",
+	"ColorMapping.color":   "Backlight color for the layout",
+
+	"KeyboardConfig.rows":     "LED indices of each keyboard row, top to bottom",
+	"KeyboardConfig.geometry": "Physical key positions in key units (1u = one regular key)",
+
+	"KeyGeometry.led":  "LED index of the key",
+	"KeyGeometry.x":    "Left edge in key units",
+	"KeyGeometry.y":    "Top edge in key units",
+	"KeyGeometry.w":    "Width in key units (default 1)",
+	"KeyGeometry.h":    "Height in key units (default 1)",
+	"KeyGeometry.name": "Key label used by the render command",
+
+	"FlagMapping.layout":   "Layout code (us, ru, ...), code with variant (us(dvorak)), a glob (de*) or \"*\" for any other layout",
+	"FlagMapping.variant":  "XKB layout variant (dvorak, phonetic, ...); a glob is allowed",
+	"FlagMapping.name":     "Layout display name (English (Dvorak)); a glob is allowed",
+	"FlagMapping.index":    "Position of the layout in the system layout list, starting at 0",
+	"FlagMapping.ime":      "Input method state (IBus, Fcitx5): active while typing through an engine such as Mozc or Pinyin",
+	"FlagMapping.stripes":  "Horizontal stripes by rows or by LED indices",
+	"FlagMapping.grid":     "Drawing with characters, one string per keyboard row, one character per key (or per 1u column with keyboard.geometry); '.' and ' ' leave a key unpainted",
+	"FlagMapping.palette":  "Colors of the grid characters ('.' and ' ' are reserved)",
+	"FlagMapping.image":    "PNG, GIF or JPEG stretched over the keyboard; relative to the config directory",
+	"FlagMapping.sampling": "How image pixels are picked for a key: nearest (default) or area",
+	"FlagMapping.text":     "Text drawn with the built-in font, optionally scrolling",
+
+	"FlagStripe.rows":  "Keyboard rows covered by the stripe (0-indexed)",
+	"FlagStripe.leds":  "Individual LED indices covered by the stripe",
+	"FlagStripe.color": "Stripe color",
+
+	"TextDrawing.value": "Text (Latin, Cyrillic, digits, 日本 and 中文; other characters are rejected)",
+	"TextDrawing.color": "Text color",
+	"TextDrawing.speed": "Scrolling speed in key columns per second (0 = static, centered)",
+}
+
+// colorSchema - схема цвета: строка или одна из форм rgb/hsv/kelvin
+func colorSchema() map[string]any {
+	channels := func(names ...string) map[string]any {
+		props := make(map[string]any)
+		for _, n := range names {
+			props[n] = map[string]any{"type": "integer", "minimum": 0, "maximum": 255}
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"required":             names,
+			"additionalProperties": false,
+		}
+	}
+	single := func(key string, value map[string]any) map[string]any {
+		return map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{key: value},
+			"required":             []string{key},
+			"additionalProperties": false,
+		}
+	}
+
+	return map[string]any{
+		"description": "Color: \"#rrggbb\", \"#rgb\", a CSS color name, rgb(), hsl(), hsv(), " +
+			"or one of {rgb: {r, g, b}}, {hsv: {h, s, v}} (0-255, as in QMK), {kelvin: 1000-40000}",
+		"oneOf": []any{
+			map[string]any{"type": "string", "minLength": 1},
+			single("rgb", channels("r", "g", "b")),
+			single("hsv", channels("h", "s", "v")),
+			single("kelvin", map[string]any{"type": "number", "minimum": 1000, "maximum": 40000}),
+		},
+	}
+}
+
+// Schema возвращает JSON Schema формата конфига
+// Схема строится по структурам Config, поэтому новые поля попадают в неё автоматически
+func Schema() ([]byte, error) {
+	g := &schemaGenerator{defs: map[string]any{"RGBColor": colorSchema()}}
+
+	root, err := g.structSchema(reflect.TypeOf(Config{}))
+	if err != nil {
+		return nil, err
+	}
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = SchemaID
+	root["title"] = "kolor-keyboard config"
+	root["$defs"] = g.defs
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// schemaGenerator строит схемы типов, вложенные структуры выносятся в $defs
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+// typeSchema возвращает схему значения типа t
+func (g *schemaGenerator) typeSchema(t reflect.Type) (map[string]any, error) {
+	if values, ok := schemaEnums[t]; ok {
+		return map[string]any{"type": "string", "enum": values}, nil
+	}
+	if t == reflect.TypeOf(StringList{}) {
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		}}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // защита от рекурсии
+			def, err := g.structSchema(t)
+			if err != nil {
+				return nil, err
+			}
+			g.defs[name] = def
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}, nil
+	case reflect.Slice:
+		items, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Uint8:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": 255}, nil
+	case reflect.Uint16:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": 65535}, nil
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	default:
+		return nil, fmt.Errorf("schema: unsupported type %s", t)
+	}
+}
+
+// structSchema возвращает схему объекта по полям структуры с тегами yaml
+func (g *schemaGenerator) structSchema(t reflect.Type) (map[string]any, error) {
+	props := make(map[string]any)
+	if err := g.addProperties(props, t, t.Name()); err != nil {
+		return nil, err
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if required, ok := schemaRequired[t]; ok {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// addProperties добавляет в props поля структуры t
+// Поля встроенных структур с ",inline" добавляются как поля owner (и описываются под его именем)
+func (g *schemaGenerator) addProperties(props map[string]any, t reflect.Type, owner string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && strings.Contains(field.Tag.Get("yaml"), ",inline") {
+			if err := g.addProperties(props, field.Type, owner); err != nil {
+				return err
+			}
+			continue
+		}
+		name := yamlFieldName(field)
+		if name == "" {
+			continue
+		}
+
+		prop, err := g.typeSchema(field.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		if desc, ok := schemaDescriptions[owner+"."+name]; ok {
+			if _, isRef := prop["$ref"]; isRef {
+				// Описание рядом с $ref допустимо в draft 2020-12
+				prop = map[string]any{"$ref": prop["$ref"], "description": desc}
+			} else {
+				prop["description"] = desc
+			}
+		}
+		props[name] = prop
+	}
+	return nil
+}
+
+// yamlFieldName возвращает имя поля в YAML ("" для пропускаемых полей)
+func yamlFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(field.Name)
+	default:
+		return name
+	}
+}
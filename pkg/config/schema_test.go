@@ -0,0 +1,92 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateSchema = flag.Bool("update", false, "update docs/config.schema.json")
+
+// schemaPath - опубликованная схема в репозитории
+var schemaPath = filepath.Join("..", "..", "docs", "config.schema.json")
+
+// TestSchemaInSync проверяет, что docs/config.schema.json совпадает со структурами конфига
+// После изменения структур: go test ./pkg/config -run TestSchemaInSync -update
+func TestSchemaInSync(t *testing.T) {
+	got, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+
+	if *updateSchema {
+		if err := os.WriteFile(schemaPath, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(schemaPath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", schemaPath, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date, run: go test ./pkg/config -run TestSchemaInSync -update", schemaPath)
+	}
+}
+
+// TestSchemaDescriptions проверяет, что у каждого поля есть описание и нет лишних описаний
+func TestSchemaDescriptions(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+
+	var schema struct {
+		Properties map[string]map[string]any `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	objects := map[string]map[string]map[string]any{"Config": schema.Properties}
+	for name, def := range schema.Defs {
+		if name != "RGBColor" {
+			objects[name] = def.Properties
+		}
+	}
+
+	seen := make(map[string]bool)
+	for typeName, props := range objects {
+		for prop, value := range props {
+			key := typeName + "." + prop
+			seen[key] = true
+			if desc, _ := value["description"].(string); desc == "" {
+				t.Errorf("%s has no description in schemaDescriptions", key)
+			}
+		}
+	}
+	for key := range schemaDescriptions {
+		if !seen[key] {
+			t.Errorf("schemaDescriptions has %s, but there is no such field", key)
+		}
+	}
+}
+
+func TestSchemaColor(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+	for _, want := range []string{`"$ref": "#/$defs/RGBColor"`, `"kelvin"`, `"enum": [`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("schema does not contain %s", want)
+		}
+	}
+}
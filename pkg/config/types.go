@@ -1,6 +1,10 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
 
 // Mode - режим работы
 type Mode string
@@ -10,6 +14,14 @@ const (
 	ModeDraw Mode = "draw" // Per-key RGB с флагами
 )
 
+// Sampling - способ выборки пикселей картинки для клавиши
+type Sampling string
+
+const (
+	SamplingNearest Sampling = "nearest" // Пиксель в центре клавиши
+	SamplingArea    Sampling = "area"    // Среднее по всем пикселям под клавишей
+)
+
 // Firmware - тип прошивки
 type Firmware string
 
@@ -18,11 +30,48 @@ const (
 	FirmwareVial  Firmware = "vial"  // Vial прошивка
 )
 
+// Watcher - источник раскладки
+type Watcher string
+
+const (
+	WatcherAuto     Watcher = "auto"     // Определить по окружению (по умолчанию)
+	WatcherKDE      Watcher = "kde"      // KDE Plasma (D-Bus org.kde.keyboard)
+	WatcherGNOME    Watcher = "gnome"    // GNOME Shell (источники ввода)
+	WatcherSway     Watcher = "sway"     // Sway IPC
+	WatcherHyprland Watcher = "hyprland" // Сокеты Hyprland
+	WatcherX11      Watcher = "x11"      // Расширение XKB X сервера
+	WatcherIBus     Watcher = "ibus"     // Движок IBus
+	WatcherFcitx5   Watcher = "fcitx5"   // Метод ввода Fcitx5
+	WatcherExternal Watcher = "external" // Внешняя команда, FIFO или stdin (см. ExternalSource)
+)
+
+// Watchers - допустимые значения watcher
+var Watchers = []Watcher{
+	WatcherAuto, WatcherKDE, WatcherGNOME, WatcherSway, WatcherHyprland, WatcherX11, WatcherIBus, WatcherFcitx5,
+	WatcherExternal,
+}
+
+// ExternalSource - внешний источник раскладки для watcher: external
+// Источник пишет по строке на смену раскладки: код ("us", "us(dvorak)") или JSON события
+// Относительные пути считаются от каталога конфига (см. rebasePaths)
+type ExternalSource struct {
+	Command []string `yaml:"command,omitempty"` // команда и аргументы, перезапускается после выхода
+	Pipe    string   `yaml:"pipe,omitempty"`    // именованный канал (FIFO), "-" - stdin
+}
+
 // Config - корневая структура конфигурации
 type Config struct {
-	Device   DeviceConfig `yaml:"device"`
-	Firmware Firmware     `yaml:"firmware"` // stock или vial
-	Mode     Mode         `yaml:"mode"`
+	Version int `yaml:"version,omitempty"` // версия формата (см. CurrentVersion), старые версии обновляются при загрузке
+
+	// Подключаемые файлы (см. compose): описание клавиатуры и общие части конфига
+	Geometry string     `yaml:"geometry,omitempty"` // ссылка на клавиатуру, например keychron/v3/ansi_encoder
+	Include  StringList `yaml:"include,omitempty"`
+
+	Device   DeviceConfig    `yaml:"device"`
+	Firmware Firmware        `yaml:"firmware"` // stock или vial
+	Mode     Mode            `yaml:"mode"`
+	Watcher  Watcher         `yaml:"watcher,omitempty"`  // источник раскладки, auto по умолчанию
+	External *ExternalSource `yaml:"external,omitempty"` // источник для watcher: external
 
 	// Глобальные настройки RGB
 	Brightness *uint8 `yaml:"brightness,omitempty"` // 0-255 (nil = не менять)
@@ -34,6 +83,8 @@ type Config struct {
 	// Для draw режима - per-key RGB
 	Keyboard KeyboardConfig `yaml:"keyboard,omitempty"`
 	Drawings []FlagMapping  `yaml:"draw,omitempty"`
+
+	files []string // файлы на диске, из которых собран конфиг (заполняет Load)
 }
 
 // DeviceConfig - параметры HID устройства
@@ -42,16 +93,23 @@ type DeviceConfig struct {
 	ProductID uint16 `yaml:"product_id"`
 	UsagePage uint16 `yaml:"usage_page"`
 	Usage     uint16 `yaml:"usage"`
+
+	// Serial - серийный номер, если подключено несколько одинаковых клавиатур (опционально)
+	Serial string `yaml:"serial,omitempty"`
+
+	// Calibration - коррекция цветов под светодиоды клавиатуры (опционально)
+	Calibration *Calibration `yaml:"calibration,omitempty"`
 }
 
 // ColorMapping - маппинг раскладки на цвет (для mono режима)
 type ColorMapping struct {
-	Layout string   `yaml:"layout"`
-	Color  RGBColor `yaml:"color"`
+	LayoutSelector `yaml:",inline"`
+	Color          RGBColor `yaml:"color"`
 }
 
-// RGBColor - цвет в RGB или HSV формате
-// При использовании HSV формата значения автоматически конвертируются в RGB
+// RGBColor - цвет в RGB
+// В конфиге может быть задан в RGB, HSV, hex, именем или цветовой температурой,
+// все форматы при загрузке конвертируются в RGB
 type RGBColor struct {
 	R uint8 `yaml:"r"`
 	G uint8 `yaml:"g"`
@@ -74,38 +132,72 @@ type hsvValues struct {
 
 // colorRaw - промежуточная структура для парсинга цвета из YAML
 type colorRaw struct {
-	RGB *rgbValues `yaml:"rgb"`
-	HSV *hsvValues `yaml:"hsv"`
+	RGB    *rgbValues `yaml:"rgb"`
+	HSV    *hsvValues `yaml:"hsv"`
+	Kelvin *float64   `yaml:"kelvin"`
 }
 
 // UnmarshalYAML реализует кастомный парсинг цвета из YAML
-// Поддерживает два формата:
+// Поддерживает форматы:
 //   - color: {rgb: {r: 255, g: 0, b: 0}}
 //   - color: {hsv: {h: 0, s: 255, v: 255}}
-func (c *RGBColor) UnmarshalYAML(unmarshal func(interface{}) error) error {
+//   - color: {kelvin: 2700}
+//   - color: "#ff8800", "#f80", "orange", "hsl(30, 100%, 50%)", "hsv(30, 100%, 100%)" (см. ParseColor)
+func (c *RGBColor) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		parsed, err := ParseColor(value.Value)
+		if err != nil {
+			return nodeError(value, err)
+		}
+		*c = parsed
+		return nil
+	case yaml.MappingNode:
+		// разбираем ниже
+	default:
+		return nodeError(value, fmt.Errorf("color must be a string or a mapping"))
+	}
+
 	var raw colorRaw
-	if err := unmarshal(&raw); err != nil {
+	if err := value.Decode(&raw); err != nil {
 		return err
 	}
 
-	if raw.RGB != nil && raw.HSV != nil {
-		return fmt.Errorf("cannot specify both rgb and hsv in color definition")
+	formats := 0
+	for _, set := range []bool{raw.RGB != nil, raw.HSV != nil, raw.Kelvin != nil} {
+		if set {
+			formats++
+		}
+	}
+	if formats > 1 {
+		return nodeError(value, fmt.Errorf("cannot specify more than one of rgb, hsv and kelvin in color definition"))
 	}
 
-	if raw.HSV != nil {
+	switch {
+	case raw.HSV != nil:
 		// Конвертируем HSV (0-255) в RGB
 		c.R, c.G, c.B = hsv255ToRGB(raw.HSV.H, raw.HSV.S, raw.HSV.V)
-	} else if raw.RGB != nil {
+	case raw.RGB != nil:
 		c.R = raw.RGB.R
 		c.G = raw.RGB.G
 		c.B = raw.RGB.B
-	} else {
-		return fmt.Errorf("color must have either 'rgb' or 'hsv' key")
+	case raw.Kelvin != nil:
+		if *raw.Kelvin < 1000 || *raw.Kelvin > 40000 {
+			return nodeError(value, fmt.Errorf("kelvin must be between 1000 and 40000, got %v", *raw.Kelvin))
+		}
+		*c = KelvinToRGB(*raw.Kelvin)
+	default:
+		return nodeError(value, fmt.Errorf("color must have either 'rgb', 'hsv' or 'kelvin' key"))
 	}
 
 	return nil
 }
 
+// nodeError добавляет к ошибке позицию узла YAML
+func nodeError(node *yaml.Node, err error) error {
+	return fmt.Errorf("line %d, column %d: %w", node.Line, node.Column, err)
+}
+
 // hsv255ToRGB конвертирует HSV (0-255) в RGB
 // Формат как в QMK/Vial: H, S, V все в диапазоне 0-255
 func hsv255ToRGB(h, s, v uint8) (r, g, b uint8) {
@@ -149,12 +241,112 @@ type KeyboardConfig struct {
 	// Rows - LED индексы для каждого ряда клавиатуры
 	// Пример: [[0,1,2,3,...], [15,16,17,...], ...]
 	Rows [][]int `yaml:"rows"`
+
+	// Geometry - физическое расположение клавиш (опционально)
+	// Если указано, картинки накладываются по реальным координатам клавиш,
+	// иначе каждый ряд из Rows растягивается на всю ширину
+	Geometry []KeyGeometry `yaml:"geometry,omitempty"`
+}
+
+// KeyGeometry - положение клавиши в единицах клавиш (1u = ширина обычной клавиши)
+type KeyGeometry struct {
+	LED  int     `yaml:"led"`
+	X    float64 `yaml:"x"`
+	Y    float64 `yaml:"y"`
+	W    float64 `yaml:"w,omitempty"`    // ширина (0 = 1u)
+	H    float64 `yaml:"h,omitempty"`    // высота (0 = 1u)
+	Name string  `yaml:"name,omitempty"` // подпись клавиши для render (опционально)
+}
+
+// Size возвращает размер клавиши с учётом значений по умолчанию
+func (k KeyGeometry) Size() (w, h float64) {
+	w, h = k.W, k.H
+	if w <= 0 {
+		w = 1
+	}
+	if h <= 0 {
+		h = 1
+	}
+	return w, h
 }
 
 // FlagMapping - маппинг раскладки на флаг (для draw режима)
+// Слои рисуются в порядке: image, stripes, grid, text (каждый следующий поверх предыдущего)
 type FlagMapping struct {
-	Layout  string       `yaml:"layout"`
-	Stripes []FlagStripe `yaml:"stripes"`
+	LayoutSelector `yaml:",inline"`
+	Stripes        []FlagStripe `yaml:"stripes,omitempty"`
+
+	// Grid - рисунок символами, одна строка на ряд клавиатуры
+	// Каждый символ соответствует одной клавише ряда (по порядку keyboard.rows),
+	// а с keyboard.geometry - колонке шириной 1u (см. Config.GridColumns)
+	// Символы '.' и ' ' означают "не закрашивать"
+	// Пример: ["WWWWBBBBRRRR", ...]
+	Grid []string `yaml:"grid,omitempty"`
+	// Palette - цвета символов сетки
+	// Пример: {W: {rgb: {r: 255, g: 255, b: 255}}, B: {rgb: {r: 0, g: 0, b: 255}}}
+	Palette map[string]RGBColor `yaml:"palette,omitempty"`
+
+	// Image - картинка (PNG, GIF, JPEG), растягивается на всю клавиатуру
+	// Относительный путь считается от директории конфига
+	Image string `yaml:"image,omitempty"`
+	// Sampling - способ выборки пикселей картинки: nearest (по умолчанию) или area
+	Sampling Sampling `yaml:"sampling,omitempty"`
+	// Frames - загруженные кадры картинки (заполняется при загрузке конфига)
+	Frames []ImageFrame `yaml:"-"`
+
+	// Text - бегущая строка, нарисованная встроенным шрифтом
+	Text *TextDrawing `yaml:"text,omitempty"`
+
+	// gridLines - номера строк YAML для каждой строки Grid (для сообщений об ошибках)
+	gridLines []int
+}
+
+// flagMappingRaw - псевдоним без UnmarshalYAML для декодирования полей
+type flagMappingRaw FlagMapping
+
+// UnmarshalYAML декодирует флаг и запоминает позиции строк сетки в файле
+func (f *FlagMapping) UnmarshalYAML(value *yaml.Node) error {
+	var raw flagMappingRaw
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*f = FlagMapping(raw)
+
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			if value.Content[i].Value != "grid" {
+				continue
+			}
+			for _, row := range value.Content[i+1].Content {
+				f.gridLines = append(f.gridLines, row.Line)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GridLine возвращает номер строки YAML для строки сетки (0 если неизвестно)
+func (f *FlagMapping) GridLine(row int) int {
+	if row < 0 || row >= len(f.gridLines) {
+		return 0
+	}
+	return f.gridLines[row]
+}
+
+// IsGridBlank сообщает, что символ сетки не закрашивает клавишу
+func IsGridBlank(ch rune) bool {
+	return ch == '.' || ch == ' '
+}
+
+// TextDrawing - бегущая строка по рядам клавиатуры
+type TextDrawing struct {
+	// Value - текст (латиница, кириллица, цифры, 日本 и 中文); символы не из шрифта - ошибка конфига
+	Value string `yaml:"value"`
+	// Color - цвет букв (фон - нижележащие слои рисунка)
+	Color RGBColor `yaml:"color"`
+	// Speed - скорость прокрутки в колонках клавиш в секунду (0 = текст стоит на месте)
+	Speed float64 `yaml:"speed,omitempty"`
 }
 
 // FlagStripe - горизонтальная полоса флага
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce - пауза после последнего изменения файла перед уведомлением
+// Редакторы сохраняют файл в несколько шагов (truncate, write, rename)
+const watchDebounce = 200 * time.Millisecond
+
+// Watch следит за изменениями файлов конфига через inotify (см. Config.Files)
+// Отслеживаются директории файлов: редакторы часто заменяют файл через rename,
+// и наблюдение за самим файлом на этом терялось бы
+// Канал получает значение после каждой серии изменений и закрывается при отмене ctx
+func Watch(ctx context.Context, paths ...string) (<-chan struct{}, error) {
+	files := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve config path: %w", err)
+		}
+		files[abs] = true
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	for abs := range files {
+		// Повторное добавление той же директории ничего не делает
+		if err := watcher.Add(filepath.Dir(abs)); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch config directory: %w", err)
+		}
+	}
+
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		defer close(changes)
+		defer watcher.Close()
+
+		timer := time.NewTimer(watchDebounce)
+		timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !files[filepath.Clean(event.Name)] {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				timer.Reset(watchDebounce)
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			case <-timer.C:
+				select {
+				case changes <- struct{}{}:
+				default:
+					// Предыдущее уведомление ещё не обработано - хватит одного
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("mode: mono\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := Watch(ctx, path)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	expect := func(what string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no change notification after %s", what)
+		}
+	}
+
+	// Запись в файл
+	if err := os.WriteFile(path, []byte("mode: draw\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("write")
+
+	// Замена через rename, как делают редакторы
+	tmp := filepath.Join(dir, ".config.yaml.swp")
+	if err := os.WriteFile(tmp, []byte("mode: mono\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	expect("rename")
+
+	// Другие файлы в директории не учитываются
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Error("unexpected notification for another file")
+	case <-time.After(3 * watchDebounce):
+	}
+
+	// Подключаемый файл из другой директории
+	other := t.TempDir()
+	include := filepath.Join(other, "colors.yaml")
+	if err := os.WriteFile(include, []byte("colors: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for range changes {
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	changes, err = Watch(ctx, path, include)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if err := os.WriteFile(include, []byte("colors: [{layout: us, color: red}]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("include write")
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Error("unexpected notification after cancel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("channel not closed after cancel")
+	}
+}
@@ -0,0 +1,34 @@
+package dbus
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Имена сервисов на сессионной шине, по которым определяется окружение
+const (
+	KDEService    = kdeKeyboardDest
+	GNOMEService  = "org.gnome.Shell"
+	Fcitx5Service = fcitxDest
+)
+
+// SessionBusNames возвращает имена, занятые на сессионной шине
+func SessionBusNames() (map[string]bool, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	defer conn.Close()
+
+	var list []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&list); err != nil {
+		return nil, fmt.Errorf("failed to list bus names: %w", err)
+	}
+
+	names := make(map[string]bool, len(list))
+	for _, name := range list {
+		names[name] = true
+	}
+	return names, nil
+}
@@ -0,0 +1,24 @@
+package dbus
+
+import "testing"
+
+func TestSessionBusNames(t *testing.T) {
+	address := startBus(t)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", address)
+
+	kde := connectBus(t, address)
+	if _, err := kde.RequestName(KDEService, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := SessionBusNames()
+	if err != nil {
+		t.Fatalf("SessionBusNames() error = %v", err)
+	}
+	if !names[KDEService] || !names["org.freedesktop.DBus"] {
+		t.Errorf("SessionBusNames() = %v, want %s and the bus itself", names, KDEService)
+	}
+	if names[GNOMEService] {
+		t.Errorf("SessionBusNames() has %s, nobody owns it", GNOMEService)
+	}
+}
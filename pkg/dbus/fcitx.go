@@ -0,0 +1,213 @@
+package dbus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+)
+
+const (
+	fcitxDest  = "org.fcitx.Fcitx5"
+	fcitxPath  = "/controller"
+	fcitxIface = "org.fcitx.Fcitx.Controller1"
+
+	// fcitxStateActive - State(): 0 - нет контекста ввода, 1 - выключен, 2 - включён
+	fcitxStateActive = 2
+
+	// fcitxPoll - период опроса: Controller1 не сообщает о смене метода ввода сигналом
+	fcitxPoll = 200 * time.Millisecond
+
+	// fcitxMaxErrors - неудачные опросы подряд, после которых Fcitx5 считается остановленным
+	fcitxMaxErrors = 5
+)
+
+// Fcitx5Watcher реализует LayoutWatcher для Fcitx5
+type Fcitx5Watcher struct {
+	conn   *dbus.Conn
+	poll   time.Duration
+	cancel context.CancelFunc
+
+	namesOnce sync.Once
+	names     map[string]string // имена методов ввода для показа
+}
+
+// NewFcitx5Watcher создаёт новый watcher для Fcitx5
+func NewFcitx5Watcher() (*Fcitx5Watcher, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	return newFcitx5Watcher(conn, fcitxPoll), nil
+}
+
+func newFcitx5Watcher(conn *dbus.Conn, poll time.Duration) *Fcitx5Watcher {
+	return &Fcitx5Watcher{conn: conn, poll: poll}
+}
+
+// Watch опрашивает Fcitx5 и сообщает о смене метода ввода или его состояния
+// Канал закрывается, когда Fcitx5 освобождает имя на шине или перестаёт отвечать
+// (fcitxMaxErrors опросов подряд), чтобы можно было перейти к другому источнику
+func (w *Fcitx5Watcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	events := make(chan layout.LayoutEvent, 10)
+
+	// Повтор текущего состояния событием не считается
+	last, err := w.GetCurrentLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	rule := fmt.Sprintf("type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',"+
+		"member='NameOwnerChanged',arg0='%s'", fcitxDest)
+	if call := w.conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule); call.Err != nil {
+		return nil, fmt.Errorf("failed to add match rule: %w", call.Err)
+	}
+	signals := make(chan *dbus.Signal, 10)
+	w.conn.Signal(signals)
+
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	go func() {
+		defer close(events)
+		defer w.conn.RemoveSignal(signals)
+		ticker := time.NewTicker(w.poll)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					// Соединение с шиной потеряно
+					return
+				}
+				if sig.Name != "org.freedesktop.DBus.NameOwnerChanged" {
+					continue
+				}
+				if name, owner, ok := nameOwnerChange(sig); ok && name == fcitxDest && owner == "" {
+					// Fcitx5 завершился
+					return
+				}
+			case <-ticker.C:
+				event, err := w.GetCurrentLayout()
+				if err != nil {
+					if failures++; failures >= fcitxMaxErrors {
+						return
+					}
+					continue
+				}
+				failures = 0
+				if event == last {
+					continue
+				}
+				last = event
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// GetCurrentLayout возвращает текущий метод ввода
+func (w *Fcitx5Watcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	obj := w.conn.Object(fcitxDest, fcitxPath)
+
+	var name string
+	if err := obj.Call(fcitxIface+".CurrentInputMethod", 0).Store(&name); err != nil {
+		return layout.LayoutEvent{}, fmt.Errorf("failed to get current input method: %w", err)
+	}
+	var state int32
+	if err := obj.Call(fcitxIface+".State", 0).Store(&state); err != nil {
+		return layout.LayoutEvent{}, fmt.Errorf("failed to get input method state: %w", err)
+	}
+
+	return w.inputMethodEvent(name, state == fcitxStateActive), nil
+}
+
+// Layouts возвращает методы ввода текущей группы Fcitx5
+// Текущая группа - первая в InputMethodGroups, её состав - InputMethodGroupInfo: раскладка и a(ss)
+func (w *Fcitx5Watcher) Layouts() ([]layout.LayoutInfo, error) {
+	obj := w.conn.Object(fcitxDest, fcitxPath)
+
+	var groups []string
+	if err := obj.Call(fcitxIface+".InputMethodGroups", 0).Store(&groups); err != nil {
+		return nil, fmt.Errorf("failed to get input method groups: %w", err)
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no input method groups")
+	}
+
+	var groupLayout string
+	var items [][]any // (метод ввода, раскладка)
+	if err := obj.Call(fcitxIface+".InputMethodGroupInfo", 0, groups[0]).Store(&groupLayout, &items); err != nil {
+		return nil, fmt.Errorf("failed to get input method group %q: %w", groups[0], err)
+	}
+
+	layouts := make([]layout.LayoutInfo, 0, len(items))
+	for _, item := range items {
+		if len(item) == 0 {
+			continue
+		}
+		name, _ := item[0].(string)
+		event := w.inputMethodEvent(name, false)
+		layouts = append(layouts, layout.LayoutInfo{Code: event.Layout, Variant: event.Variant, Name: event.Name})
+	}
+	return layouts, nil
+}
+
+// inputMethodEvent переводит метод ввода Fcitx5 в событие
+// "keyboard-us", "keyboard-de-nodeadkeys" - раскладки XKB, остальные ("mozc", "pinyin")
+// - методы ввода, код раскладки - имя метода
+func (w *Fcitx5Watcher) inputMethodEvent(name string, active bool) layout.LayoutEvent {
+	event := layout.LayoutEvent{Index: -1, Name: w.displayName(name), Engine: name}
+	if spec, ok := strings.CutPrefix(name, "keyboard-"); ok {
+		event.Layout, event.Variant, _ = strings.Cut(spec, "-")
+	} else {
+		event.Layout = name
+		event.IME = active
+	}
+	return event
+}
+
+// displayName возвращает имя метода ввода для показа ("Mozc", "English (US)")
+// Список методов запрашивается один раз, при ошибке используется внутреннее имя
+func (w *Fcitx5Watcher) displayName(name string) string {
+	w.namesOnce.Do(func() {
+		w.names = make(map[string]string)
+		// a(ssssssb): уникальное имя, имя, родное имя, значок, метка, язык, настраиваемый
+		var methods [][]any
+		err := w.conn.Object(fcitxDest, fcitxPath).Call(fcitxIface+".AvailableInputMethods", 0).Store(&methods)
+		if err == nil {
+			for _, m := range methods {
+				if len(m) < 2 {
+					continue
+				}
+				id, _ := m[0].(string)
+				display, _ := m[1].(string)
+				w.names[id] = display
+			}
+		}
+	})
+	if display := w.names[name]; display != "" {
+		return display
+	}
+	return name
+}
+
+// Close закрывает соединение
+func (w *Fcitx5Watcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	return w.conn.Close()
+}
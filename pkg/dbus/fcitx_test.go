@@ -0,0 +1,183 @@
+package dbus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+)
+
+// fakeFcitx5 - объект /controller с методами Controller1
+type fakeFcitx5 struct {
+	mu    sync.Mutex
+	name  string
+	state int32
+	fail  bool // CurrentInputMethod возвращает ошибку
+}
+
+func (f *fakeFcitx5) CurrentInputMethod() (string, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return "", dbus.MakeFailedError(fmt.Errorf("input method is not available"))
+	}
+	return f.name, nil
+}
+
+func (f *fakeFcitx5) State() (int32, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state, nil
+}
+
+// inputMethod - запись a(ssssssb) из AvailableInputMethods
+type inputMethod struct {
+	UniqueName, Name, NativeName, Icon, Label, Language string
+	Configurable                                        bool
+}
+
+func (f *fakeFcitx5) AvailableInputMethods() ([]inputMethod, *dbus.Error) {
+	return []inputMethod{
+		{UniqueName: "keyboard-us", Name: "English (US)", Label: "en", Language: "en"},
+		{UniqueName: "mozc", Name: "Mozc", Label: "あ", Language: "ja", Configurable: true},
+	}, nil
+}
+
+// groupItem - запись a(ss) из InputMethodGroupInfo: метод ввода и раскладка
+type groupItem struct {
+	Name, Layout string
+}
+
+func (f *fakeFcitx5) InputMethodGroups() ([]string, *dbus.Error) {
+	return []string{"Default", "Other"}, nil
+}
+
+func (f *fakeFcitx5) InputMethodGroupInfo(group string) (string, []groupItem, *dbus.Error) {
+	if group != "Default" {
+		return "", nil, dbus.MakeFailedError(fmt.Errorf("unexpected group %q", group))
+	}
+	return "us", []groupItem{{"keyboard-us", ""}, {"mozc", "jp"}}, nil
+}
+
+func (f *fakeFcitx5) set(name string, state int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.name, f.state = name, state
+}
+
+func (f *fakeFcitx5) setFail(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fail
+}
+
+func TestFcitx5Watcher(t *testing.T) {
+	address := startBus(t)
+
+	daemon := connectBus(t, address)
+	if _, err := daemon.RequestName(fcitxDest, 0); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeFcitx5{name: "keyboard-us", state: 1}
+	if err := daemon.Export(fake, fcitxPath, fcitxIface); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newFcitx5Watcher(connectBus(t, address), 10*time.Millisecond)
+
+	current, err := w.GetCurrentLayout()
+	if err != nil {
+		t.Fatalf("GetCurrentLayout() error = %v", err)
+	}
+	if want := (layout.LayoutEvent{Index: -1, Layout: "us", Name: "English (US)", Engine: "keyboard-us"}); current != want {
+		t.Errorf("GetCurrentLayout() = %+v, want %+v", current, want)
+	}
+
+	want := []layout.LayoutInfo{{Code: "us", Name: "English (US)"}, {Code: "mozc", Name: "Mozc"}}
+	if got, err := w.Layouts(); err != nil || !slices.Equal(got, want) {
+		t.Errorf("Layouts() = %+v, %v, want %+v", got, err, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	expect := func(want layout.LayoutEvent) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+
+	fake.set("mozc", 2)
+	expect(layout.LayoutEvent{Index: -1, Layout: "mozc", Name: "Mozc", Engine: "mozc", IME: true})
+
+	// Выключение метода ввода без смены движка
+	fake.set("mozc", 1)
+	expect(layout.LayoutEvent{Index: -1, Layout: "mozc", Name: "Mozc", Engine: "mozc"})
+
+	fake.set("keyboard-de-nodeadkeys", 1)
+	expect(layout.LayoutEvent{Index: -1, Layout: "de", Variant: "nodeadkeys", Name: "keyboard-de-nodeadkeys", Engine: "keyboard-de-nodeadkeys"})
+}
+
+// TestFcitx5WatcherStops проверяет, что канал закрывается, когда Fcitx5 пропадает
+func TestFcitx5WatcherStops(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(t *testing.T, daemon *dbus.Conn, fake *fakeFcitx5)
+	}{
+		{"name released", func(t *testing.T, daemon *dbus.Conn, _ *fakeFcitx5) {
+			if _, err := daemon.ReleaseName(fcitxDest); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"calls fail", func(t *testing.T, _ *dbus.Conn, fake *fakeFcitx5) {
+			fake.setFail(true)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := startBus(t)
+			daemon := connectBus(t, address)
+			if _, err := daemon.RequestName(fcitxDest, 0); err != nil {
+				t.Fatal(err)
+			}
+			fake := &fakeFcitx5{name: "keyboard-us", state: 1}
+			if err := daemon.Export(fake, fcitxPath, fcitxIface); err != nil {
+				t.Fatal(err)
+			}
+
+			w := newFcitx5Watcher(connectBus(t, address), 10*time.Millisecond)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events, err := w.Watch(ctx)
+			if err != nil {
+				t.Fatalf("Watch() error = %v", err)
+			}
+
+			tt.stop(t, daemon, fake)
+			select {
+			case event, ok := <-events:
+				if ok {
+					t.Errorf("unexpected event %+v, want the channel closed", event)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("events channel not closed")
+			}
+		})
+	}
+}
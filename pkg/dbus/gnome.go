@@ -0,0 +1,298 @@
+package dbus
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+const (
+	gnomeSourcesSchema = "org.gnome.desktop.input-sources"
+	gnomeSourcesPath   = "/org/gnome/desktop/input-sources/"
+
+	// dconf сообщает об изменении любых ключей сигналом Notify
+	dconfWriterIface = "ca.desrt.dconf.Writer"
+
+	// ibusComponentDir - описания движков IBus (отображаемые имена)
+	ibusComponentDir = "/usr/share/ibus/component"
+)
+
+// gnomeSettings читает ключи GSettings в текстовом формате GVariant
+type gnomeSettings interface {
+	Get(schema, key string) (string, error)
+}
+
+// gsettingsCLI читает GSettings через утилиту gsettings
+// Так используется тот же backend, что и у GNOME Shell (обычно dconf)
+type gsettingsCLI struct{}
+
+func (gsettingsCLI) Get(schema, key string) (string, error) {
+	out, err := exec.Command("gsettings", "get", schema, key).Output()
+	if err != nil {
+		return "", fmt.Errorf("gsettings get %s %s: %w", schema, key, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// inputSource - источник ввода GNOME: ('xkb', 'us+dvorak') или ('ibus', 'anthy')
+type inputSource struct {
+	Type string
+	ID   string
+}
+
+// GNOMELayoutWatcher реализует LayoutWatcher для GNOME Shell
+// Текущий источник ввода - первый в mru-sources, список - sources
+type GNOMELayoutWatcher struct {
+	conn     *dbus.Conn
+	settings gnomeSettings
+	registry *xkb.Registry
+	cancel   context.CancelFunc
+}
+
+// NewGNOMELayoutWatcher создаёт новый watcher для GNOME
+func NewGNOMELayoutWatcher() (*GNOMELayoutWatcher, error) {
+	if _, err := exec.LookPath("gsettings"); err != nil {
+		return nil, fmt.Errorf("gsettings not found: %w", err)
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	return newGNOMELayoutWatcher(conn, gsettingsCLI{}), nil
+}
+
+func newGNOMELayoutWatcher(conn *dbus.Conn, settings gnomeSettings) *GNOMELayoutWatcher {
+	return &GNOMELayoutWatcher{conn: conn, settings: settings, registry: xkb.Default()}
+}
+
+// Watch запускает отслеживание смены источника ввода
+func (w *GNOMELayoutWatcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	events := make(chan layout.LayoutEvent, 10)
+
+	matchRule := fmt.Sprintf("type='signal',interface='%s',member='Notify'", dconfWriterIface)
+	call := w.conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, matchRule)
+	if call.Err != nil {
+		return nil, fmt.Errorf("failed to add match rule: %w", call.Err)
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	w.conn.Signal(signals)
+
+	// Изменение sources без смены текущего источника событием не считается
+	last, _ := w.GetCurrentLayout()
+
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				if sig.Name != dconfWriterIface+".Notify" || !sourcesChanged(sig) {
+					continue
+				}
+				event, err := w.GetCurrentLayout()
+				if err != nil || event == last {
+					continue
+				}
+				last = event
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// sourcesChanged проверяет, что сигнал dconf Notify касается ключей input-sources
+// Тело сигнала: префикс, список ключей относительно префикса, метка
+func sourcesChanged(sig *dbus.Signal) bool {
+	if len(sig.Body) < 2 {
+		return false
+	}
+	prefix, _ := sig.Body[0].(string)
+	changes, _ := sig.Body[1].([]string)
+	if len(changes) == 0 {
+		changes = []string{""}
+	}
+	for _, key := range changes {
+		path := prefix + key
+		if strings.HasPrefix(path, gnomeSourcesPath) || strings.HasPrefix(gnomeSourcesPath, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCurrentLayout возвращает текущий источник ввода
+func (w *GNOMELayoutWatcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	sources, err := w.sources()
+	if err != nil {
+		return layout.LayoutEvent{}, err
+	}
+	if len(sources) == 0 {
+		// Без настроенных источников GNOME использует раскладку системы
+		return layout.LayoutEvent{}, fmt.Errorf("no input sources configured")
+	}
+
+	index := w.currentIndex(sources)
+	return w.sourceEvent(sources[index], index), nil
+}
+
+// Layouts возвращает источники ввода по порядку sources
+func (w *GNOMELayoutWatcher) Layouts() ([]layout.LayoutInfo, error) {
+	sources, err := w.sources()
+	if err != nil {
+		return nil, err
+	}
+	layouts := make([]layout.LayoutInfo, len(sources))
+	for i, s := range sources {
+		event := w.sourceEvent(s, i)
+		layouts[i] = layout.LayoutInfo{Code: event.Layout, Variant: event.Variant, Name: event.Name}
+	}
+	return layouts, nil
+}
+
+// sources читает список источников ввода
+func (w *GNOMELayoutWatcher) sources() ([]inputSource, error) {
+	raw, err := w.settings.Get(gnomeSourcesSchema, "sources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get input sources: %w", err)
+	}
+	sources, err := parseSources(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input sources: %w", err)
+	}
+	return sources, nil
+}
+
+// currentIndex ищет текущий источник: первый из mru-sources, который есть в sources,
+// затем устаревший ключ current (GNOME до 3.36), иначе первый
+func (w *GNOMELayoutWatcher) currentIndex(sources []inputSource) int {
+	if raw, err := w.settings.Get(gnomeSourcesSchema, "mru-sources"); err == nil {
+		if mru, err := parseSources(raw); err == nil {
+			for _, recent := range mru {
+				for i, s := range sources {
+					if s == recent {
+						return i
+					}
+				}
+			}
+		}
+	}
+	if raw, err := w.settings.Get(gnomeSourcesSchema, "current"); err == nil {
+		current, err := strconv.Atoi(strings.TrimPrefix(raw, "uint32 "))
+		if err == nil && current >= 0 && current < len(sources) {
+			return current
+		}
+	}
+	return 0
+}
+
+// sourceEvent переводит источник ввода в событие
+// xkb: "us+dvorak" -> код us, вариант dvorak, имя из реестра XKB
+// ibus: код и движок - имя движка ("anthy"), имя - из описания компонента IBus
+func (w *GNOMELayoutWatcher) sourceEvent(s inputSource, index int) layout.LayoutEvent {
+	event := layout.LayoutEvent{Index: index}
+	switch s.Type {
+	case "xkb":
+		event.Layout, event.Variant = xkb.SplitID(s.ID)
+		event.Name = w.registry.Description(event.Layout, event.Variant)
+	default:
+		event.Layout = s.ID
+		event.Name = ibusEngineName(s.ID)
+		event.Engine, event.IME = s.ID, true
+	}
+	if event.Name == "" {
+		event.Name = s.ID
+	}
+	return event
+}
+
+// sourcePattern - кортеж (ss) в текстовом формате GVariant, строки в ' или "
+var sourcePattern = regexp.MustCompile(`\(\s*('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*")\s*,\s*('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*")\s*\)`)
+
+// parseSources разбирает значение a(ss): [('xkb', 'us'), ('ibus', 'anthy')] или @a(ss) []
+func parseSources(raw string) ([]inputSource, error) {
+	raw = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "@a(ss)"))
+	if !strings.HasPrefix(raw, "[") || !strings.HasSuffix(raw, "]") {
+		return nil, fmt.Errorf("unexpected value %q", raw)
+	}
+
+	var sources []inputSource
+	for _, m := range sourcePattern.FindAllStringSubmatch(raw, -1) {
+		sources = append(sources, inputSource{Type: unquoteGVariant(m[1]), ID: unquoteGVariant(m[2])})
+	}
+	return sources, nil
+}
+
+// unquoteGVariant снимает кавычки и экранирование со строки GVariant
+func unquoteGVariant(s string) string {
+	s = s[1 : len(s)-1]
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// ibusEngines - отображаемые имена движков IBus из описаний компонентов
+var ibusEngines = sync.OnceValue(func() map[string]string {
+	names := make(map[string]string)
+	files, _ := filepath.Glob(filepath.Join(ibusComponentDir, "*.xml"))
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var component struct {
+			Engines []struct {
+				Name     string `xml:"name"`
+				LongName string `xml:"longname"`
+			} `xml:"engines>engine"`
+		}
+		if xml.Unmarshal(data, &component) != nil {
+			continue
+		}
+		for _, e := range component.Engines {
+			names[e.Name] = e.LongName
+		}
+	}
+	return names
+})
+
+// ibusEngineName возвращает отображаемое имя движка IBus ("" - неизвестен)
+func ibusEngineName(engine string) string {
+	return ibusEngines()[engine]
+}
+
+// Close закрывает соединение
+func (w *GNOMELayoutWatcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	return w.conn.Close()
+}
@@ -0,0 +1,178 @@
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+)
+
+// startBus запускает отдельный dbus-daemon и возвращает его адрес
+func startBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read bus address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// connectBus подключается к шине из startBus
+func connectBus(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("failed to connect to bus: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// memorySettings - GSettings в памяти, об изменениях сообщает как dconf (сигнал Notify)
+type memorySettings struct {
+	mu     sync.Mutex
+	values map[string]string
+	conn   *dbus.Conn
+}
+
+func (s *memorySettings) Get(schema, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[schema+" "+key]
+	if !ok {
+		return "", fmt.Errorf("no key %s %s", schema, key)
+	}
+	return value, nil
+}
+
+func (s *memorySettings) Set(t *testing.T, key, value string) {
+	t.Helper()
+	s.mu.Lock()
+	s.values[gnomeSourcesSchema+" "+key] = value
+	s.mu.Unlock()
+
+	err := s.conn.Emit("/ca/desrt/dconf/Writer/user", dconfWriterIface+".Notify",
+		gnomeSourcesPath+key, []string{""}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []inputSource
+		wantErr bool
+	}{
+		{input: "@a(ss) []"},
+		{input: "[('xkb', 'us'), ('xkb', 'ru')]", want: []inputSource{{"xkb", "us"}, {"xkb", "ru"}}},
+		{input: "[('xkb', 'us+dvorak'), ('ibus', 'anthy')]", want: []inputSource{{"xkb", "us+dvorak"}, {"ibus", "anthy"}}},
+		{input: `[("xkb", "it's")]`, want: []inputSource{{"xkb", "it's"}}},
+		{input: `[('xkb', 'a\'b')]`, want: []inputSource{{"xkb", "a'b"}}},
+		{input: "uint32 0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSources(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("parseSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGNOMELayoutWatcher(t *testing.T) {
+	address := startBus(t)
+	settings := &memorySettings{
+		values: map[string]string{
+			gnomeSourcesSchema + " sources":     "[('xkb', 'us'), ('xkb', 'us+dvorak'), ('ibus', 'anthy')]",
+			gnomeSourcesSchema + " mru-sources": "@a(ss) []",
+		},
+		conn: connectBus(t, address),
+	}
+
+	w := newGNOMELayoutWatcher(connectBus(t, address), settings)
+
+	current, err := w.GetCurrentLayout()
+	if err != nil {
+		t.Fatalf("GetCurrentLayout() error = %v", err)
+	}
+	if current.Layout != "us" || current.Index != 0 {
+		t.Errorf("GetCurrentLayout() = %+v, want us at 0", current)
+	}
+	layouts, err := w.Layouts()
+	if err != nil || len(layouts) != 3 || layouts[1].Code != "us" || layouts[1].Variant != "dvorak" {
+		t.Errorf("Layouts() = %+v, %v, want 3 sources with us(dvorak) second", layouts, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	expect := func(want layout.LayoutEvent) {
+		t.Helper()
+		select {
+		case got := <-events:
+			// Отображаемые имена XKB зависят от системы, проверяется только наличие
+			if got.Index != want.Index || got.Layout != want.Layout || got.Variant != want.Variant || got.Name == "" {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+			if want.Name != "" && got.Name != want.Name {
+				t.Errorf("event name = %q, want %q", got.Name, want.Name)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+
+	settings.Set(t, "mru-sources", "[('xkb', 'us+dvorak'), ('xkb', 'us')]")
+	expect(layout.LayoutEvent{Index: 1, Layout: "us", Variant: "dvorak"})
+
+	settings.Set(t, "mru-sources", "[('ibus', 'anthy'), ('xkb', 'us+dvorak'), ('xkb', 'us')]")
+	expect(layout.LayoutEvent{Index: 2, Layout: "anthy", Name: "anthy"})
+
+	// Перестановка sources меняет индекс текущего источника
+	settings.Set(t, "sources", "[('ibus', 'anthy'), ('xkb', 'us')]")
+	expect(layout.LayoutEvent{Index: 0, Layout: "anthy", Name: "anthy"})
+
+	// Изменение без смены источника событием не считается, чужие ключи не интересны
+	settings.Set(t, "xkb-options", "['caps:escape']")
+	settings.Set(t, "mru-sources", "[('ibus', 'anthy'), ('xkb', 'us')]")
+	settings.Set(t, "mru-sources", "[('xkb', 'us'), ('ibus', 'anthy')]")
+	expect(layout.LayoutEvent{Index: 1, Layout: "us"})
+
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,203 @@
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+)
+
+const (
+	ibusDest  = "org.freedesktop.IBus"
+	ibusPath  = "/org/freedesktop/IBus"
+	ibusIface = "org.freedesktop.IBus"
+)
+
+// IBusWatcher реализует LayoutWatcher для IBus
+// IBus работает на своей шине, её адрес - в $IBUS_ADDRESS или в ~/.config/ibus/bus/
+type IBusWatcher struct {
+	conn   *dbus.Conn
+	cancel context.CancelFunc
+}
+
+// IBusAddress возвращает адрес шины IBus
+// Без $IBUS_ADDRESS берётся самый свежий файл ~/.config/ibus/bus/<machine-id>-<host>-<display>
+func IBusAddress() (string, error) {
+	if address := os.Getenv("IBUS_ADDRESS"); address != "" {
+		return address, nil
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		home, _ := os.UserHomeDir()
+		configHome = filepath.Join(home, ".config")
+	}
+	files, _ := filepath.Glob(filepath.Join(configHome, "ibus", "bus", "*"))
+
+	var newest string
+	var newestTime int64
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if t := info.ModTime().UnixNano(); newest == "" || t > newestTime {
+			newest, newestTime = path, t
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("ibus bus address not found (is ibus-daemon running?)")
+	}
+	return readIBusAddress(newest)
+}
+
+// readIBusAddress читает IBUS_ADDRESS=... из файла адреса шины
+func readIBusAddress(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ibus address: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if address, ok := strings.CutPrefix(scanner.Text(), "IBUS_ADDRESS="); ok && address != "" {
+			return address, nil
+		}
+	}
+	return "", fmt.Errorf("no IBUS_ADDRESS in %s", path)
+}
+
+// NewIBusWatcher подключается к шине IBus
+func NewIBusWatcher() (*IBusWatcher, error) {
+	address, err := IBusAddress()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ibus: %w", err)
+	}
+	return &IBusWatcher{conn: conn}, nil
+}
+
+// Watch запускает отслеживание смены движка
+func (w *IBusWatcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	events := make(chan layout.LayoutEvent, 10)
+
+	matchRule := fmt.Sprintf(
+		"type='signal',interface='%s',member='GlobalEngineChanged',path='%s'",
+		ibusIface, ibusPath,
+	)
+	call := w.conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, matchRule)
+	if call.Err != nil {
+		return nil, fmt.Errorf("failed to add match rule: %w", call.Err)
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	w.conn.Signal(signals)
+
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				if sig.Name != ibusIface+".GlobalEngineChanged" || len(sig.Body) < 1 {
+					continue
+				}
+				name, _ := sig.Body[0].(string)
+				// Полное описание движка (имя для показа) - из свойства GlobalEngine
+				event, err := w.GetCurrentLayout()
+				if err != nil || event.Engine != name {
+					event = engineEvent(name, "")
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// GetCurrentLayout возвращает текущий движок
+func (w *IBusWatcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	v, err := w.conn.Object(ibusDest, ibusPath).GetProperty(ibusIface + ".GlobalEngine")
+	if err != nil {
+		return layout.LayoutEvent{}, fmt.Errorf("failed to get global engine: %w", err)
+	}
+	name, longName, err := parseEngineDesc(v.Value())
+	if err != nil {
+		return layout.LayoutEvent{}, err
+	}
+	return engineEvent(name, longName), nil
+}
+
+// Layouts не поддерживается: список движков пользователя хранится
+// в настройках рабочего стола, а не на шине IBus
+func (w *IBusWatcher) Layouts() ([]layout.LayoutInfo, error) {
+	return nil, nil
+}
+
+// parseEngineDesc разбирает IBusEngineDesc: ("IBusEngineDesc", {вложения}, name, longname,
+// description, language, ...)
+// Сериализованные объекты IBus сами завёрнуты в variant
+func parseEngineDesc(v any) (name, longName string, err error) {
+	if inner, ok := v.(dbus.Variant); ok {
+		v = inner.Value()
+	}
+	fields, ok := v.([]any)
+	if !ok || len(fields) < 4 {
+		return "", "", fmt.Errorf("unexpected engine description %T", v)
+	}
+	if kind, _ := fields[0].(string); kind != "IBusEngineDesc" {
+		return "", "", fmt.Errorf("unexpected engine description %q", kind)
+	}
+	name, _ = fields[2].(string)
+	longName, _ = fields[3].(string)
+	return name, longName, nil
+}
+
+// engineEvent переводит движок IBus в событие
+// "xkb:us:dvorak:eng" - раскладка XKB без метода ввода, остальные ("mozc-jp", "anthy")
+// - метод ввода, код раскладки - имя движка
+func engineEvent(name, longName string) layout.LayoutEvent {
+	event := layout.LayoutEvent{Index: -1, Name: longName, Engine: name}
+	if spec, ok := strings.CutPrefix(name, "xkb:"); ok {
+		parts := strings.Split(spec, ":")
+		event.Layout = parts[0]
+		if len(parts) > 1 {
+			event.Variant = parts[1]
+		}
+	} else {
+		event.Layout = name
+		event.IME = true
+	}
+	if event.Name == "" {
+		event.Name = name
+	}
+	return event
+}
+
+// Close закрывает соединение
+func (w *IBusWatcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	return w.conn.Close()
+}
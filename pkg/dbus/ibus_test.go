@@ -0,0 +1,149 @@
+package dbus
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+)
+
+// engineDesc - сериализованный IBusEngineDesc (первые поля)
+type engineDesc struct {
+	Kind        string
+	Attachments map[string]dbus.Variant
+	Name        string
+	LongName    string
+	Description string
+	Language    string
+	License     string
+	Author      string
+	Icon        string
+	Layout      string
+	Rank        uint32
+}
+
+func engine(name, longName, xkbLayout string) dbus.Variant {
+	return dbus.MakeVariant(engineDesc{
+		Kind: "IBusEngineDesc", Attachments: map[string]dbus.Variant{},
+		Name: name, LongName: longName, Layout: xkbLayout,
+	})
+}
+
+func TestIBusAddress(t *testing.T) {
+	dir := t.TempDir()
+	bus := filepath.Join(dir, "ibus", "bus")
+	if err := os.MkdirAll(bus, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string, age time.Duration) {
+		path := filepath.Join(bus, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("abc-unix-0", "# comment\nIBUS_ADDRESS=unix:path=/old\nIBUS_DAEMON_PID=1\n", time.Hour)
+	write("abc-unix-wayland-0", "IBUS_ADDRESS=unix:path=/new\nIBUS_DAEMON_PID=2\n", time.Minute)
+
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("IBUS_ADDRESS", "")
+	if got, err := IBusAddress(); err != nil || got != "unix:path=/new" {
+		t.Errorf("IBusAddress() = %q, %v, want the newest file", got, err)
+	}
+
+	t.Setenv("IBUS_ADDRESS", "unix:path=/env")
+	if got, err := IBusAddress(); err != nil || got != "unix:path=/env" {
+		t.Errorf("IBusAddress() = %q, %v, want $IBUS_ADDRESS", got, err)
+	}
+
+	t.Setenv("IBUS_ADDRESS", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if _, err := IBusAddress(); err == nil {
+		t.Error("IBusAddress() without bus files succeeded")
+	}
+}
+
+func TestEngineEvent(t *testing.T) {
+	tests := []struct {
+		name, longName string
+		want           layout.LayoutEvent
+	}{
+		{"xkb:us::eng", "English (US)", layout.LayoutEvent{Index: -1, Layout: "us", Name: "English (US)", Engine: "xkb:us::eng"}},
+		{"xkb:us:dvorak:eng", "", layout.LayoutEvent{Index: -1, Layout: "us", Variant: "dvorak", Name: "xkb:us:dvorak:eng", Engine: "xkb:us:dvorak:eng"}},
+		{"mozc-jp", "Mozc", layout.LayoutEvent{Index: -1, Layout: "mozc-jp", Name: "Mozc", Engine: "mozc-jp", IME: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := engineEvent(tt.name, tt.longName); got != tt.want {
+				t.Errorf("engineEvent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIBusWatcher(t *testing.T) {
+	address := startBus(t)
+
+	// Демон IBus: свойство GlobalEngine и сигнал GlobalEngineChanged
+	daemon := connectBus(t, address)
+	if _, err := daemon.RequestName(ibusDest, 0); err != nil {
+		t.Fatal(err)
+	}
+	props, err := prop.Export(daemon, ibusPath, prop.Map{
+		ibusIface: {"GlobalEngine": {Value: engine("xkb:us::eng", "English (US)", "us"), Emit: prop.EmitFalse}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	switchEngine := func(name, longName string) {
+		t.Helper()
+		props.SetMust(ibusIface, "GlobalEngine", engine(name, longName, "default"))
+		if err := daemon.Emit(ibusPath, ibusIface+".GlobalEngineChanged", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := &IBusWatcher{conn: connectBus(t, address)}
+
+	current, err := w.GetCurrentLayout()
+	if err != nil {
+		t.Fatalf("GetCurrentLayout() error = %v", err)
+	}
+	if current.Layout != "us" || current.IME || current.Name != "English (US)" {
+		t.Errorf("GetCurrentLayout() = %+v, want us without IME", current)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	expect := func(want layout.LayoutEvent) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+
+	switchEngine("mozc-jp", "Mozc")
+	expect(layout.LayoutEvent{Index: -1, Layout: "mozc-jp", Name: "Mozc", Engine: "mozc-jp", IME: true})
+
+	switchEngine("xkb:ru::rus", "Russian")
+	expect(layout.LayoutEvent{Index: -1, Layout: "ru", Name: "Russian", Engine: "xkb:ru::rus"})
+}
@@ -0,0 +1,110 @@
+package dbus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+// kdeProtocol - версия интерфейса org.kde.KeyboardLayouts
+//
+//	Plasma 5.19+ и 6:  getLayout() -> u, getLayoutsList() -> a(sss), сигнал layoutChanged(u)
+//	Plasma 5 до 5.19: getCurrentLayout() -> s, getLayoutsList() -> as, сигнал currentLayoutChanged(s)
+//
+// В старом интерфейсе раскладка - строка "us" или "us(dvorak)", отображаемого имени в ответах нет
+type kdeProtocol int
+
+const (
+	kdeProtocolIndex kdeProtocol = iota // раскладка - индекс в списке
+	kdeProtocolName                     // раскладка - строка "us(dvorak)"
+)
+
+// currentMethod возвращает метод, которым запрашивается текущая раскладка
+func (p kdeProtocol) currentMethod() string {
+	if p == kdeProtocolName {
+		return kdeLayoutsIface + ".getCurrentLayout"
+	}
+	return kdeLayoutsIface + ".getLayout"
+}
+
+// detectKDEProtocol определяет версию интерфейса по интроспекции /Layouts
+func detectKDEProtocol(node *introspect.Node) (kdeProtocol, error) {
+	for _, iface := range node.Interfaces {
+		if iface.Name != kdeLayoutsIface {
+			continue
+		}
+		for _, m := range iface.Methods {
+			switch m.Name {
+			case "getLayout":
+				return kdeProtocolIndex, nil
+			case "getCurrentLayout":
+				return kdeProtocolName, nil
+			}
+		}
+		return 0, fmt.Errorf("%s has neither getLayout nor getCurrentLayout", kdeLayoutsIface)
+	}
+	return 0, fmt.Errorf("%s not found at %s", kdeLayoutsIface, kdeLayoutsPath)
+}
+
+// splitKDELayout разбирает раскладку старого интерфейса: "us(dvorak)" -> us, dvorak
+func splitKDELayout(s string) (code, variant string) {
+	code, variant, _ = strings.Cut(s, "(")
+	return code, strings.TrimSuffix(variant, ")")
+}
+
+// parseKDELayouts разбирает ответ getLayoutsList: a(sss) (код, вариант, имя) или as ("us(dvorak)")
+// Для строк имя берётся из реестра XKB, а если раскладки в нём нет - сама строка
+func parseKDELayouts(body []any, registry *xkb.Registry) ([]layout.LayoutInfo, error) {
+	if len(body) == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+
+	switch list := body[0].(type) {
+	case [][]any:
+		// godbus возвращает структуры как слайсы
+		result := make([]layout.LayoutInfo, len(list))
+		for i, l := range list {
+			if len(l) >= 3 {
+				result[i].Code, _ = l[0].(string)
+				result[i].Variant, _ = l[1].(string)
+				result[i].Name, _ = l[2].(string)
+			}
+		}
+		return result, nil
+	case []string:
+		result := make([]layout.LayoutInfo, len(list))
+		for i, s := range list {
+			code, variant := splitKDELayout(s)
+			result[i] = layout.LayoutInfo{Code: code, Variant: variant, Name: registry.Description(code, variant)}
+			if result[i].Name == "" {
+				result[i].Name = s
+			}
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unexpected getLayoutsList reply %s", dbus.SignatureOf(body...))
+	}
+}
+
+// parseKDECurrent разбирает текущую раскладку из ответа getLayout/getCurrentLayout
+// или сигнала layoutChanged/currentLayoutChanged: индекс (u) или строка (s, id != "")
+func parseKDECurrent(body []any) (index uint32, id string, err error) {
+	if len(body) == 0 {
+		return 0, "", fmt.Errorf("empty layout message")
+	}
+	switch v := body[0].(type) {
+	case uint32:
+		return v, "", nil
+	case string:
+		if v == "" {
+			return 0, "", fmt.Errorf("empty layout name")
+		}
+		return 0, v, nil
+	default:
+		return 0, "", fmt.Errorf("unexpected layout message %s", dbus.SignatureOf(body...))
+	}
+}
@@ -0,0 +1,153 @@
+package dbus
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+// testdata/kde - сообщения org.kde.KeyboardLayouts в формате провода D-Bus
+// и интроспекция /Layouts: plasma6 - KWin (Plasma 6), plasma5 - модуль kded keyboard (Plasma 5.18)
+
+// readMessage разбирает сообщение D-Bus из testdata/kde
+func readMessage(t *testing.T, name string) *dbus.Message {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "kde", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := dbus.DecodeMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeMessage(%s) error = %v", name, err)
+	}
+	return msg
+}
+
+// readIntrospection разбирает интроспекцию из testdata/kde
+func readIntrospection(t *testing.T, name string) *introspect.Node {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "kde", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var node introspect.Node
+	if err := xml.Unmarshal(data, &node); err != nil {
+		t.Fatal(err)
+	}
+	return &node
+}
+
+func TestDetectKDEProtocol(t *testing.T) {
+	tests := []struct {
+		file string
+		want kdeProtocol
+	}{
+		{"plasma6-introspect.xml", kdeProtocolIndex},
+		{"plasma5-introspect.xml", kdeProtocolName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := detectKDEProtocol(readIntrospection(t, tt.file))
+			if err != nil || got != tt.want {
+				t.Errorf("detectKDEProtocol() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := detectKDEProtocol(&introspect.Node{}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("detectKDEProtocol(empty) error = %v, want not found", err)
+	}
+}
+
+func TestParseKDELayouts(t *testing.T) {
+	registry, err := xkb.ParseRegistry(strings.NewReader(`<xkbConfigRegistry><layoutList>
+<layout><configItem><name>ru</name><description>Russian</description></configItem></layout>
+<layout><configItem><name>de</name><description>German</description></configItem>
+<variantList><variant><configItem><name>nodeadkeys</name><description>German (no dead keys)</description></configItem></variant></variantList>
+</layout>
+</layoutList></xkbConfigRegistry>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		want []layout.LayoutInfo
+	}{
+		{"plasma6-getLayoutsList.bin", []layout.LayoutInfo{
+			{Code: "us", Name: "English (US)"},
+			{Code: "ru", Name: "Russian"},
+			{Code: "de", Variant: "nodeadkeys", Name: "German (no dead keys)"},
+		}},
+		// Имени в ответе нет: из реестра, а если раскладки в нём нет - сама строка
+		{"plasma5-getLayoutsList.bin", []layout.LayoutInfo{
+			{Code: "us", Name: "us"},
+			{Code: "ru", Name: "Russian"},
+			{Code: "de", Variant: "nodeadkeys", Name: "German (no dead keys)"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := parseKDELayouts(readMessage(t, tt.file).Body, registry)
+			if err != nil || !slices.Equal(got, tt.want) {
+				t.Errorf("parseKDELayouts() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseKDELayouts([]any{uint32(1)}, registry); err == nil || !strings.Contains(err.Error(), "reply u") {
+		t.Errorf("parseKDELayouts(u) error = %v, want unexpected reply", err)
+	}
+}
+
+func TestParseKDECurrent(t *testing.T) {
+	tests := []struct {
+		file      string
+		wantIndex uint32
+		wantID    string
+		wantErr   bool
+	}{
+		{"plasma6-getLayout.bin", 1, "", false},
+		{"plasma6-layoutChanged.bin", 2, "", false},
+		{"plasma5-getCurrentLayout.bin", 0, "ru", false},
+		{"plasma5-currentLayoutChanged.bin", 0, "de(nodeadkeys)", false},
+		{"plasma6-layoutListChanged.bin", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			index, id, err := parseKDECurrent(readMessage(t, tt.file).Body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseKDECurrent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if index != tt.wantIndex || id != tt.wantID {
+				t.Errorf("parseKDECurrent() = %d, %q, want %d, %q", index, id, tt.wantIndex, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestSplitKDELayout(t *testing.T) {
+	tests := []struct {
+		in, code, variant string
+	}{
+		{"us", "us", ""},
+		{"de(nodeadkeys)", "de", "nodeadkeys"},
+	}
+	for _, tt := range tests {
+		if code, variant := splitKDELayout(tt.in); code != tt.code || variant != tt.variant {
+			t.Errorf("splitKDELayout(%q) = %q, %q, want %q, %q", tt.in, code, variant, tt.code, tt.variant)
+		}
+	}
+}
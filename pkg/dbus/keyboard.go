@@ -3,8 +3,13 @@ package dbus
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
 )
 
 const (
@@ -13,56 +18,101 @@ const (
 	kdeLayoutsIface = "org.kde.KeyboardLayouts"
 )
 
-// LayoutEvent - событие смены раскладки
-type LayoutEvent struct {
-	Index  uint32 // Индекс раскладки (0, 1, 2...)
-	Layout string // Код раскладки ("ru", "us", "ua")
-	Name   string // Полное имя ("Russian", "English (US)")
-}
-
-// LayoutWatcher - интерфейс для отслеживания раскладки
-type LayoutWatcher interface {
-	Watch(ctx context.Context) (<-chan LayoutEvent, error)
-	GetCurrentLayout() (LayoutEvent, error)
-	Close() error
-}
+// Переподключение к сессионной шине, если соединение потеряно
+const (
+	kdeReconnectDelay    = time.Second
+	kdeMaxReconnectDelay = 30 * time.Second
+)
 
-// KDELayoutWatcher реализует LayoutWatcher для KDE Plasma 6
+// KDELayoutWatcher реализует LayoutWatcher для KDE Plasma 5 и 6
+// Версия интерфейса org.kde.KeyboardLayouts определяется интроспекцией (см. kdeProtocol).
+// Переживает перезапуск KWin (новый владелец org.kde.keyboard) и сессионной шины:
+// после них раскладка перечитывается и приходит событием.
+// Список раскладок кэшируется и обновляется по сигналу layoutListChanged
 type KDELayoutWatcher struct {
-	conn   *dbus.Conn
-	cancel context.CancelFunc
+	connect func() (*dbus.Conn, error)
+	retry   time.Duration // первая пауза перед переподключением
+	cancel  context.CancelFunc
+
+	registry *xkb.Registry // имена раскладок для интерфейса Plasma 5
+
+	mu       sync.Mutex
+	conn     *dbus.Conn
+	layouts  []layout.LayoutInfo // кэш getLayoutsList, nil - не загружен
+	protocol kdeProtocol
+	detected bool // protocol определён интроспекцией
 }
 
 // NewKDELayoutWatcher создаёт новый watcher для KDE
 func NewKDELayoutWatcher() (*KDELayoutWatcher, error) {
-	conn, err := dbus.SessionBus()
+	return newKDELayoutWatcher(func() (*dbus.Conn, error) {
+		return dbus.ConnectSessionBus()
+	})
+}
+
+func newKDELayoutWatcher(connect func() (*dbus.Conn, error)) (*KDELayoutWatcher, error) {
+	conn, err := connect()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
 	}
-	return &KDELayoutWatcher{conn: conn}, nil
+	return &KDELayoutWatcher{connect: connect, retry: kdeReconnectDelay, registry: xkb.Default(), conn: conn}, nil
 }
 
-// Watch запускает отслеживание смены раскладки
-func (w *KDELayoutWatcher) Watch(ctx context.Context) (<-chan LayoutEvent, error) {
-	events := make(chan LayoutEvent, 10)
-
-	// Подписка на сигнал layoutChanged
-	matchRule := fmt.Sprintf(
-		"type='signal',interface='%s',member='layoutChanged',path='%s'",
-		kdeLayoutsIface, kdeLayoutsPath,
-	)
-
-	call := w.conn.BusObject().Call(
-		"org.freedesktop.DBus.AddMatch", 0, matchRule,
-	)
-	if call.Err != nil {
-		return nil, fmt.Errorf("failed to add match rule: %w", call.Err)
+// bus возвращает текущее соединение с шиной
+func (w *KDELayoutWatcher) bus() *dbus.Conn {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn
+}
+
+// subscribeKDE подписывается на сигналы /Layouts (layoutChanged или currentLayoutChanged, layoutListChanged)
+// и смену владельца org.kde.keyboard
+// Канал сигналов закрывается, когда соединение с шиной потеряно
+func subscribeKDE(conn *dbus.Conn) (chan *dbus.Signal, error) {
+	rules := []string{
+		fmt.Sprintf("type='signal',interface='%s',path='%s'", kdeLayoutsIface, kdeLayoutsPath),
+		fmt.Sprintf("type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',"+
+			"member='NameOwnerChanged',arg0='%s'", kdeKeyboardDest),
+	}
+	for _, rule := range rules {
+		if call := conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule); call.Err != nil {
+			return nil, fmt.Errorf("failed to add match rule: %w", call.Err)
+		}
 	}
 
 	signals := make(chan *dbus.Signal, 10)
-	w.conn.Signal(signals)
+	conn.Signal(signals)
+	return signals, nil
+}
+
+// Watch запускает отслеживание смены раскладки
+func (w *KDELayoutWatcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	signals, err := subscribeKDE(w.bus())
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, w.cancel = context.WithCancel(ctx)
+	events := make(chan layout.LayoutEvent, 10)
+
+	send := func(event layout.LayoutEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	// resync перечитывает раскладку у нового владельца org.kde.keyboard
+	// Событие приходит, даже если раскладка та же: клавиатуру нужно перерисовать
+	resync := func() bool {
+		event, err := w.GetCurrentLayout()
+		if err != nil {
+			// Сервис ещё не готов: раскладка придёт с NameOwnerChanged или layoutChanged
+			return true
+		}
+		return send(event)
+	}
 
 	go func() {
 		defer close(events)
@@ -72,16 +122,37 @@ func (w *KDELayoutWatcher) Watch(ctx context.Context) (<-chan LayoutEvent, error
 				return
 			case sig, ok := <-signals:
 				if !ok {
-					return
+					// Соединение с шиной потеряно
+					w.invalidate()
+					if signals = w.reconnect(ctx); signals == nil || !resync() {
+						return
+					}
+					continue
 				}
-				if sig.Name == kdeLayoutsIface+".layoutChanged" {
-					event, err := w.parseLayoutSignal(sig)
-					if err == nil {
-						select {
-						case events <- event:
-						case <-ctx.Done():
-							return
-						}
+
+				switch sig.Name {
+				case kdeLayoutsIface + ".layoutChanged", kdeLayoutsIface + ".currentLayoutChanged":
+					// Код и имя берутся из кэша списка раскладок, без запроса на каждое переключение
+					event, err := w.currentEvent(sig.Body)
+					if err == nil && !send(event) {
+						return
+					}
+				case kdeLayoutsIface + ".layoutListChanged":
+					// Индексы теперь указывают на другие раскладки
+					w.invalidate()
+					if !resync() {
+						return
+					}
+				case "org.freedesktop.DBus.NameOwnerChanged":
+					name, owner, ok := nameOwnerChange(sig)
+					if !ok || name != kdeKeyboardDest {
+						continue
+					}
+					// У нового KWin может быть другой список раскладок.
+					// Пустой новый владелец - KWin остановлен, ждём следующего
+					w.invalidate()
+					if owner != "" && !resync() {
+						return
 					}
 				}
 			}
@@ -91,111 +162,191 @@ func (w *KDELayoutWatcher) Watch(ctx context.Context) (<-chan LayoutEvent, error
 	return events, nil
 }
 
+// nameOwnerChange разбирает NameOwnerChanged: имя и новый владелец
+func nameOwnerChange(sig *dbus.Signal) (name, owner string, ok bool) {
+	if len(sig.Body) != 3 {
+		return "", "", false
+	}
+	name, ok1 := sig.Body[0].(string)
+	owner, ok2 := sig.Body[2].(string)
+	return name, owner, ok1 && ok2
+}
+
+// reconnect подключается к шине заново с растущей паузой и восстанавливает подписку
+// Возвращает новый канал сигналов, nil - ctx отменён
+func (w *KDELayoutWatcher) reconnect(ctx context.Context) chan *dbus.Signal {
+	delay := w.retry
+	for {
+		conn, err := w.connect()
+		if err == nil {
+			signals, err := subscribeKDE(conn)
+			if err == nil {
+				w.mu.Lock()
+				if ctx.Err() != nil {
+					w.mu.Unlock()
+					conn.Close()
+					return nil
+				}
+				old := w.conn
+				w.conn = conn
+				w.mu.Unlock()
+				old.Close()
+				return signals
+			}
+			conn.Close()
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		}
+		delay = min(delay*2, kdeMaxReconnectDelay)
+	}
+}
+
 // GetCurrentLayout возвращает текущую раскладку
-func (w *KDELayoutWatcher) GetCurrentLayout() (LayoutEvent, error) {
-	obj := w.conn.Object(kdeKeyboardDest, kdeLayoutsPath)
+func (w *KDELayoutWatcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	method := w.detectProtocol().currentMethod()
+	call := w.bus().Object(kdeKeyboardDest, kdeLayoutsPath).Call(method, 0)
+	if call.Err != nil {
+		return layout.LayoutEvent{}, fmt.Errorf("failed to get current layout: %w", call.Err)
+	}
+	return w.currentEvent(call.Body)
+}
 
-	var index uint32
-	err := obj.Call(kdeLayoutsIface+".getLayout", 0).Store(&index)
+// detectProtocol возвращает версию интерфейса KDE, при первом вызове - по интроспекции
+// Если интроспекция не удалась, используется интерфейс Plasma 6, а проверка повторяется в следующий раз
+func (w *KDELayoutWatcher) detectProtocol() kdeProtocol {
+	w.mu.Lock()
+	if w.detected {
+		defer w.mu.Unlock()
+		return w.protocol
+	}
+	conn := w.conn
+	w.mu.Unlock()
+
+	node, err := introspect.Call(conn.Object(kdeKeyboardDest, kdeLayoutsPath))
 	if err != nil {
-		return LayoutEvent{}, fmt.Errorf("failed to get current layout: %w", err)
+		return kdeProtocolIndex
+	}
+	protocol, err := detectKDEProtocol(node)
+	if err != nil {
+		return kdeProtocolIndex
 	}
 
-	// Получаем список раскладок для имени
-	layouts, err := w.getLayoutsList()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.protocol, w.detected = protocol, true
+	return protocol
+}
+
+// currentEvent переводит текущую раскладку из ответа или сигнала в событие
+func (w *KDELayoutWatcher) currentEvent(body []any) (layout.LayoutEvent, error) {
+	index, id, err := parseKDECurrent(body)
 	if err != nil {
-		return LayoutEvent{Index: index}, nil
+		return layout.LayoutEvent{}, err
+	}
+	if id != "" {
+		return w.nameEvent(id), nil
 	}
+	return w.indexEvent(index), nil
+}
 
-	layout := ""
-	name := ""
-	if int(index) < len(layouts) {
-		info := layouts[index]
-		layout = info.Code
-		name = info.Name
+// Layouts возвращает список раскладок KDE (из кэша)
+func (w *KDELayoutWatcher) Layouts() ([]layout.LayoutInfo, error) {
+	w.mu.Lock()
+	cached := w.layouts
+	w.mu.Unlock()
+	if cached != nil {
+		return cached, nil
 	}
 
-	return LayoutEvent{
-		Index:  index,
-		Layout: layout,
-		Name:   name,
-	}, nil
+	layouts, err := w.getLayoutsList()
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	w.layouts = layouts
+	w.mu.Unlock()
+	return layouts, nil
 }
 
-// LayoutInfo содержит информацию о раскладке из D-Bus
-type LayoutInfo struct {
-	Code    string // "us", "ru"
-	Variant string // "", "phonetic"
-	Name    string // "English (US)", "Russian"
+// invalidate сбрасывает кэш списка раскладок и версию интерфейса
+// (после перезапуска KWin или шины может ответить другая версия Plasma)
+func (w *KDELayoutWatcher) invalidate() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.layouts = nil
+	w.detected = false
 }
 
-// getLayoutsList получает список всех раскладок
-func (w *KDELayoutWatcher) getLayoutsList() ([]LayoutInfo, error) {
-	obj := w.conn.Object(kdeKeyboardDest, kdeLayoutsPath)
+// indexEvent дополняет индекс раскладки кодом и именем из списка
+// Если список недоступен, в событии только индекс (записи с index всё равно сработают)
+func (w *KDELayoutWatcher) indexEvent(index uint32) layout.LayoutEvent {
+	event := layout.LayoutEvent{Index: int(index)}
 
-	// D-Bus возвращает a(sss) - массив структур из 3 строк
-	call := obj.Call(kdeLayoutsIface+".getLayoutsList", 0)
-	if call.Err != nil {
-		return nil, fmt.Errorf("failed to get layouts list: %w", call.Err)
+	layouts, err := w.Layouts()
+	if err == nil && int(index) >= len(layouts) {
+		// Список мог смениться без layoutListChanged (например, сигнал потерян при переподключении)
+		w.invalidate()
+		layouts, err = w.Layouts()
 	}
-
-	if len(call.Body) == 0 {
-		return nil, fmt.Errorf("empty response")
+	if err != nil || int(index) >= len(layouts) {
+		return event
 	}
 
-	// Парсим как [][]interface{} так как godbus возвращает структуры как слайсы
-	rawLayouts, ok := call.Body[0].([][]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected type: %T", call.Body[0])
-	}
+	info := layouts[index]
+	event.Layout = info.Code
+	event.Variant = info.Variant
+	event.Name = info.Name
+	return event
+}
+
+// nameEvent переводит раскладку интерфейса Plasma 5 ("us(dvorak)") в событие
+// Индекс - позиция в списке раскладок, -1 - раскладки нет в списке
+func (w *KDELayoutWatcher) nameEvent(id string) layout.LayoutEvent {
+	code, variant := splitKDELayout(id)
+	event := layout.LayoutEvent{Index: -1, Layout: code, Variant: variant}
 
-	result := make([]LayoutInfo, len(rawLayouts))
-	for i, l := range rawLayouts {
-		if len(l) >= 3 {
-			code, _ := l[0].(string)
-			variant, _ := l[1].(string)
-			name, _ := l[2].(string)
-			result[i] = LayoutInfo{
-				Code:    code,
-				Variant: variant,
-				Name:    name,
+	find := func() bool {
+		layouts, err := w.Layouts()
+		if err != nil {
+			return false
+		}
+		for i, l := range layouts {
+			if l.Code == code && l.Variant == variant {
+				event.Index, event.Name = i, l.Name
+				return true
 			}
 		}
+		return false
 	}
-
-	return result, nil
-}
-
-// parseLayoutSignal парсит сигнал смены раскладки
-func (w *KDELayoutWatcher) parseLayoutSignal(sig *dbus.Signal) (LayoutEvent, error) {
-	if len(sig.Body) < 1 {
-		return LayoutEvent{}, fmt.Errorf("invalid signal body")
+	if !find() {
+		// Список мог смениться без layoutListChanged
+		w.invalidate()
+		find()
 	}
 
-	index, ok := sig.Body[0].(uint32)
-	if !ok {
-		return LayoutEvent{}, fmt.Errorf("invalid index type")
+	if event.Name == "" {
+		event.Name = w.registry.Description(code, variant)
 	}
-
-	// Получаем полную информацию о раскладке
-	layouts, err := w.getLayoutsList()
-	if err != nil {
-		return LayoutEvent{Index: index}, nil
+	if event.Name == "" {
+		event.Name = id
 	}
+	return event
+}
 
-	layout := ""
-	name := ""
-	if int(index) < len(layouts) {
-		info := layouts[index]
-		layout = info.Code
-		name = info.Name
-	}
+// getLayoutsList запрашивает список всех раскладок
+func (w *KDELayoutWatcher) getLayoutsList() ([]layout.LayoutInfo, error) {
+	obj := w.bus().Object(kdeKeyboardDest, kdeLayoutsPath)
 
-	return LayoutEvent{
-		Index:  index,
-		Layout: layout,
-		Name:   name,
-	}, nil
+	// Plasma 5.19+ возвращает a(sss), Plasma 5 до 5.19 - as
+	call := obj.Call(kdeLayoutsIface+".getLayoutsList", 0)
+	if call.Err != nil {
+		return nil, fmt.Errorf("failed to get layouts list: %w", call.Err)
+	}
+	return parseKDELayouts(call.Body, w.registry)
 }
 
 // Close закрывает соединение
@@ -203,5 +354,7 @@ func (w *KDELayoutWatcher) Close() error {
 	if w.cancel != nil {
 		w.cancel()
 	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.conn.Close()
 }
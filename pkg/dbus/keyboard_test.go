@@ -0,0 +1,315 @@
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/layout/layouttest"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+// kdeLayout - запись getLayoutsList: a(sss)
+type kdeLayout struct {
+	Code, Variant, Name string
+}
+
+// fakeKWin - сервис org.kde.keyboard с объектом /Layouts
+type fakeKWin struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	index   uint32
+	layouts []kdeLayout
+	lists   int // число вызовов getLayoutsList
+}
+
+// startKWin занимает org.kde.keyboard на шине, как KWin при запуске
+func startKWin(t *testing.T, address string, index uint32) *fakeKWin {
+	t.Helper()
+	k := &fakeKWin{
+		conn:    connectBus(t, address),
+		index:   index,
+		layouts: []kdeLayout{{"us", "", "English (US)"}, {"ru", "", "Russian"}, {"de", "nodeadkeys", "German (no dead keys)"}},
+	}
+	methods := map[string]interface{}{
+		"getLayout": func() (uint32, *dbus.Error) {
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			return k.index, nil
+		},
+		"getLayoutsList": func() ([]kdeLayout, *dbus.Error) {
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			k.lists++
+			return k.layouts, nil
+		},
+	}
+	exportKDE(t, k.conn, methods, "plasma6-introspect.xml")
+	return k
+}
+
+// exportKDE публикует методы /Layouts с интроспекцией из testdata/kde и занимает org.kde.keyboard
+func exportKDE(t *testing.T, conn *dbus.Conn, methods map[string]interface{}, introspection string) {
+	t.Helper()
+	if err := conn.ExportMethodTable(methods, kdeLayoutsPath, kdeLayoutsIface); err != nil {
+		t.Fatal(err)
+	}
+	node := readIntrospection(t, introspection)
+	if err := conn.Export(introspect.NewIntrospectable(node), kdeLayoutsPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.RequestName(kdeKeyboardDest, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeKDED - модуль kded keyboard из Plasma 5 до 5.19: раскладки - строки "us(dvorak)"
+type fakeKDED struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	current string
+}
+
+func startKDED(t *testing.T, address, current string) *fakeKDED {
+	t.Helper()
+	k := &fakeKDED{conn: connectBus(t, address), current: current}
+	methods := map[string]interface{}{
+		"getCurrentLayout": func() (string, *dbus.Error) {
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			return k.current, nil
+		},
+		"getLayoutsList": func() ([]string, *dbus.Error) {
+			return []string{"us", "ru", "de(nodeadkeys)"}, nil
+		},
+	}
+	exportKDE(t, k.conn, methods, "plasma5-introspect.xml")
+	return k
+}
+
+// switchLayout меняет раскладку и шлёт currentLayoutChanged
+func (k *fakeKDED) switchLayout(t *testing.T, id string) {
+	t.Helper()
+	k.mu.Lock()
+	k.current = id
+	k.mu.Unlock()
+	if err := k.conn.Emit(kdeLayoutsPath, kdeLayoutsIface+".currentLayoutChanged", id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// switchLayout меняет раскладку и шлёт layoutChanged
+func (k *fakeKWin) switchLayout(t *testing.T, index uint32) {
+	t.Helper()
+	k.mu.Lock()
+	k.index = index
+	k.mu.Unlock()
+	if err := k.conn.Emit(kdeLayoutsPath, kdeLayoutsIface+".layoutChanged", index); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setLayouts меняет список раскладок и шлёт layoutListChanged
+func (k *fakeKWin) setLayouts(t *testing.T, layouts ...kdeLayout) {
+	t.Helper()
+	k.mu.Lock()
+	k.layouts = layouts
+	k.mu.Unlock()
+	if err := k.conn.Emit(kdeLayoutsPath, kdeLayoutsIface+".layoutListChanged"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listCalls возвращает число вызовов getLayoutsList
+func (k *fakeKWin) listCalls() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.lists
+}
+
+// startBusAt запускает dbus-daemon по заданному адресу и возвращает функцию остановки
+// Так шину можно перезапустить с тем же адресом
+func startBusAt(t *testing.T, address string) func() {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--address="+address, "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start dbus-daemon: %v", err)
+	}
+	stop := func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
+	t.Cleanup(stop)
+
+	// Адрес печатается, когда шина готова
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("failed to read bus address: %v", err)
+	}
+	return stop
+}
+
+var (
+	kdeUS = layout.LayoutEvent{Index: 0, Layout: "us", Name: "English (US)"}
+	kdeRU = layout.LayoutEvent{Index: 1, Layout: "ru", Name: "Russian"}
+	kdeDE = layout.LayoutEvent{Index: 2, Layout: "de", Variant: "nodeadkeys", Name: "German (no dead keys)"}
+)
+
+func TestKDELayoutWatcher(t *testing.T) {
+	address := startBus(t)
+	kwin := startKWin(t, address, 0)
+
+	w, err := newKDELayoutWatcher(func() (*dbus.Conn, error) { return dbus.Connect(address) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if got, err := w.GetCurrentLayout(); err != nil || got != kdeUS {
+		t.Fatalf("GetCurrentLayout() = %+v, %v, want %+v", got, err, kdeUS)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	kwin.switchLayout(t, 1)
+	layouttest.ExpectEvent(t, events, kdeRU)
+
+	// Перезапуск KWin: имя освобождается и занимается новым процессом,
+	// раскладка которого приходит событием без layoutChanged
+	kwin.conn.Close()
+	startKWin(t, address, 2)
+	layouttest.ExpectEvent(t, events, kdeDE)
+}
+
+func TestKDELayoutWatcherBusRestart(t *testing.T) {
+	address := "unix:path=" + filepath.Join(t.TempDir(), "bus")
+	stop := startBusAt(t, address)
+	startKWin(t, address, 0)
+
+	w, err := newKDELayoutWatcher(func() (*dbus.Conn, error) { return dbus.Connect(address) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.retry = 10 * time.Millisecond
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// Шина перезапускается по тому же адресу, KWin подключается к новой
+	stop()
+	os.Remove(strings.TrimPrefix(address, "unix:path="))
+	startBusAt(t, address)
+	kwin := startKWin(t, address, 1)
+	layouttest.ExpectEvent(t, events, kdeRU)
+
+	// Подписка на новой шине восстановлена
+	kwin.switchLayout(t, 2)
+	layouttest.ExpectEvent(t, events, kdeDE)
+}
+
+func TestKDELayoutList(t *testing.T) {
+	address := startBus(t)
+	kwin := startKWin(t, address, 0)
+
+	w, err := newKDELayoutWatcher(func() (*dbus.Conn, error) { return dbus.Connect(address) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	want := []layout.LayoutInfo{
+		{Code: "us", Name: "English (US)"},
+		{Code: "ru", Name: "Russian"},
+		{Code: "de", Variant: "nodeadkeys", Name: "German (no dead keys)"},
+	}
+	if got, err := w.Layouts(); err != nil || !slices.Equal(got, want) {
+		t.Fatalf("Layouts() = %+v, %v, want %+v", got, err, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// Переключение берёт имя из кэша, без getLayoutsList
+	kwin.switchLayout(t, 2)
+	layouttest.ExpectEvent(t, events, kdeDE)
+	kwin.switchLayout(t, 1)
+	layouttest.ExpectEvent(t, events, kdeRU)
+	if n := kwin.listCalls(); n != 1 {
+		t.Errorf("getLayoutsList called %d times, want 1 (cached)", n)
+	}
+
+	// Новый список: текущий индекс 1 указывает на другую раскладку
+	kwin.setLayouts(t, kdeLayout{"us", "", "English (US)"}, kdeLayout{"ua", "", "Ukrainian"})
+	layouttest.ExpectEvent(t, events, layout.LayoutEvent{Index: 1, Layout: "ua", Name: "Ukrainian"})
+	want = []layout.LayoutInfo{{Code: "us", Name: "English (US)"}, {Code: "ua", Name: "Ukrainian"}}
+	if got, err := w.Layouts(); err != nil || !slices.Equal(got, want) {
+		t.Errorf("Layouts() after layoutListChanged = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestKDELayoutWatcherPlasma5(t *testing.T) {
+	address := startBus(t)
+	kded := startKDED(t, address, "ru")
+
+	w, err := newKDELayoutWatcher(func() (*dbus.Conn, error) { return dbus.Connect(address) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.registry, err = xkb.ParseRegistry(strings.NewReader("<xkbConfigRegistry/>")); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Раскладок нет в реестре XKB: имя - строка раскладки
+	want := layout.LayoutEvent{Index: 1, Layout: "ru", Name: "ru"}
+	if got, err := w.GetCurrentLayout(); err != nil || got != want {
+		t.Fatalf("GetCurrentLayout() = %+v, %v, want %+v", got, err, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	kded.switchLayout(t, "de(nodeadkeys)")
+	layouttest.ExpectEvent(t, events, layout.LayoutEvent{Index: 2, Layout: "de", Variant: "nodeadkeys", Name: "de(nodeadkeys)"})
+
+	// Раскладки нет в списке: индекс неизвестен
+	kded.switchLayout(t, "fr")
+	layouttest.ExpectEvent(t, events, layout.LayoutEvent{Index: -1, Layout: "fr", Name: "fr"})
+}
@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/jidckii/kolor-keyboard/pkg/config"
 	"github.com/jidckii/kolor-keyboard/pkg/hid"
 	hidlib "github.com/sstallion/go-hid"
 )
@@ -24,6 +26,7 @@ type DeviceInfo struct {
 	Usage        uint16
 	Manufacturer string
 	Product      string
+	Serial       string
 	Path         string
 	IsVial       bool
 	LEDCount     int
@@ -49,7 +52,8 @@ func FindVIADevices() ([]DeviceInfo, error) {
 	err := hidlib.Enumerate(0, 0, func(info *hidlib.DeviceInfo) error {
 		// Ищем устройства с VIA Usage Page
 		if info.UsagePage == VIAUsagePage && info.Usage == VIAUsage {
-			key := fmt.Sprintf("%04x:%04x", info.VendorID, info.ProductID)
+			// Одинаковые клавиатуры различаются серийным номером
+			key := fmt.Sprintf("%04x:%04x:%s", info.VendorID, info.ProductID, info.SerialNbr)
 			if !seen[key] {
 				seen[key] = true
 				devices = append(devices, DeviceInfo{
@@ -59,6 +63,7 @@ func FindVIADevices() ([]DeviceInfo, error) {
 					Usage:        info.Usage,
 					Manufacturer: info.MfrStr,
 					Product:      info.ProductStr,
+					Serial:       info.SerialNbr,
 					Path:         info.Path,
 				})
 			}
@@ -76,6 +81,7 @@ func FindVIADevices() ([]DeviceInfo, error) {
 // CheckVialSupport проверяет поддерживает ли устройство Vial и возвращает количество LED
 func CheckVialSupport(dev *DeviceInfo) error {
 	device := hid.NewVIARGBDevice(dev.VendorID, dev.ProductID, dev.UsagePage, dev.Usage)
+	device.SetSerial(dev.Serial)
 
 	if err := device.Open(); err != nil {
 		return fmt.Errorf("cannot open device: %w", err)
@@ -101,6 +107,7 @@ func CheckVialSupport(dev *DeviceInfo) error {
 // RunLEDMappingTour запускает интерактивный тур для маппинга LED по рядам
 func RunLEDMappingTour(dev *DeviceInfo) ([][]int, error) {
 	device := hid.NewVIARGBDevice(dev.VendorID, dev.ProductID, dev.UsagePage, dev.Usage)
+	device.SetSerial(dev.Serial)
 
 	if err := device.Open(); err != nil {
 		return nil, fmt.Errorf("failed to open device: %w", err)
@@ -260,6 +267,7 @@ func GenerateConfig(cfg *DiscoveredConfig) string {
 	sb.WriteString(fmt.Sprintf("# Generated for: %s %s\n", cfg.Device.Manufacturer, cfg.Device.Product))
 	sb.WriteString(fmt.Sprintf("# Keyboard: %s/%s/%s\n", vendor, model, variant))
 	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("version: %d\n", config.CurrentVersion))
 
 	sb.WriteString("device:\n")
 	sb.WriteString(fmt.Sprintf("  vendor_id: 0x%04X\n", cfg.Device.VendorID))
@@ -282,7 +290,7 @@ func GenerateConfig(cfg *DiscoveredConfig) string {
 		sb.WriteString("  rows:\n")
 		for i, row := range cfg.KeyboardRows {
 			sb.WriteString(fmt.Sprintf("    # Row %d (%d LEDs)\n", i, len(row)))
-			sb.WriteString(fmt.Sprintf("    - %v\n", row))
+			sb.WriteString(fmt.Sprintf("    - %s\n", yamlInts(row)))
 		}
 		sb.WriteString("\n")
 		sb.WriteString("draw:\n")
@@ -304,15 +312,15 @@ func GenerateConfig(cfg *DiscoveredConfig) string {
 			whiteRows := allRows[:third]
 			blueRows := allRows[third : third*2]
 			redRows := allRows[third*2:]
-			sb.WriteString(fmt.Sprintf("      - rows: %v\n", whiteRows))
-			sb.WriteString("        color: {rgb: {r: 255, g: 255, b: 255}}  # white\n")
-			sb.WriteString(fmt.Sprintf("      - rows: %v\n", blueRows))
-			sb.WriteString("        color: {rgb: {r: 0, g: 50, b: 255}}    # blue\n")
-			sb.WriteString(fmt.Sprintf("      - rows: %v\n", redRows))
-			sb.WriteString("        color: {rgb: {r: 255, g: 0, b: 0}}      # red\n")
+			sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(whiteRows)))
+			sb.WriteString("        color: " + yamlColor(255, 255, 255) + "  # white\n")
+			sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(blueRows)))
+			sb.WriteString("        color: " + yamlColor(0, 50, 255) + "  # blue\n")
+			sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(redRows)))
+			sb.WriteString("        color: " + yamlColor(255, 0, 0) + "  # red\n")
 		} else {
-			sb.WriteString(fmt.Sprintf("      - rows: %v\n", allRows))
-			sb.WriteString("        color: {rgb: {r: 255, g: 0, b: 0}}\n")
+			sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(allRows)))
+			sb.WriteString("        color: " + yamlColor(255, 0, 0) + "\n")
 		}
 		sb.WriteString("\n")
 
@@ -320,32 +328,32 @@ func GenerateConfig(cfg *DiscoveredConfig) string {
 		sb.WriteString("  # English - blue\n")
 		sb.WriteString("  - layout: us\n")
 		sb.WriteString("    stripes:\n")
-		sb.WriteString(fmt.Sprintf("      - rows: %v\n", allRows))
-		sb.WriteString("        color: {rgb: {r: 0, g: 100, b: 255}}\n")
+		sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(allRows)))
+		sb.WriteString("        color: " + yamlColor(0, 100, 255) + "\n")
 		sb.WriteString("\n")
 
 		// German - gold
 		sb.WriteString("  # German - gold/yellow\n")
 		sb.WriteString("  - layout: de\n")
 		sb.WriteString("    stripes:\n")
-		sb.WriteString(fmt.Sprintf("      - rows: %v\n", allRows))
-		sb.WriteString("        color: {rgb: {r: 255, g: 200, b: 0}}\n")
+		sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(allRows)))
+		sb.WriteString("        color: " + yamlColor(255, 200, 0) + "\n")
 		sb.WriteString("\n")
 
 		// French - blue
 		sb.WriteString("  # French - blue\n")
 		sb.WriteString("  - layout: fr\n")
 		sb.WriteString("    stripes:\n")
-		sb.WriteString(fmt.Sprintf("      - rows: %v\n", allRows))
-		sb.WriteString("        color: {rgb: {r: 0, g: 50, b: 200}}\n")
+		sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(allRows)))
+		sb.WriteString("        color: " + yamlColor(0, 50, 200) + "\n")
 		sb.WriteString("\n")
 
 		// Spanish - orange
 		sb.WriteString("  # Spanish - orange\n")
 		sb.WriteString("  - layout: es\n")
 		sb.WriteString("    stripes:\n")
-		sb.WriteString(fmt.Sprintf("      - rows: %v\n", allRows))
-		sb.WriteString("        color: {rgb: {r: 255, g: 100, b: 0}}\n")
+		sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(allRows)))
+		sb.WriteString("        color: " + yamlColor(255, 100, 0) + "\n")
 		sb.WriteString("\n")
 
 		// Ukrainian - blue/yellow flag
@@ -356,13 +364,13 @@ func GenerateConfig(cfg *DiscoveredConfig) string {
 			half := rowCount / 2
 			blueRows := allRows[:half]
 			yellowRows := allRows[half:]
-			sb.WriteString(fmt.Sprintf("      - rows: %v\n", blueRows))
-			sb.WriteString("        color: {rgb: {r: 0, g: 90, b: 200}}    # blue\n")
-			sb.WriteString(fmt.Sprintf("      - rows: %v\n", yellowRows))
-			sb.WriteString("        color: {rgb: {r: 255, g: 215, b: 0}}   # yellow\n")
+			sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(blueRows)))
+			sb.WriteString("        color: " + yamlColor(0, 90, 200) + "  # blue\n")
+			sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(yellowRows)))
+			sb.WriteString("        color: " + yamlColor(255, 215, 0) + "  # yellow\n")
 		} else {
-			sb.WriteString(fmt.Sprintf("      - rows: %v\n", allRows))
-			sb.WriteString("        color: {rgb: {r: 0, g: 90, b: 200}}\n")
+			sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(allRows)))
+			sb.WriteString("        color: " + yamlColor(0, 90, 200) + "\n")
 		}
 		sb.WriteString("\n")
 
@@ -370,8 +378,8 @@ func GenerateConfig(cfg *DiscoveredConfig) string {
 		sb.WriteString("  # Fallback - green\n")
 		sb.WriteString("  - layout: \"*\"\n")
 		sb.WriteString("    stripes:\n")
-		sb.WriteString(fmt.Sprintf("      - rows: %v\n", allRows))
-		sb.WriteString("        color: {rgb: {r: 0, g: 255, b: 0}}\n")
+		sb.WriteString(fmt.Sprintf("      - rows: %s\n", yamlInts(allRows)))
+		sb.WriteString("        color: " + yamlColor(0, 255, 0) + "\n")
 	} else {
 		sb.WriteString("mode: mono\n")
 		sb.WriteString("\n")
@@ -381,64 +389,78 @@ func GenerateConfig(cfg *DiscoveredConfig) string {
 		sb.WriteString("colors:\n")
 		sb.WriteString("  # Russian - red (flag color)\n")
 		sb.WriteString("  - layout: ru\n")
-		sb.WriteString("    color: {rgb: {r: 255, g: 0, b: 0}}\n")
+		sb.WriteString("    color: " + yamlColor(255, 0, 0) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # English - blue\n")
 		sb.WriteString("  - layout: us\n")
-		sb.WriteString("    color: {rgb: {r: 0, g: 100, b: 255}}\n")
+		sb.WriteString("    color: " + yamlColor(0, 100, 255) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # German - gold/yellow (flag color)\n")
 		sb.WriteString("  - layout: de\n")
-		sb.WriteString("    color: {rgb: {r: 255, g: 200, b: 0}}\n")
+		sb.WriteString("    color: " + yamlColor(255, 200, 0) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # French - blue (flag color)\n")
 		sb.WriteString("  - layout: fr\n")
-		sb.WriteString("    color: {rgb: {r: 0, g: 50, b: 200}}\n")
+		sb.WriteString("    color: " + yamlColor(0, 50, 200) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Spanish - orange/red (flag color)\n")
 		sb.WriteString("  - layout: es\n")
-		sb.WriteString("    color: {rgb: {r: 255, g: 100, b: 0}}\n")
+		sb.WriteString("    color: " + yamlColor(255, 100, 0) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Italian - green (flag color)\n")
 		sb.WriteString("  - layout: it\n")
-		sb.WriteString("    color: {rgb: {r: 0, g: 200, b: 80}}\n")
+		sb.WriteString("    color: " + yamlColor(0, 200, 80) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Ukrainian - blue (flag color)\n")
 		sb.WriteString("  - layout: ua\n")
-		sb.WriteString("    color: {rgb: {r: 0, g: 90, b: 200}}\n")
+		sb.WriteString("    color: " + yamlColor(0, 90, 200) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Turkish - red (flag color)\n")
 		sb.WriteString("  - layout: tr\n")
-		sb.WriteString("    color: {rgb: {r: 200, g: 0, b: 0}}\n")
+		sb.WriteString("    color: " + yamlColor(200, 0, 0) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Arabic - green (pan-Arab color)\n")
 		sb.WriteString("  - layout: ar\n")
-		sb.WriteString("    color: {rgb: {r: 0, g: 150, b: 50}}\n")
+		sb.WriteString("    color: " + yamlColor(0, 150, 50) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Chinese - red (flag color)\n")
 		sb.WriteString("  - layout: cn\n")
-		sb.WriteString("    color: {rgb: {r: 220, g: 0, b: 0}}\n")
+		sb.WriteString("    color: " + yamlColor(220, 0, 0) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Japanese - white/red (flag inspired)\n")
 		sb.WriteString("  - layout: jp\n")
-		sb.WriteString("    color: {rgb: {r: 255, g: 100, b: 100}}\n")
+		sb.WriteString("    color: " + yamlColor(255, 100, 100) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Korean - blue (flag color)\n")
 		sb.WriteString("  - layout: kr\n")
-		sb.WriteString("    color: {rgb: {r: 0, g: 70, b: 170}}\n")
+		sb.WriteString("    color: " + yamlColor(0, 70, 170) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Portuguese - green (flag color)\n")
 		sb.WriteString("  - layout: pt\n")
-		sb.WriteString("    color: {rgb: {r: 0, g: 180, b: 60}}\n")
+		sb.WriteString("    color: " + yamlColor(0, 180, 60) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Polish - white (flag color)\n")
 		sb.WriteString("  - layout: pl\n")
-		sb.WriteString("    color: {rgb: {r: 255, g: 255, b: 255}}\n")
+		sb.WriteString("    color: " + yamlColor(255, 255, 255) + "\n")
 		sb.WriteString("\n")
 		sb.WriteString("  # Fallback - green\n")
 		sb.WriteString("  - layout: \"*\"\n")
-		sb.WriteString("    color: {rgb: {r: 0, g: 255, b: 0}}\n")
+		sb.WriteString("    color: " + yamlColor(0, 255, 0) + "\n")
 	}
 
 	return sb.String()
 }
+
+// yamlColor форматирует цвет для конфига ("#rrggbb", см. config.ParseColor)
+func yamlColor(r, g, b uint8) string {
+	return fmt.Sprintf("%q", config.RGBColor{R: r, G: g, B: b}.String())
+}
+
+// yamlInts форматирует список индексов как YAML flow sequence: [0, 1, 2]
+func yamlInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
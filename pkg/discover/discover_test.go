@@ -1,8 +1,12 @@
 package discover
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
 )
 
 func TestGenerateConfig(t *testing.T) {
@@ -26,6 +30,7 @@ func TestGenerateConfig(t *testing.T) {
 				KeyboardRows: [][]int{{0, 1, 2}, {3, 4, 5}},
 			},
 			wantContains: []string{
+				"version: 1",
 				"vendor_id: 0x3434",
 				"product_id: 0x0331",
 				"usage_page: 0xFF60",
@@ -34,8 +39,8 @@ func TestGenerateConfig(t *testing.T) {
 				"mode: draw",
 				"keyboard:",
 				"rows:",
-				"- [0 1 2]",
-				"- [3 4 5]",
+				"- [0, 1, 2]",
+				"- [3, 4, 5]",
 				"draw:",
 				"stripes:",
 			},
@@ -138,12 +143,12 @@ func TestGenerateConfigVialWithRows(t *testing.T) {
 	}
 
 	// Check all rows index
-	if !strings.Contains(config, "- rows: [0 1 2]") {
+	if !strings.Contains(config, "- rows: [0, 1, 2]") {
 		t.Error("Missing rows index")
 	}
 
 	// Check default color
-	if !strings.Contains(config, "color: {rgb: {r: 0, g: 255, b: 0}}") {
+	if !strings.Contains(config, `color: "#00ff00"`) {
 		t.Error("Missing default color")
 	}
 }
@@ -181,3 +186,59 @@ func TestGenerateConfigStockFirmware(t *testing.T) {
 		t.Error("Mono mode should not have keyboard section")
 	}
 }
+
+func TestGenerateConfigRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *DiscoveredConfig
+		wantMode config.Mode
+	}{
+		{
+			name: "vial draw",
+			cfg: &DiscoveredConfig{
+				Device:       DeviceInfo{VendorID: 0x3434, ProductID: 0x0331, UsagePage: 0xFF60, Usage: 0x61},
+				Firmware:     "vial",
+				KeyboardRows: [][]int{{0, 1, 2}, {3, 4, 5}, {6, 7}},
+			},
+			wantMode: config.ModeDraw,
+		},
+		{
+			name: "stock mono",
+			cfg: &DiscoveredConfig{
+				Device:   DeviceInfo{VendorID: 0x3434, ProductID: 0x0331, UsagePage: 0xFF60, Usage: 0x61},
+				Firmware: "stock",
+			},
+			wantMode: config.ModeMono,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(GenerateConfig(tt.cfg)), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			cfg, err := config.Load(path)
+			if err != nil {
+				t.Fatalf("generated config does not load: %v", err)
+			}
+			if cfg.Mode != tt.wantMode {
+				t.Errorf("Mode = %s, want %s", cfg.Mode, tt.wantMode)
+			}
+
+			red := config.RGBColor{R: 255}
+			if cfg.Mode == config.ModeDraw {
+				if len(cfg.Keyboard.Rows) != len(tt.cfg.KeyboardRows) {
+					t.Errorf("len(Rows) = %d, want %d", len(cfg.Keyboard.Rows), len(tt.cfg.KeyboardRows))
+				}
+				ru := cfg.GetFlagForLayout("ru")
+				if got := ru.Stripes[len(ru.Stripes)-1].Color; got != red {
+					t.Errorf("ru last stripe = %v, want %v", got, red)
+				}
+			} else if got := cfg.GetColorForLayout("ru"); *got != red {
+				t.Errorf("ru color = %v, want %v", *got, red)
+			}
+		})
+	}
+}
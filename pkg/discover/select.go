@@ -0,0 +1,87 @@
+package discover
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+// ConfigDir возвращает каталог настроек: $XDG_CONFIG_HOME/kolor-keyboard или ~/.config/kolor-keyboard
+func ConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "kolor-keyboard")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "kolor-keyboard")
+}
+
+// KeyboardsDir возвращает каталог конфигов, сохранённых discover --global
+func KeyboardsDir() string {
+	return filepath.Join(ConfigDir(), "keyboards")
+}
+
+// FindConfigs возвращает все config.yaml в каталоге и его подкаталогах в алфавитном порядке
+func FindConfigs(dir string) []string {
+	var paths []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Недоступный каталог пропускается, остальные просматриваются
+			return nil
+		}
+		if !d.IsDir() && d.Name() == "config.yaml" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
+
+// SelectConfig выбирает конфиг для подключённых клавиатур
+// Конфиг подходит, если его device совпадает с клавиатурой по VID/PID и serial (если указан).
+// Конфиг с serial точнее и выбирается раньше конфига без него, при равенстве - первый по порядку.
+// Файлы, которые не читаются, пропускаются: их ошибки покажет validate
+// Возвращает "" и nil, если подходящего конфига нет
+func SelectConfig(paths []string, devices []DeviceInfo) (string, *DeviceInfo) {
+	var (
+		best     string
+		bestDev  *DeviceInfo
+		bySerial bool
+	)
+	for _, path := range paths {
+		dev, err := config.LoadDevice(path)
+		if err != nil {
+			continue
+		}
+		for i := range devices {
+			d := &devices[i]
+			if d.VendorID != dev.VendorID || d.ProductID != dev.ProductID {
+				continue
+			}
+			if dev.Serial != "" && dev.Serial != d.Serial {
+				continue
+			}
+			if best == "" || (dev.Serial != "" && !bySerial) {
+				best, bestDev, bySerial = path, d, dev.Serial != ""
+			}
+			break
+		}
+	}
+	return best, bestDev
+}
+
+// ConnectedConfig ищет в KeyboardsDir конфиг для подключённой клавиатуры
+// Возвращает "", если ни одна подключённая клавиатура не описана
+func ConnectedConfig() (string, error) {
+	paths := FindConfigs(KeyboardsDir())
+	if len(paths) == 0 {
+		return "", nil
+	}
+	devices, err := FindVIADevices()
+	if err != nil {
+		return "", err
+	}
+	path, _ := SelectConfig(paths, devices)
+	return path, nil
+}
@@ -0,0 +1,151 @@
+package discover
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConfigDir(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	tests := []struct {
+		xdg  string
+		want string
+	}{
+		{"/tmp/xdg", "/tmp/xdg/kolor-keyboard"},
+		{"", filepath.Join(home, ".config", "kolor-keyboard")},
+		// Относительный путь по спецификации XDG игнорируется
+		{"relative", filepath.Join(home, ".config", "kolor-keyboard")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.xdg, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", tt.xdg)
+			if got := ConfigDir(); got != tt.want {
+				t.Errorf("ConfigDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"keychron/v3/ansi/config.yaml":       "device: {vendor_id: 0x3434, product_id: 0x0331}\n",
+		"keychron/v3/iso/config.yaml":        "device: {vendor_id: 0x3434, product_id: 0x0332}\n",
+		"keychron/v3/office/config.yaml":     "device: {vendor_id: 0x3434, product_id: 0x0331, serial: B2}\n",
+		"keychron/v3/ansi/notes.yaml":        "device: {vendor_id: 0x3434, product_id: 0x0333}\n",
+		"broken/config.yaml":                 "device: [\n",
+		"acme/k1/default/nested/config.yaml": "device: {vendor_id: 0x1234, product_id: 0x0001}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := FindConfigs(dir)
+	var rel []string
+	for _, p := range paths {
+		r, _ := filepath.Rel(dir, p)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	wantPaths := []string{
+		"acme/k1/default/nested/config.yaml",
+		"broken/config.yaml",
+		"keychron/v3/ansi/config.yaml",
+		"keychron/v3/iso/config.yaml",
+		"keychron/v3/office/config.yaml",
+	}
+	if !slices.Equal(rel, wantPaths) {
+		t.Fatalf("FindConfigs() = %v, want %v", rel, wantPaths)
+	}
+
+	tests := []struct {
+		name    string
+		devices []DeviceInfo
+		want    string
+	}{
+		{"nothing connected", nil, ""},
+		{"unknown keyboard", []DeviceInfo{{VendorID: 0x5555, ProductID: 1}}, ""},
+		{"by vid and pid", []DeviceInfo{{VendorID: 0x3434, ProductID: 0x0332}}, "keychron/v3/iso/config.yaml"},
+		{"serial wins", []DeviceInfo{{VendorID: 0x3434, ProductID: 0x0331, Serial: "B2"}}, "keychron/v3/office/config.yaml"},
+		{"other serial", []DeviceInfo{{VendorID: 0x3434, ProductID: 0x0331, Serial: "A1"}}, "keychron/v3/ansi/config.yaml"},
+		{"first config in order", []DeviceInfo{
+			{VendorID: 0x3434, ProductID: 0x0332},
+			{VendorID: 0x1234, ProductID: 0x0001},
+		}, "acme/k1/default/nested/config.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dev := SelectConfig(paths, tt.devices)
+			if tt.want == "" {
+				if got != "" || dev != nil {
+					t.Errorf("SelectConfig() = %q, want none", got)
+				}
+				return
+			}
+			if got != filepath.Join(dir, filepath.FromSlash(tt.want)) {
+				t.Errorf("SelectConfig() = %q, want %q", got, tt.want)
+			}
+			if dev == nil {
+				t.Errorf("SelectConfig() device = nil")
+			}
+		})
+	}
+}
+
+func TestWatchDevices(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := watchDevices(ctx, dir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("watchDevices() error = %v", err)
+	}
+
+	expect := func(what string, want bool) {
+		t.Helper()
+		select {
+		case <-changes:
+			if !want {
+				t.Fatalf("unexpected notification after %s", what)
+			}
+		case <-time.After(300 * time.Millisecond):
+			if want {
+				t.Fatalf("no notification after %s", what)
+			}
+		}
+	}
+
+	// Другие устройства не интересны
+	if err := os.WriteFile(filepath.Join(dir, "ttyUSB0"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	expect("unrelated device", false)
+
+	node := filepath.Join(dir, "hidraw3")
+	if err := os.WriteFile(node, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	expect("plug", true)
+
+	if err := os.Remove(node); err != nil {
+		t.Fatal(err)
+	}
+	expect("unplug", true)
+
+	cancel()
+	if _, ok := <-changes; ok {
+		t.Error("channel not closed after cancel")
+	}
+}
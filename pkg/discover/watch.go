@@ -0,0 +1,75 @@
+package discover
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// devDir - каталог, в котором появляются узлы /dev/hidrawN
+const devDir = "/dev"
+
+// deviceDebounce - пауза после последнего подключения или отключения перед уведомлением
+// Клавиатура создаёт несколько hidraw узлов, а udev выдаёт права на них не сразу
+const deviceDebounce = time.Second
+
+// WatchDevices сообщает о подключении и отключении HID устройств (узлы /dev/hidraw*)
+// Канал получает значение после каждой серии изменений и закрывается при отмене ctx
+func WatchDevices(ctx context.Context) (<-chan struct{}, error) {
+	return watchDevices(ctx, devDir, deviceDebounce)
+}
+
+func watchDevices(ctx context.Context, dir string, debounce time.Duration) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create device watcher: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		defer close(changes)
+		defer watcher.Close()
+
+		timer := time.NewTimer(debounce)
+		timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !strings.HasPrefix(filepath.Base(event.Name), "hidraw") {
+					continue
+				}
+				if event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Chmod) == 0 {
+					continue
+				}
+				timer.Reset(debounce)
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			case <-timer.C:
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,258 @@
+package external
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+// Перезапуск команды после выхода
+const (
+	restartDelay    = time.Second
+	maxRestartDelay = 30 * time.Second
+)
+
+// Watcher реализует LayoutWatcher для внешнего источника: долгоживущей команды,
+// именованного канала (FIFO) или stdin. Источник пишет по строке на смену раскладки:
+// код XKB ("us", "us(dvorak)") или JSON события ({"layout": "ru", "index": 1}).
+// Так раскладку (или режим vim, окно tmux) может сообщать любой скрипт
+type Watcher struct {
+	command  []string // команда и аргументы, nil - читается pipe
+	pipe     string   // путь к FIFO, "-" - stdin
+	registry *xkb.Registry
+	retry    time.Duration // первая пауза перед перезапуском команды
+	cancel   context.CancelFunc
+
+	mu   sync.Mutex
+	last layout.LayoutEvent
+}
+
+// NewCommandWatcher создаёт watcher, который запускает команду и читает её stdout
+// Команда перезапускается после выхода с растущей паузой
+func NewCommandWatcher(args []string) (*Watcher, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("external command is not set")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("external command: %w", err)
+	}
+	return newWatcher(args, "", xkb.Default()), nil
+}
+
+// NewPipeWatcher создаёт watcher, который читает именованный канал ("-" - stdin)
+// Если канала нет, он создаётся
+func NewPipeWatcher(path string) (*Watcher, error) {
+	if path != "-" {
+		if err := makeFIFO(path); err != nil {
+			return nil, err
+		}
+	}
+	return newWatcher(nil, path, xkb.Default()), nil
+}
+
+func newWatcher(command []string, pipe string, registry *xkb.Registry) *Watcher {
+	return &Watcher{
+		command:  command,
+		pipe:     pipe,
+		registry: registry,
+		retry:    restartDelay,
+		last:     layout.LayoutEvent{Index: -1},
+	}
+}
+
+// makeFIFO проверяет, что path - именованный канал, и создаёт его, если файла нет
+func makeFIFO(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := syscall.Mkfifo(path, 0600); err != nil {
+			return fmt.Errorf("failed to create pipe %s: %w", path, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeNamedPipe == 0 {
+		return fmt.Errorf("%s is not a named pipe", path)
+	}
+	return nil
+}
+
+// jsonEvent - событие в JSON, поля как у LayoutEvent
+type jsonEvent struct {
+	Index   *int   `json:"index"` // nil - неизвестен
+	Layout  string `json:"layout"`
+	Variant string `json:"variant"`
+	Name    string `json:"name"`
+	Engine  string `json:"engine"`
+	IME     bool   `json:"ime"`
+}
+
+// parseLine разбирает строку источника: код раскладки или JSON события
+// Пустые строки и комментарии (#) пропускаются, ok = false
+func parseLine(line string, registry *xkb.Registry) (event layout.LayoutEvent, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return event, false, nil
+	}
+
+	event.Index = -1
+	if strings.HasPrefix(line, "{") {
+		var e jsonEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return event, false, fmt.Errorf("invalid event %q: %w", line, err)
+		}
+		if e.Index != nil {
+			event.Index = *e.Index
+		}
+		event.Layout, event.Variant, event.Name = e.Layout, e.Variant, e.Name
+		event.Engine, event.IME = e.Engine, e.IME
+	} else {
+		event.Layout = line
+	}
+
+	// "us(dvorak)" в layout - код с вариантом
+	if code, variant, found := strings.Cut(event.Layout, "("); found && event.Variant == "" && strings.HasSuffix(variant, ")") {
+		event.Layout, event.Variant = code, strings.TrimSuffix(variant, ")")
+	}
+	if event.Layout == "" && event.Name == "" && event.Index < 0 {
+		return event, false, fmt.Errorf("event %q has no layout, name or index", line)
+	}
+	if event.Name == "" && event.Layout != "" {
+		event.Name = registry.Description(event.Layout, event.Variant)
+	}
+	return event, true, nil
+}
+
+// GetCurrentLayout возвращает последнюю раскладку от источника
+// До первой строки раскладка неизвестна: событие без кода с индексом -1
+func (w *Watcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.last, nil
+}
+
+// Layouts не поддерживается: источник сообщает только текущую раскладку
+func (w *Watcher) Layouts() ([]layout.LayoutInfo, error) {
+	return nil, nil
+}
+
+// Watch запускает команду или открывает канал и отслеживает строки источника
+func (w *Watcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	var input io.ReadCloser
+	switch w.pipe {
+	case "":
+	case "-":
+		input = os.Stdin
+	default:
+		// O_RDWR: открытие не ждёт писателя, а когда писатели закрывают канал,
+		// чтение не получает EOF - канал работает, пока работает watcher
+		f, err := os.OpenFile(w.pipe, os.O_RDWR, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pipe: %w", err)
+		}
+		input = f
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	events := make(chan layout.LayoutEvent, 10)
+	go func() {
+		defer close(events)
+		if input == nil {
+			w.runCommand(ctx, events)
+			return
+		}
+
+		go func() {
+			// Закрытие прерывает чтение
+			<-ctx.Done()
+			input.Close()
+		}()
+		w.read(ctx, input, events)
+		// stdin закончился: раскладка больше не меняется, но источник не считается упавшим
+		<-ctx.Done()
+	}()
+	return events, nil
+}
+
+// runCommand запускает команду и перезапускает её после выхода, пока ctx не отменён
+func (w *Watcher) runCommand(ctx context.Context, events chan<- layout.LayoutEvent) {
+	delay := w.retry
+	for {
+		start := time.Now()
+		cmd := exec.CommandContext(ctx, w.command[0], w.command[1:]...)
+		cmd.Stderr = os.Stderr
+		// Потомки команды могут держать stdout открытым после её завершения
+		cmd.WaitDelay = time.Second
+
+		stdout, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			w.read(ctx, stdout, events)
+			cmd.Wait()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Команда, которая проработала долго, перезапускается сразу после первой паузы
+		if time.Since(start) > maxRestartDelay {
+			delay = w.retry
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+// read читает строки источника до конца ввода и отправляет новые раскладки
+// Строки, которые не разбираются, пропускаются
+func (w *Watcher) read(ctx context.Context, r io.Reader, events chan<- layout.LayoutEvent) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		event, ok, err := parseLine(scanner.Text(), w.registry)
+		if err != nil || !ok {
+			continue
+		}
+
+		w.mu.Lock()
+		changed := event != w.last
+		w.last = event
+		w.mu.Unlock()
+		if !changed {
+			continue
+		}
+
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Close останавливает отслеживание и команду
+func (w *Watcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package external
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/layout/layouttest"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb/xkbtest"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    layout.LayoutEvent
+		wantOK  bool
+		wantErr bool
+	}{
+		{"code", "ru", layout.LayoutEvent{Index: -1, Layout: "ru", Name: "Russian"}, true, false},
+		{"code with variant", " us(dvorak)\r", layout.LayoutEvent{Index: -1, Layout: "us", Variant: "dvorak", Name: "English (Dvorak)"}, true, false},
+		{"unknown code", "vim-insert", layout.LayoutEvent{Index: -1, Layout: "vim-insert"}, true, false},
+		{"json", `{"layout": "ru", "index": 1}`, layout.LayoutEvent{Index: 1, Layout: "ru", Name: "Russian"}, true, false},
+		{"json with name", `{"layout": "mozc-jp", "name": "Mozc", "engine": "mozc-jp", "ime": true}`,
+			layout.LayoutEvent{Index: -1, Layout: "mozc-jp", Name: "Mozc", Engine: "mozc-jp", IME: true}, true, false},
+		{"json index only", `{"index": 0}`, layout.LayoutEvent{Index: 0}, true, false},
+		{"empty line", "  ", layout.LayoutEvent{}, false, false},
+		{"comment", "# layout follows", layout.LayoutEvent{}, false, false},
+		{"broken json", `{"layout": `, layout.LayoutEvent{}, false, true},
+		{"empty json", `{}`, layout.LayoutEvent{}, false, true},
+	}
+
+	registry := xkbtest.Registry(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseLine(tt.line, registry)
+			if (err != nil) != tt.wantErr || ok != tt.wantOK {
+				t.Fatalf("parseLine(%q) ok = %v, error = %v, want ok %v, error %v", tt.line, ok, err, tt.wantOK, tt.wantErr)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parseLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+var (
+	us = layout.LayoutEvent{Index: -1, Layout: "us", Name: "English (US)"}
+	ru = layout.LayoutEvent{Index: 1, Layout: "ru", Name: "Russian"}
+)
+
+func TestCommandWatcher(t *testing.T) {
+	if _, err := NewCommandWatcher([]string{"kolor-keyboard-no-such-command"}); err == nil {
+		t.Error("NewCommandWatcher() with a missing command succeeded")
+	}
+
+	// Команда сообщает две раскладки и выходит, повтор одной и той же пропускается
+	w := newWatcher([]string{"sh", "-c", `echo us; echo us; echo '{"layout": "ru", "index": 1}'`}, "", xkbtest.Registry(t))
+	w.retry = 10 * time.Millisecond
+	defer w.Close()
+
+	if got, err := w.GetCurrentLayout(); err != nil || got != (layout.LayoutEvent{Index: -1}) {
+		t.Errorf("GetCurrentLayout() before the first line = %+v, %v, want unknown layout", got, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	layouttest.ExpectEvent(t, events, us)
+	layouttest.ExpectEvent(t, events, ru)
+	if got, _ := w.GetCurrentLayout(); got != ru {
+		t.Errorf("GetCurrentLayout() = %+v, want %+v", got, ru)
+	}
+
+	// После выхода команда перезапускается
+	layouttest.ExpectEvent(t, events, us)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestPipeWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "layout")
+	w, err := NewPipeWatcher(path)
+	if err != nil {
+		t.Fatalf("NewPipeWatcher() error = %v", err)
+	}
+	w.registry = xkbtest.Registry(t)
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	write := func(line string) {
+		t.Helper()
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Каждый писатель открывает и закрывает канал, чтение продолжается
+	write("us")
+	layouttest.ExpectEvent(t, events, us)
+	write(`{"layout": "ru", "index": 1}`)
+	layouttest.ExpectEvent(t, events, ru)
+
+	cancel()
+	for range events {
+	}
+
+	// Обычный файл вместо канала
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPipeWatcher(file); err == nil || !strings.Contains(err.Error(), "not a named pipe") {
+		t.Errorf("NewPipeWatcher(regular file) error = %v, want not a named pipe", err)
+	}
+}
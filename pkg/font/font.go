@@ -0,0 +1,130 @@
+// Package font - компактный растровый шрифт 5 пикселей в высоту для рисунков на клавиатуре
+package font
+
+import (
+	"slices"
+	"unicode"
+)
+
+// Height - высота глифов (рассчитан на клавиатуры с 5-6 рядами)
+const Height = 5
+
+// glyphs - шрифт: латиница, кириллица, цифры и иероглифы кодов раскладок
+// '#' - закрашенный пиксель, '.' - пустой
+var glyphs = map[rune][]string{
+	' ': {"..", "..", "..", "..", ".."},
+	'-': {"...", "...", "###", "...", "..."},
+	'+': {"...", ".#.", "###", ".#.", "..."},
+	'.': {".", ".", ".", ".", "#"},
+	',': {"..", "..", "..", ".#", "#."},
+	':': {".", "#", ".", "#", "."},
+	'!': {"#", "#", "#", ".", "#"},
+	'?': {"##.", "..#", ".#.", "...", ".#."},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+	'(': {".#", "#.", "#.", "#.", ".#"},
+	')': {"#.", ".#", ".#", ".#", "#."},
+
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"##.", "..#", ".#.", "#..", "###"},
+	'3': {"##.", "..#", ".#.", "..#", "##."},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "##.", "..#", "##."},
+	'6': {".##", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", ".#.", ".#.", ".#."},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "##."},
+
+	'A': {".#.", "#.#", "###", "#.#", "#.#"},
+	'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'C': {".##", "#..", "#..", "#..", ".##"},
+	'D': {"##.", "#.#", "#.#", "#.#", "##."},
+	'E': {"###", "#..", "##.", "#..", "###"},
+	'F': {"###", "#..", "##.", "#..", "#.."},
+	'G': {".##", "#..", "#.#", "#.#", ".##"},
+	'H': {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I': {"###", ".#.", ".#.", ".#.", "###"},
+	'J': {"..#", "..#", "..#", "#.#", ".#."},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L': {"#..", "#..", "#..", "#..", "###"},
+	'M': {"#...#", "##.##", "#.#.#", "#...#", "#...#"},
+	'N': {"#..#", "##.#", "#.##", "#..#", "#..#"},
+	'O': {".#.", "#.#", "#.#", "#.#", ".#."},
+	'P': {"##.", "#.#", "##.", "#..", "#.."},
+	'Q': {".#.", "#.#", "#.#", "##.", ".##"},
+	'R': {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S': {".##", "#..", ".#.", "..#", "##."},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	'U': {"#.#", "#.#", "#.#", "#.#", "###"},
+	'V': {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W': {"#...#", "#...#", "#.#.#", "##.##", "#...#"},
+	'X': {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y': {"#.#", "#.#", ".#.", ".#.", ".#."},
+	'Z': {"###", "..#", ".#.", "#..", "###"},
+
+	'А': {".#.", "#.#", "###", "#.#", "#.#"},
+	'Б': {"###", "#..", "##.", "#.#", "##."},
+	'В': {"##.", "#.#", "##.", "#.#", "##."},
+	'Г': {"###", "#..", "#..", "#..", "#.."},
+	'Д': {".##.", ".#.#", ".#.#", "####", "#..#"},
+	'Е': {"###", "#..", "##.", "#..", "###"},
+	'Ё': {"#.#", "###", "#..", "##.", "###"},
+	'Ж': {"#.#.#", "#.#.#", ".###.", "#.#.#", "#.#.#"},
+	'З': {"##.", "..#", ".#.", "..#", "##."},
+	'И': {"#..#", "#..#", "#.##", "##.#", "#..#"},
+	'Й': {"#.##", "#..#", "#.##", "##.#", "#..#"},
+	'К': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'Л': {".##", "#.#", "#.#", "#.#", "#.#"},
+	'М': {"#...#", "##.##", "#.#.#", "#...#", "#...#"},
+	'Н': {"#.#", "#.#", "###", "#.#", "#.#"},
+	'О': {".#.", "#.#", "#.#", "#.#", ".#."},
+	'П': {"###", "#.#", "#.#", "#.#", "#.#"},
+	'Р': {"##.", "#.#", "##.", "#..", "#.."},
+	'С': {".##", "#..", "#..", "#..", ".##"},
+	'Т': {"###", ".#.", ".#.", ".#.", ".#."},
+	'У': {"#.#", "#.#", ".##", "..#", "##."},
+	'Ф': {".#.", "###", "#.#", "###", ".#."},
+	'Х': {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Ц': {"#.#.", "#.#.", "#.#.", "####", "...#"},
+	'Ч': {"#.#", "#.#", "###", "..#", "..#"},
+	'Ш': {"#.#.#", "#.#.#", "#.#.#", "#.#.#", "#####"},
+	'Щ': {"#.#.#.", "#.#.#.", "#.#.#.", "#####.", ".....#"},
+	'Ъ': {"##..", "#...", "###.", "#..#", "###."},
+	'Ы': {"#...#", "#...#", "###.#", "#.#.#", "###.#"},
+	'Ь': {"#..", "#..", "##.", "#.#", "##."},
+	'Э': {"##.", "..#", ".##", "..#", "##."},
+	'Ю': {"#..#.", "#.#.#", "###.#", "#.#.#", "#..#."},
+	'Я': {".##", "#.#", ".##", "#.#", "#.#"},
+	'І': {"###", ".#.", ".#.", ".#.", "###"},
+	'Ї': {"#.#", "...", ".#.", ".#.", ".#."},
+	'Є': {".##", "#..", "##.", "#..", ".##"},
+
+	// Иероглифы для подписей раскладок: 日本, 中文
+	'日': {"####", "#..#", "####", "#..#", "####"},
+	'本': {"..#..", "#####", ".###.", "#.#.#", "..#.."},
+	'中': {"..#..", "#####", "#.#.#", "#####", "..#.."},
+	'文': {"..#..", "#####", ".#.#.", "..#..", "##.##"},
+}
+
+// Glyph возвращает глиф символа (строчные буквы рисуются как заглавные)
+// ok = false, если символа нет в шрифте
+func Glyph(r rune) (glyph []string, ok bool) {
+	if g, ok := glyphs[r]; ok {
+		return g, true
+	}
+	if g, ok := glyphs[unicode.ToUpper(r)]; ok {
+		return g, true
+	}
+	return nil, false
+}
+
+// Missing возвращает символы строки, которых нет в шрифте, без повторов
+func Missing(s string) []rune {
+	var missing []rune
+	for _, r := range s {
+		if _, ok := Glyph(r); !ok && !slices.Contains(missing, r) {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
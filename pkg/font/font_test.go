@@ -0,0 +1,40 @@
+package font
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGlyphs(t *testing.T) {
+	for r, g := range glyphs {
+		if len(g) != Height {
+			t.Errorf("glyph %q: %d rows, want %d", r, len(g), Height)
+			continue
+		}
+		for row, line := range g {
+			if len(line) != len(g[0]) {
+				t.Errorf("glyph %q row %d: width %d, want %d", r, row, len(line), len(g[0]))
+			}
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	tests := []struct {
+		text string
+		want []rune
+	}{
+		{"RU", nil},
+		{"en", nil},
+		{"日本", nil},
+		{"中文", nil},
+		{"한국한", []rune{'한', '국'}},
+		{"A☺B", []rune{'☺'}},
+	}
+
+	for _, tt := range tests {
+		if got := Missing(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Missing(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
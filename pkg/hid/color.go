@@ -0,0 +1,121 @@
+package hid
+
+// Цветовая модель прошивки QMK
+// Прошивка получает HSV и переводит его в RGB целочисленным hsv_to_rgb из quantum/color.c,
+// поэтому конвертация здесь повторяет его побитово: иначе светодиод покажет не тот цвет,
+// который записан в конфиге
+
+// hsvSearchRadius - окрестность начальной оценки, в которой ищется лучший HSV
+const hsvSearchRadius = 8
+
+// HSVToRGB конвертирует HSV в RGB так же, как QMK hsv_to_rgb (без кривой CIE1931)
+func HSVToRGB(c HSVColor) (r, g, b uint8) {
+	if c.S == 0 {
+		return c.V, c.V, c.V
+	}
+
+	h, s, v := uint16(c.H), uint16(c.S), uint16(c.V)
+
+	region := h * 6 / 255
+	remainder := uint16(uint8((h*2 - region*85) * 3))
+
+	p := uint8((v * (255 - s)) >> 8)
+	q := uint8((v * (255 - ((s * remainder) >> 8))) >> 8)
+	t := uint8((v * (255 - ((s * (255 - remainder)) >> 8))) >> 8)
+	vv := uint8(v)
+
+	switch region {
+	case 6, 0:
+		return vv, t, p
+	case 1:
+		return q, vv, p
+	case 2:
+		return p, vv, t
+	case 3:
+		return p, q, vv
+	case 4:
+		return t, p, vv
+	default:
+		return vv, p, q
+	}
+}
+
+// RGBToHSV подбирает HSV, который прошивка покажет максимально близко к RGB
+// Начальная оценка считается целочисленно, затем перебираются соседние H и S:
+// V всегда равен максимальной компоненте, т.к. hsv_to_rgb выдаёт её без потерь
+func RGBToHSV(r, g, b uint8) HSVColor {
+	est := estimateHSV(r, g, b)
+	if est.S == 0 {
+		return est
+	}
+
+	best := est
+	bestDist := rgbDistance(est, r, g, b)
+
+	for dh := -hsvSearchRadius; dh <= hsvSearchRadius && bestDist > 0; dh++ {
+		for ds := -hsvSearchRadius; ds <= hsvSearchRadius; ds++ {
+			s := int(est.S) + ds
+			if s < 1 || s > 255 {
+				continue
+			}
+			c := HSVColor{H: uint8(int(est.H) + dh), S: uint8(s), V: est.V}
+			if d := rgbDistance(c, r, g, b); d < bestDist {
+				best, bestDist = c, d
+			}
+		}
+	}
+
+	return best
+}
+
+// DisplayedRGB возвращает цвет, который светодиод покажет для запрошенного RGB
+// brightness - глобальная яркость (0-255), V масштабируется так же, как в SetLEDs
+func DisplayedRGB(r, g, b, brightness uint8) (uint8, uint8, uint8) {
+	hsv := RGBToHSV(r, g, b)
+	hsv.V = scaleBrightness(hsv.V, brightness)
+	return HSVToRGB(hsv)
+}
+
+// estimateHSV - целочисленная конвертация RGB в HSV (обратная к hsv_to_rgb с точностью до округления)
+// Оттенок делится на шесть секторов по 43 единицы: красный 0, зелёный 85, синий 171
+func estimateHSV(r, g, b uint8) HSVColor {
+	ri, gi, bi := int(r), int(g), int(b)
+	maxC := max(ri, gi, bi)
+	minC := min(ri, gi, bi)
+	delta := maxC - minC
+
+	if maxC == 0 || delta == 0 {
+		return HSVColor{V: uint8(maxC)}
+	}
+
+	var h int
+	switch maxC {
+	case ri:
+		h = 43 * (gi - bi) / delta
+	case gi:
+		h = 85 + 43*(bi-ri)/delta
+	default:
+		h = 171 + 43*(ri-gi)/delta
+	}
+
+	return HSVColor{
+		H: uint8(h), // отрицательный оттенок заворачивается как uint8 в C
+		S: uint8(255 * delta / maxC),
+		V: uint8(maxC),
+	}
+}
+
+// rgbDistance - квадрат расстояния между цветом прошивки для c и целевым RGB
+func rgbDistance(c HSVColor, r, g, b uint8) int {
+	cr, cg, cb := HSVToRGB(c)
+	dr := int(cr) - int(r)
+	dg := int(cg) - int(g)
+	db := int(cb) - int(b)
+	return dr*dr + dg*dg + db*db
+}
+
+// scaleBrightness применяет глобальную яркость к V: v * brightness / 255
+// Общая для отправки цветов (SetLEDs) и предпросмотра (DisplayedRGB)
+func scaleBrightness(v, brightness uint8) uint8 {
+	return uint8(uint16(v) * uint16(brightness) / 255)
+}
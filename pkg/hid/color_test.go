@@ -0,0 +1,143 @@
+package hid
+
+import (
+	"testing"
+)
+
+// Ожидаемые значения - результат QMK hsv_to_rgb для тех же входов
+func TestHSVToRGB(t *testing.T) {
+	tests := []struct {
+		name    string
+		hsv     HSVColor
+		r, g, b uint8
+	}{
+		{"HSV_RED", HSVColor{0, 255, 255}, 255, 0, 0},
+		{"HSV_ORANGE", HSVColor{21, 255, 255}, 255, 126, 0},
+		{"HSV_YELLOW", HSVColor{43, 255, 255}, 252, 255, 0},
+		{"HSV_GREEN", HSVColor{85, 255, 255}, 0, 255, 0},
+		{"HSV_CYAN", HSVColor{128, 255, 255}, 0, 252, 255},
+		{"HSV_BLUE", HSVColor{170, 255, 255}, 0, 0, 255},
+		{"HSV_PURPLE", HSVColor{191, 255, 255}, 126, 0, 255},
+		{"HSV_MAGENTA", HSVColor{213, 255, 255}, 255, 0, 252},
+		{"hue 255 wraps to red", HSVColor{255, 255, 255}, 255, 0, 0},
+		{"HSV_WHITE", HSVColor{0, 0, 255}, 255, 255, 255},
+		{"gray", HSVColor{100, 0, 128}, 128, 128, 128},
+		{"HSV_OFF", HSVColor{0, 0, 0}, 0, 0, 0},
+		{"half saturation", HSVColor{0, 128, 200}, 200, 100, 99},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, g, b := HSVToRGB(tt.hsv)
+			if r != tt.r || g != tt.g || b != tt.b {
+				t.Errorf("HSVToRGB(%v) = (%d, %d, %d), want (%d, %d, %d)", tt.hsv, r, g, b, tt.r, tt.g, tt.b)
+			}
+		})
+	}
+}
+
+func TestRGBToHSVRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		r, g, b uint8
+		want    HSVColor
+	}{
+		{"red", 255, 0, 0, HSVColor{0, 255, 255}},
+		{"green", 0, 255, 0, HSVColor{85, 255, 255}},
+		{"blue", 0, 0, 255, HSVColor{170, 255, 255}},
+		{"white", 255, 255, 255, HSVColor{0, 0, 255}},
+		{"black", 0, 0, 0, HSVColor{0, 0, 0}},
+		{"gray", 128, 128, 128, HSVColor{0, 0, 128}},
+		{"qmk orange", 255, 126, 0, HSVColor{21, 255, 255}},
+		{"qmk purple", 126, 0, 255, HSVColor{191, 255, 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RGBToHSV(tt.r, tt.g, tt.b)
+			if got != tt.want {
+				t.Errorf("RGBToHSV(%d, %d, %d) = %v, want %v", tt.r, tt.g, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+// Подобранный HSV должен быть не хуже начальной оценки и не хуже наивного float-округления
+func TestRGBToHSVClosest(t *testing.T) {
+	colors := [][3]uint8{
+		{255, 136, 0},
+		{0, 100, 255},
+		{255, 255, 0},
+		{10, 20, 30},
+		{200, 50, 120},
+		{0, 0, 40},
+		{255, 200, 150},
+		{1, 2, 3},
+	}
+
+	for _, c := range colors {
+		got := RGBToHSV(c[0], c[1], c[2])
+		gotDist := rgbDistance(got, c[0], c[1], c[2])
+
+		if est := estimateHSV(c[0], c[1], c[2]); rgbDistance(est, c[0], c[1], c[2]) < gotDist {
+			t.Errorf("RGBToHSV(%v) = %v is worse than estimate %v", c, got, est)
+		}
+
+		// Полный перебор H и S при V = max(r, g, b)
+		best := gotDist
+		for h := 0; h < 256; h++ {
+			for s := 0; s < 256; s++ {
+				if d := rgbDistance(HSVColor{uint8(h), uint8(s), got.V}, c[0], c[1], c[2]); d < best {
+					best = d
+				}
+			}
+		}
+		if best < gotDist {
+			t.Errorf("RGBToHSV(%v) = %v has distance %d, exhaustive search found %d", c, got, gotDist, best)
+		}
+	}
+}
+
+func TestDisplayedRGB(t *testing.T) {
+	tests := []struct {
+		name       string
+		r, g, b    uint8
+		brightness uint8
+		wr, wg, wb uint8
+	}{
+		{"full brightness red", 255, 0, 0, 255, 255, 0, 0},
+		{"half brightness red", 255, 0, 0, 128, 128, 0, 0},
+		{"off", 255, 255, 255, 0, 0, 0, 0},
+		{"yellow loses a bit of red", 255, 255, 0, 255, 252, 255, 0},
+		{"half brightness white", 255, 255, 255, 127, 127, 127, 127},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, g, b := DisplayedRGB(tt.r, tt.g, tt.b, tt.brightness)
+			if r != tt.wr || g != tt.wg || b != tt.wb {
+				t.Errorf("DisplayedRGB(%d, %d, %d, %d) = (%d, %d, %d), want (%d, %d, %d)",
+					tt.r, tt.g, tt.b, tt.brightness, r, g, b, tt.wr, tt.wg, tt.wb)
+			}
+		})
+	}
+}
+
+func TestScaleBrightness(t *testing.T) {
+	tests := []struct {
+		v, brightness, want uint8
+	}{
+		{255, 255, 255},
+		{255, 0, 0},
+		{255, 128, 128},
+		{100, 255, 100},
+		{200, 127, 99},
+		{1, 254, 0},
+	}
+
+	for _, tt := range tests {
+		if got := scaleBrightness(tt.v, tt.brightness); got != tt.want {
+			t.Errorf("scaleBrightness(%d, %d) = %d, want %d", tt.v, tt.brightness, got, tt.want)
+		}
+	}
+}
@@ -20,6 +20,7 @@ type VIARGBDevice struct {
 	productID uint16
 	usagePage uint16
 	usage     uint16
+	serial    string // пусто - любой серийный номер
 
 	device     *hid.Device
 	mu         sync.Mutex
@@ -38,6 +39,11 @@ func NewVIARGBDevice(vendorID, productID, usagePage, usage uint16) *VIARGBDevice
 	}
 }
 
+// SetSerial ограничивает поиск устройства серийным номером
+func (d *VIARGBDevice) SetSerial(serial string) {
+	d.serial = serial
+}
+
 // Open открывает HID устройство
 func (d *VIARGBDevice) Open() error {
 	d.mu.Lock()
@@ -49,7 +55,7 @@ func (d *VIARGBDevice) Open() error {
 
 	var targetDevice *hid.DeviceInfo
 	err := hid.Enumerate(d.vendorID, d.productID, func(info *hid.DeviceInfo) error {
-		if info.UsagePage == d.usagePage && info.Usage == d.usage {
+		if info.UsagePage == d.usagePage && info.Usage == d.usage && (d.serial == "" || info.SerialNbr == d.serial) {
 			targetDevice = info
 		}
 		return nil
@@ -59,6 +65,9 @@ func (d *VIARGBDevice) Open() error {
 	}
 
 	if targetDevice == nil {
+		if d.serial != "" {
+			return fmt.Errorf("device not found: VID=%04X PID=%04X serial=%s", d.vendorID, d.productID, d.serial)
+		}
 		return fmt.Errorf("device not found: VID=%04X PID=%04X", d.vendorID, d.productID)
 	}
 
@@ -222,8 +231,7 @@ func (d *VIARGBDevice) SetLEDs(updates []LEDUpdate) error {
 
 		for j, u := range batch {
 			// Применяем глобальную яркость к V компоненту
-			v := uint16(u.Color.V) * uint16(d.brightness) / 255
-			colors[j] = HSVColor{H: u.Color.H, S: u.Color.S, V: uint8(v)}
+			colors[j] = HSVColor{H: u.Color.H, S: u.Color.S, V: scaleBrightness(u.Color.V, d.brightness)}
 		}
 
 		packet := BuildDirectSetPacket(startIndex, colors)
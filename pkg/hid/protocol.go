@@ -41,63 +41,6 @@ type HSVColor struct {
 	V uint8
 }
 
-// RGBToHSV конвертирует RGB в HSV
-func RGBToHSV(r, g, b uint8) HSVColor {
-	rf := float64(r) / 255.0
-	gf := float64(g) / 255.0
-	bf := float64(b) / 255.0
-
-	max := rf
-	if gf > max {
-		max = gf
-	}
-	if bf > max {
-		max = bf
-	}
-
-	min := rf
-	if gf < min {
-		min = gf
-	}
-	if bf < min {
-		min = bf
-	}
-
-	delta := max - min
-
-	var h, s, v float64
-	v = max
-
-	if max == 0 {
-		s = 0
-	} else {
-		s = delta / max
-	}
-
-	if delta == 0 {
-		h = 0
-	} else {
-		switch max {
-		case rf:
-			h = (gf - bf) / delta
-			if gf < bf {
-				h += 6
-			}
-		case gf:
-			h = 2 + (bf-rf)/delta
-		case bf:
-			h = 4 + (rf-gf)/delta
-		}
-		h /= 6
-	}
-
-	return HSVColor{
-		H: uint8(h * 255),
-		S: uint8(s * 255),
-		V: uint8(v * 255),
-	}
-}
-
 // BuildSetEffectPacket устанавливает эффект VIA RGB Matrix (для mono режима)
 func BuildSetEffectPacket(effect uint8) []byte {
 	packet := make([]byte, PacketSize)
@@ -0,0 +1,286 @@
+package hyprland
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+const (
+	requestSocket = ".socket.sock"  // запросы в стиле hyprctl
+	eventSocket   = ".socket2.sock" // поток событий "name>>data"
+)
+
+// keyboard - клавиатура из ответа j/devices
+type keyboard struct {
+	Name         string `json:"name"`
+	Layout       string `json:"layout"`  // "us,ru"
+	Variant      string `json:"variant"` // "dvorak,"
+	ActiveKeymap string `json:"active_keymap"`
+	Main         bool   `json:"main"`
+}
+
+// Watcher реализует LayoutWatcher для Hyprland через сокеты $XDG_RUNTIME_DIR/hypr/<подпись>/
+// Раскладка берётся с клавиатуры из фильтра, а без неё - с главной (main) или любой
+type Watcher struct {
+	dir      string
+	registry *xkb.Registry
+	cancel   context.CancelFunc
+
+	mu        sync.Mutex
+	filter    string              // имя клавиатуры, "" - любая
+	keyboards map[string]keyboard // последние известные настройки клавиатур
+}
+
+// SocketDir ищет каталог сокетов Hyprland по $HYPRLAND_INSTANCE_SIGNATURE
+// С версии 0.40 сокеты лежат в $XDG_RUNTIME_DIR/hypr, раньше - в /tmp/hypr
+func SocketDir() (string, error) {
+	sig := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if sig == "" {
+		return "", fmt.Errorf("HYPRLAND_INSTANCE_SIGNATURE is not set")
+	}
+
+	var dirs []string
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		dirs = append(dirs, filepath.Join(runtime, "hypr", sig))
+	}
+	dirs = append(dirs, filepath.Join("/tmp/hypr", sig))
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, eventSocket)); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("hyprland sockets not found in %s", strings.Join(dirs, ", "))
+}
+
+// NewWatcher создаёт watcher для текущего экземпляра Hyprland
+// keyboard - имя клавиатуры Hyprland ("keychron-keychron-v3"), "" - любая
+func NewWatcher(keyboard string) (*Watcher, error) {
+	dir, err := SocketDir()
+	if err != nil {
+		return nil, err
+	}
+	return newWatcher(dir, keyboard, xkb.Default()), nil
+}
+
+func newWatcher(dir, keyboard string, registry *xkb.Registry) *Watcher {
+	return &Watcher{dir: dir, registry: registry, filter: keyboard}
+}
+
+// request отправляет команду в .socket.sock и возвращает ответ целиком
+// Hyprland закрывает соединение после ответа
+func (w *Watcher) request(cmd string) ([]byte, error) {
+	c, err := net.Dial("unix", filepath.Join(w.dir, requestSocket))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to hyprland: %w", err)
+	}
+	defer c.Close()
+
+	if _, err := io.WriteString(c, cmd); err != nil {
+		return nil, fmt.Errorf("failed to send %q: %w", cmd, err)
+	}
+	data, err := io.ReadAll(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reply to %q: %w", cmd, err)
+	}
+	return data, nil
+}
+
+// devices запрашивает клавиатуры (hyprctl devices -j) и запоминает их раскладки
+func (w *Watcher) devices() ([]keyboard, error) {
+	data, err := w.request("j/devices")
+	if err != nil {
+		return nil, err
+	}
+	var reply struct {
+		Keyboards []keyboard `json:"keyboards"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return nil, fmt.Errorf("failed to parse devices: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.keyboards = make(map[string]keyboard, len(reply.Keyboards))
+	for _, k := range reply.Keyboards {
+		w.keyboards[k.Name] = k
+	}
+	return reply.Keyboards, nil
+}
+
+// GetCurrentLayout возвращает раскладку клавиатуры из фильтра или главной клавиатуры
+func (w *Watcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	chosen, err := w.keyboard()
+	if err != nil {
+		return layout.LayoutEvent{}, err
+	}
+	event, _ := w.event(chosen.Name, chosen.ActiveKeymap)
+	return event, nil
+}
+
+// Layouts возвращает раскладки клавиатуры из фильтра или главной клавиатуры
+func (w *Watcher) Layouts() ([]layout.LayoutInfo, error) {
+	chosen, err := w.keyboard()
+	if err != nil {
+		return nil, err
+	}
+	layouts := chosen.layouts()
+	for i := range layouts {
+		layouts[i].Name = w.registry.Description(layouts[i].Code, layouts[i].Variant)
+		if layouts[i].Name == "" {
+			layouts[i].Name = layouts[i].Code
+		}
+	}
+	return layouts, nil
+}
+
+// keyboard запрашивает клавиатуры и выбирает клавиатуру из фильтра, главную или любую
+func (w *Watcher) keyboard() (*keyboard, error) {
+	keyboards, err := w.devices()
+	if err != nil {
+		return nil, err
+	}
+
+	var chosen *keyboard
+	for i := range keyboards {
+		k := &keyboards[i]
+		if w.filter != "" && k.Name == w.filter {
+			return k, nil
+		}
+		if chosen == nil || (k.Main && !chosen.Main) {
+			chosen = k
+		}
+	}
+	if chosen == nil {
+		return nil, fmt.Errorf("no keyboards")
+	}
+	return chosen, nil
+}
+
+// layouts разбирает настройки клавиатуры в коды и варианты раскладок (без имён)
+func (k *keyboard) layouts() []layout.LayoutInfo {
+	codes := strings.Split(k.Layout, ",")
+	variants := strings.Split(k.Variant, ",")
+	layouts := make([]layout.LayoutInfo, len(codes))
+	for i, code := range codes {
+		layouts[i].Code = strings.TrimSpace(code)
+		if i < len(variants) {
+			layouts[i].Variant = strings.TrimSpace(variants[i])
+		}
+	}
+	return layouts
+}
+
+// event переводит имя раскладки в событие
+// Позиция, код и вариант ищутся в настройках клавиатуры (layout "us,ru", variant ",phonetic"):
+// имя сравнивается с описаниями этих раскладок в evdev.xml. Если клавиатура неизвестна,
+// код ищется по имени во всём реестре, found = false
+func (w *Watcher) event(name, keymap string) (event layout.LayoutEvent, found bool) {
+	event.Name, event.Index = keymap, -1
+
+	w.mu.Lock()
+	k, ok := w.keyboards[name]
+	w.mu.Unlock()
+
+	if ok {
+		for i, l := range k.layouts() {
+			if w.registry.Description(l.Code, l.Variant) == keymap {
+				event.Index, event.Layout, event.Variant = i, l.Code, l.Variant
+				return event, true
+			}
+		}
+	}
+
+	event.Layout, event.Variant, _ = w.registry.Lookup(keymap)
+	return event, false
+}
+
+// accept решает, брать ли раскладку с клавиатуры
+// Если клавиатуры из фильтра нет, подходит любая
+func (w *Watcher) accept(name string) bool {
+	if w.filter == "" || name == w.filter {
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.keyboards[w.filter]
+	return !ok
+}
+
+// parseActiveLayout разбирает данные события activelayout: "keyboard,English (US, intl.)"
+// Имя клавиатуры не содержит запятых, имя раскладки - может
+func parseActiveLayout(data string) (keyboard, keymap string, ok bool) {
+	return strings.Cut(data, ",")
+}
+
+// Watch читает события из .socket2.sock и отслеживает смену раскладки
+func (w *Watcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	c, err := net.Dial("unix", filepath.Join(w.dir, eventSocket))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to hyprland events: %w", err)
+	}
+
+	// Повтор текущей раскладки событием не считается
+	last, _ := w.GetCurrentLayout()
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	go func() {
+		// Закрытие соединения прерывает чтение
+		<-ctx.Done()
+		c.Close()
+	}()
+
+	events := make(chan layout.LayoutEvent, 10)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(c)
+		for scanner.Scan() {
+			name, data, _ := strings.Cut(scanner.Text(), ">>")
+			if name != "activelayout" {
+				continue
+			}
+			kbd, keymap, ok := parseActiveLayout(data)
+			if !ok || !w.accept(kbd) {
+				continue
+			}
+
+			event, found := w.event(kbd, keymap)
+			if !found {
+				// Новая клавиатура или изменились её раскладки - перечитываем список
+				if _, err := w.devices(); err == nil {
+					event, _ = w.event(kbd, keymap)
+				}
+			}
+			if event == last {
+				continue
+			}
+			last = event
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Close останавливает отслеживание
+func (w *Watcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package hyprland
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb/xkbtest"
+)
+
+// fakeHyprland - сокеты Hyprland для тестов: .socket.sock отвечает на j/devices,
+// в .socket2.sock пишутся события
+type fakeHyprland struct {
+	t   *testing.T
+	dir string
+
+	mu        sync.Mutex
+	keyboards []keyboard
+	requests  []string
+	listeners []net.Conn
+	connected chan struct{}
+}
+
+func newFakeHyprland(t *testing.T, keyboards []keyboard) *fakeHyprland {
+	t.Helper()
+	h := &fakeHyprland{t: t, dir: t.TempDir(), keyboards: keyboards, connected: make(chan struct{}, 10)}
+
+	requests, err := net.Listen("unix", filepath.Join(h.dir, requestSocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := net.Listen("unix", filepath.Join(h.dir, eventSocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		requests.Close()
+		events.Close()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, c := range h.listeners {
+			c.Close()
+		}
+	})
+
+	go func() {
+		for {
+			c, err := requests.Accept()
+			if err != nil {
+				return
+			}
+			go h.serve(c)
+		}
+	}()
+	go func() {
+		for {
+			c, err := events.Accept()
+			if err != nil {
+				return
+			}
+			h.mu.Lock()
+			h.listeners = append(h.listeners, c)
+			h.mu.Unlock()
+			h.connected <- struct{}{}
+		}
+	}()
+	return h
+}
+
+func (h *fakeHyprland) serve(c net.Conn) {
+	defer c.Close()
+	buf := make([]byte, 256)
+	n, err := c.Read(buf)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests = append(h.requests, string(buf[:n]))
+	if string(buf[:n]) != "j/devices" {
+		io.WriteString(c, "unknown request")
+		return
+	}
+	data, _ := json.Marshal(map[string]any{"mice": []any{}, "keyboards": h.keyboards})
+	c.Write(data)
+}
+
+// emit пишет строку события всем подключённым
+func (h *fakeHyprland) emit(line string) {
+	h.t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range h.listeners {
+		if _, err := io.WriteString(c, line+"\n"); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+}
+
+func (h *fakeHyprland) setKeyboards(keyboards []keyboard) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.keyboards = keyboards
+}
+
+func (h *fakeHyprland) requestCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.requests)
+}
+
+func TestSocketDir(t *testing.T) {
+	runtime := t.TempDir()
+	dir := filepath.Join(runtime, "hypr", "abc_123")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, eventSocket), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", runtime)
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "abc_123")
+	if got, err := SocketDir(); err != nil || got != dir {
+		t.Errorf("SocketDir() = %q, %v, want %q", got, err, dir)
+	}
+
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "missing")
+	if _, err := SocketDir(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("SocketDir() error = %v, want not found", err)
+	}
+
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+	if _, err := SocketDir(); err == nil {
+		t.Error("SocketDir() without signature succeeded")
+	}
+}
+
+func TestParseActiveLayout(t *testing.T) {
+	tests := []struct {
+		data, keyboard, keymap string
+	}{
+		{"at-translated-set-2-keyboard,English (US)", "at-translated-set-2-keyboard", "English (US)"},
+		{"keychron-keychron-v3,English (US, intl., with dead keys)", "keychron-keychron-v3", "English (US, intl., with dead keys)"},
+	}
+
+	for _, tt := range tests {
+		kbd, keymap, ok := parseActiveLayout(tt.data)
+		if !ok || kbd != tt.keyboard || keymap != tt.keymap {
+			t.Errorf("parseActiveLayout(%q) = %q, %q, %v", tt.data, kbd, keymap, ok)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	const (
+		laptop   = "at-translated-set-2-keyboard"
+		keychron = "keychron-keychron-v3"
+	)
+	h := newFakeHyprland(t, []keyboard{
+		{Name: laptop, Layout: "us,ru", Variant: ",", ActiveKeymap: "English (US)"},
+		{Name: keychron, Layout: "us,us,ru", Variant: "intl,,phonetic", ActiveKeymap: "Russian (phonetic)", Main: true},
+	})
+
+	registry := xkbtest.Registry(t)
+	w := newWatcher(h.dir, "", registry)
+	defer w.Close()
+
+	current, err := w.GetCurrentLayout()
+	if err != nil {
+		t.Fatalf("GetCurrentLayout() error = %v", err)
+	}
+	if want := (layout.LayoutEvent{Index: 2, Layout: "ru", Variant: "phonetic", Name: "Russian (phonetic)"}); current != want {
+		t.Errorf("GetCurrentLayout() = %+v, want main keyboard %+v", current, want)
+	}
+	wantLayouts := []layout.LayoutInfo{
+		{Code: "us", Variant: "intl", Name: "English (US, intl., with dead keys)"},
+		{Code: "us", Name: "English (US)"},
+		{Code: "ru", Variant: "phonetic", Name: "Russian (phonetic)"},
+	}
+	if got, err := w.Layouts(); err != nil || !slices.Equal(got, wantLayouts) {
+		t.Errorf("Layouts() = %+v, %v, want %+v", got, err, wantLayouts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	select {
+	case <-h.connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not connect to the event socket")
+	}
+
+	expect := func(want layout.LayoutEvent) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+
+	h.emit("workspace>>2")
+	h.emit("activelayout>>" + keychron + ",English (US, intl., with dead keys)")
+	expect(layout.LayoutEvent{Index: 0, Layout: "us", Variant: "intl", Name: "English (US, intl., with dead keys)"})
+
+	h.emit("activelayout>>" + laptop + ",Russian")
+	expect(layout.LayoutEvent{Index: 1, Layout: "ru", Name: "Russian"})
+
+	// Новая раскладка в настройках: список клавиатур перечитывается
+	before := h.requestCount()
+	h.setKeyboards([]keyboard{{Name: laptop, Layout: "ru,us", Variant: ",dvorak", ActiveKeymap: "English (Dvorak)"}})
+	h.emit("activelayout>>" + laptop + ",English (Dvorak)")
+	expect(layout.LayoutEvent{Index: 1, Layout: "us", Variant: "dvorak", Name: "English (Dvorak)"})
+	if h.requestCount() == before {
+		t.Error("devices were not queried again")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("unexpected event after cancel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("events channel not closed after cancel")
+	}
+}
+
+func TestWatcherFilter(t *testing.T) {
+	h := newFakeHyprland(t, []keyboard{
+		{Name: "laptop", Layout: "us,ru", ActiveKeymap: "English (US)", Main: true},
+		{Name: "keychron", Layout: "us,ru", ActiveKeymap: "Russian"},
+	})
+	registry := xkbtest.Registry(t)
+	w := newWatcher(h.dir, "keychron", registry)
+	defer w.Close()
+
+	current, err := w.GetCurrentLayout()
+	if err != nil || current.Layout != "ru" {
+		t.Fatalf("GetCurrentLayout() = %+v, %v, want the filtered keyboard", current, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-h.connected
+
+	for i, kbd := range []string{"laptop", "keychron"} {
+		h.emit(fmt.Sprintf("activelayout>>%s,%s", kbd, []string{"Russian", "English (US)"}[i]))
+	}
+	select {
+	case got := <-events:
+		if got.Layout != "us" || got.Index != 0 {
+			t.Errorf("event = %+v, want us from keychron", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+}
@@ -0,0 +1,32 @@
+package layout
+
+import "context"
+
+// LayoutEvent - событие смены раскладки
+// Для методов ввода (IBus, Fcitx5) Layout - имя движка ("mozc-jp", "pinyin"),
+// если движок не просто раскладка XKB
+type LayoutEvent struct {
+	Index   int    // Индекс раскладки (0, 1, 2...), -1 - неизвестен
+	Layout  string // Код раскладки ("ru", "us", "ua")
+	Variant string // Вариант раскладки ("", "phonetic")
+	Name    string // Полное имя ("Russian", "English (US)")
+	Engine  string // Движок метода ввода ("xkb:us::eng", "mozc-jp"), "" - без метода ввода
+	IME     bool   // Метод ввода включён (ввод идёт через движок, а не напрямую)
+}
+
+// LayoutInfo - раскладка из списка раскладок рабочего стола
+type LayoutInfo struct {
+	Code    string // "us", "ru"
+	Variant string // "", "phonetic"
+	Name    string // "English (US)", "Russian"
+}
+
+// LayoutWatcher - интерфейс для отслеживания раскладки
+// Реализации: dbus (KDE, GNOME, IBus, Fcitx5), sway, hyprland, x11, external
+type LayoutWatcher interface {
+	Watch(ctx context.Context) (<-chan LayoutEvent, error)
+	GetCurrentLayout() (LayoutEvent, error)
+	// Layouts возвращает список раскладок по порядку индексов (nil - источник не знает списка)
+	Layouts() ([]LayoutInfo, error)
+	Close() error
+}
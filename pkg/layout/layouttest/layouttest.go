@@ -0,0 +1,29 @@
+// Package layouttest - помощники для тестов watcher'ов раскладки
+package layouttest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+)
+
+// eventTimeout - сколько ждать события, прежде чем тест упадёт
+const eventTimeout = 5 * time.Second
+
+// ExpectEvent ждёт следующее событие watcher'а и сравнивает его с want
+// Закрытый канал и отсутствие события - ошибка теста
+func ExpectEvent(t testing.TB, events <-chan layout.LayoutEvent, want layout.LayoutEvent) {
+	t.Helper()
+	select {
+	case got, ok := <-events:
+		if !ok {
+			t.Fatalf("events closed, want %+v", want)
+		}
+		if got != want {
+			t.Errorf("event = %+v, want %+v", got, want)
+		}
+	case <-time.After(eventTimeout):
+		t.Fatalf("no event, want %+v", want)
+	}
+}
@@ -0,0 +1,226 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+// testCardBands - полосы тестовой таблицы для калибровки
+// Каждая полоса получает позицию клавиши в ряду (0..1) и возвращает её цвет
+var testCardBands = []func(x float64) config.RGBColor{
+	// Чистый белый - проверка точки белого
+	func(float64) config.RGBColor { return config.RGBColor{R: 255, G: 255, B: 255} },
+	// Серая шкала - проверка гаммы
+	func(x float64) config.RGBColor {
+		v := uint8(x * 255)
+		return config.RGBColor{R: v, G: v, B: v}
+	},
+	// Красный, зелёный, синий - баланс каналов
+	thirds(config.RGBColor{R: 255}, config.RGBColor{G: 255}, config.RGBColor{B: 255}),
+	// Тёмно-синяя шкала - тёмные тона, которые легко пропадают
+	func(x float64) config.RGBColor { return config.RGBColor{B: uint8(16 + x*112)} },
+	// Жёлтый, голубой, пурпурный - смешение каналов
+	thirds(config.RGBColor{R: 255, G: 255}, config.RGBColor{G: 255, B: 255}, config.RGBColor{R: 255, B: 255}),
+	// Белый снизу - сравнение с верхним рядом
+	func(float64) config.RGBColor { return config.RGBColor{R: 255, G: 255, B: 255} },
+}
+
+// thirds делит полосу на три цвета
+func thirds(a, b, c config.RGBColor) func(x float64) config.RGBColor {
+	return func(x float64) config.RGBColor {
+		switch {
+		case x < 1.0/3:
+			return a
+		case x < 2.0/3:
+			return b
+		default:
+			return c
+		}
+	}
+}
+
+// TestCard рассчитывает цвета LED тестовой таблицы для команды calibrate
+// Полосы таблицы идут по keyboard.rows (на клавиатурах с большим числом рядов повторяются),
+// без рядов LED делятся на равные группы по порядку индексов
+func TestCard(cfg *config.Config, ledCount int) []config.RGBColor {
+	leds := make([]config.RGBColor, ledCount)
+
+	if rows := cfg.Keyboard.Rows; len(rows) > 0 {
+		for r, row := range rows {
+			band := testCardBands[r%len(testCardBands)]
+			for j, led := range row {
+				if led >= 0 && led < ledCount {
+					leds[led] = band(float64(j) / float64(len(row)))
+				}
+			}
+		}
+		return leds
+	}
+
+	groupSize := max((ledCount+len(testCardBands)-1)/len(testCardBands), 1)
+	for i := range leds {
+		group := i / groupSize
+		start := group * groupSize
+		size := min(groupSize, ledCount-start)
+		leds[i] = testCardBands[group](float64(i-start) / float64(size))
+	}
+	return leds
+}
+
+// Шаги команд настройки калибровки
+const (
+	gainStep   = 0.05
+	gammaStep  = 0.1
+	kelvinStep = 500
+	// neutralKelvin - температура, при которой KelvinToRGB даёт чистый белый
+	neutralKelvin = 6600
+)
+
+// CalibrationState - текущие значения калибровки в процессе настройки
+type CalibrationState struct {
+	gamma  float64
+	gain   config.ChannelGain
+	kelvin float64 // 0 = точка белого из конфига (или без неё)
+	white  *config.RGBColor
+}
+
+// NewCalibrationState начинает настройку с калибровки из конфига (nil - без калибровки)
+func NewCalibrationState(c *config.Calibration) *CalibrationState {
+	s := &CalibrationState{gamma: 1, gain: config.ChannelGain{R: 1, G: 1, B: 1}}
+	if c == nil {
+		return s
+	}
+	if c.Gamma != 0 {
+		s.gamma = c.Gamma
+	}
+	if c.Gain != nil {
+		s.gain = *c.Gain
+	}
+	s.white = c.WhitePoint
+	return s
+}
+
+// Calibration собирает config.Calibration из текущих значений
+func (s *CalibrationState) Calibration() *config.Calibration {
+	gain := s.gain
+	c := &config.Calibration{Gamma: s.gamma, Gain: &gain, WhitePoint: s.white}
+	if s.kelvin != 0 {
+		white := config.KelvinToRGB(s.kelvin)
+		c.WhitePoint = &white
+	}
+	return c
+}
+
+// Adjust применяет команду изменения ("r+", "y-", "k+" ...), возвращает false для неизвестной команды
+func (s *CalibrationState) Adjust(command string) bool {
+	if len(command) != 2 || (command[1] != '+' && command[1] != '-') {
+		return false
+	}
+	sign := 1.0
+	if command[1] == '-' {
+		sign = -1
+	}
+
+	clamp := func(v, lo, hi float64) float64 {
+		return max(lo, min(hi, v))
+	}
+
+	switch command[0] {
+	case 'r':
+		s.gain.R = clamp(s.gain.R+sign*gainStep, 0, 2)
+	case 'g':
+		s.gain.G = clamp(s.gain.G+sign*gainStep, 0, 2)
+	case 'b':
+		s.gain.B = clamp(s.gain.B+sign*gainStep, 0, 2)
+	case 'y':
+		s.gamma = clamp(s.gamma+sign*gammaStep, gammaStep, 5)
+	case 'k':
+		if s.kelvin == 0 {
+			s.kelvin = neutralKelvin
+		}
+		s.kelvin = clamp(s.kelvin+sign*kelvinStep, 1000, 40000)
+	default:
+		return false
+	}
+	return true
+}
+
+// String выводит текущие значения в одну строку
+func (s *CalibrationState) String() string {
+	white := "none"
+	switch {
+	case s.kelvin != 0:
+		white = fmt.Sprintf("%.0fK", s.kelvin)
+	case s.white != nil:
+		white = s.white.String()
+	}
+	return fmt.Sprintf("gamma=%s gain=(r=%s g=%s b=%s) white_point=%s",
+		formatFloat(s.gamma), formatFloat(s.gain.R), formatFloat(s.gain.G), formatFloat(s.gain.B), white)
+}
+
+// YAML возвращает блок calibration для вставки в секцию device
+func (s *CalibrationState) YAML() string {
+	var sb strings.Builder
+	sb.WriteString("  calibration:\n")
+	fmt.Fprintf(&sb, "    gamma: %s\n", formatFloat(s.gamma))
+	fmt.Fprintf(&sb, "    gain: {r: %s, g: %s, b: %s}\n", formatFloat(s.gain.R), formatFloat(s.gain.G), formatFloat(s.gain.B))
+	switch {
+	case s.kelvin != 0:
+		fmt.Fprintf(&sb, "    white_point: {kelvin: %.0f}\n", s.kelvin)
+	case s.white != nil:
+		fmt.Fprintf(&sb, "    white_point: %q\n", s.white.String())
+	}
+	return sb.String()
+}
+
+// formatFloat округляет значение до сотых без лишних нулей
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// Calibrate - интерактивная настройка калибровки (команда calibrate)
+// Читает команды из in по строке, подсказки и итоговый блок пишет в out.
+// show вызывается с текущей калибровкой перед каждой командой и показывает тестовую таблицу.
+// Команды: r+ r- g+ g- b+ b- y+ y- k+ k- (см. Adjust), reset - начать заново,
+// q - вывести блок calibration и выйти, x - выйти без вывода (как и конец ввода)
+func Calibrate(in io.Reader, out io.Writer, start *config.Calibration, show func(*config.Calibration) error) error {
+	state := NewCalibrationState(start)
+	scanner := bufio.NewScanner(in)
+
+	for {
+		if err := show(state.Calibration()); err != nil {
+			return fmt.Errorf("failed to show test card: %w", err)
+		}
+
+		fmt.Fprintln(out, state)
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		command := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		switch command {
+		case "":
+		case "q":
+			fmt.Fprintln(out)
+			fmt.Fprintln(out, "Add this block under 'device:' in your config:")
+			fmt.Fprintln(out)
+			fmt.Fprint(out, state.YAML())
+			return nil
+		case "x":
+			return nil
+		case "reset":
+			state = NewCalibrationState(nil)
+		default:
+			if !state.Adjust(command) {
+				fmt.Fprintln(out, "Unknown command. Use r+ r- g+ g- b+ b- y+ y- k+ k-, reset, q or x")
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package render
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+func TestTestCard(t *testing.T) {
+	green := config.RGBColor{G: 255}
+
+	t.Run("by rows", func(t *testing.T) {
+		cfg := &config.Config{Keyboard: config.KeyboardConfig{
+			Rows: [][]int{{0, 1}, {2, 3}, {4, 5, 6}, {7}, {8}, {9}, {10}},
+		}}
+		leds := TestCard(cfg, 11)
+
+		checks := map[int]config.RGBColor{
+			0:  white,
+			1:  white,
+			2:  black,                    // начало серой шкалы
+			3:  {R: 127, G: 127, B: 127}, // середина серой шкалы
+			4:  red,
+			5:  green,
+			6:  blue,
+			7:  {B: 16},          // начало тёмно-синей шкалы
+			8:  {R: 255, G: 255}, // жёлтый
+			9:  white,
+			10: white, // ряды повторяются
+		}
+		for led, want := range checks {
+			if leds[led] != want {
+				t.Errorf("led %d = %v, want %v", led, leds[led], want)
+			}
+		}
+	})
+
+	t.Run("without rows", func(t *testing.T) {
+		leds := TestCard(&config.Config{}, 12)
+		if len(leds) != 12 {
+			t.Fatalf("got %d LEDs, want 12", len(leds))
+		}
+		if leds[0] != white || leds[1] != white {
+			t.Errorf("first group = %v %v, want white", leds[0], leds[1])
+		}
+		if leds[4] != red || leds[5] != green {
+			t.Errorf("third group = %v %v, want red and green", leds[4], leds[5])
+		}
+	})
+
+	t.Run("leds outside count are ignored", func(t *testing.T) {
+		cfg := &config.Config{Keyboard: config.KeyboardConfig{Rows: [][]int{{0, 5}}}}
+		leds := TestCard(cfg, 2)
+		if leds[0] != white || leds[1] != black {
+			t.Errorf("got %v", leds)
+		}
+	})
+}
+
+func TestCalibrate(t *testing.T) {
+	gain := config.ChannelGain{R: 1, G: 0.9, B: 1}
+	start := &config.Calibration{Gamma: 2.2, Gain: &gain}
+
+	tests := []struct {
+		name    string
+		start   *config.Calibration
+		input   string
+		shows   int    // сколько раз показана тестовая таблица
+		last    string // значения перед последней командой
+		wantOut string // подстрока вывода
+		noOut   string // строка, которой не должно быть в выводе
+	}{
+		{
+			name:    "adjust and print",
+			input:   "r+\nr+\nb-\ny-\nq\n",
+			shows:   5,
+			last:    "gamma=0.9 gain=(r=1.1 g=1 b=0.95) white_point=none",
+			wantOut: "  calibration:\n    gamma: 0.9\n    gain: {r: 1.1, g: 1, b: 0.95}\n",
+		},
+		{
+			name:    "starts from config",
+			start:   start,
+			input:   "G+\nq\n",
+			shows:   2,
+			last:    "gamma=2.2 gain=(r=1 g=0.95 b=1) white_point=none",
+			wantOut: "    gain: {r: 1, g: 0.95, b: 1}\n",
+		},
+		{
+			name:    "white point temperature",
+			input:   "k-\nk-\nq\n",
+			shows:   3,
+			last:    "white_point=5600K",
+			wantOut: "    white_point: {kelvin: 5600}\n",
+		},
+		{
+			name:    "reset drops config values",
+			start:   start,
+			input:   "reset\nq\n",
+			shows:   2,
+			last:    "gamma=1 gain=(r=1 g=1 b=1) white_point=none",
+			wantOut: "    gamma: 1\n",
+		},
+		{
+			name:    "gain is clamped",
+			input:   strings.Repeat("b-\n", 25) + "q\n",
+			shows:   26,
+			last:    "gain=(r=1 g=1 b=0)",
+			wantOut: "b: 0}",
+		},
+		{
+			name:    "unknown command and blank line",
+			input:   "\nz+\nq\n",
+			shows:   3,
+			wantOut: "Unknown command",
+		},
+		{
+			name:  "quit without printing",
+			input: "r+\nx\n",
+			shows: 2,
+			noOut: "calibration:",
+		},
+		{
+			name:  "end of input",
+			input: "r+\n",
+			shows: 2,
+			noOut: "calibration:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			var shown []*config.Calibration
+			show := func(c *config.Calibration) error {
+				shown = append(shown, c)
+				return nil
+			}
+
+			if err := Calibrate(strings.NewReader(tt.input), &out, tt.start, show); err != nil {
+				t.Fatalf("Calibrate() error = %v", err)
+			}
+			if len(shown) != tt.shows {
+				t.Errorf("test card shown %d times, want %d", len(shown), tt.shows)
+			}
+
+			// Перед каждой командой выводятся текущие значения, после первой - следом за "> "
+			var states []string
+			for _, line := range strings.Split(out.String(), "\n") {
+				if line = strings.TrimPrefix(line, "> "); strings.HasPrefix(line, "gamma=") {
+					states = append(states, line)
+				}
+			}
+			if tt.last != "" && (len(states) == 0 || !strings.Contains(states[len(states)-1], tt.last)) {
+				t.Errorf("last state = %q, want %q", states, tt.last)
+			}
+			if tt.wantOut != "" && !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output:\n%s\nwant substring %q", out.String(), tt.wantOut)
+			}
+			if tt.noOut != "" && strings.Contains(out.String(), tt.noOut) {
+				t.Errorf("output:\n%s\nshould not contain %q", out.String(), tt.noOut)
+			}
+		})
+	}
+}
+
+func TestCalibrateShowError(t *testing.T) {
+	show := func(*config.Calibration) error { return errors.New("device gone") }
+	err := Calibrate(strings.NewReader("q\n"), io.Discard, nil, show)
+	if err == nil || !strings.Contains(err.Error(), "device gone") {
+		t.Errorf("Calibrate() error = %v, want show error", err)
+	}
+}
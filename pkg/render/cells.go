@@ -0,0 +1,110 @@
+package render
+
+import (
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+// fallbackRowWidth - LED в строке картинки, если у клавиатуры нет ни rows, ни geometry
+const fallbackRowWidth = 16
+
+// Cell - прямоугольник клавиши в нормализованных координатах клавиатуры (0..1)
+type Cell struct {
+	LED    int
+	X0, Y0 float64 // левый верхний угол
+	X1, Y1 float64 // правый нижний угол
+}
+
+// Cells возвращает положения всех LED на клавиатуре
+// Если указана keyboard.geometry - по реальным координатам клавиш,
+// иначе - сетка по keyboard.rows, где каждый ряд растянут на всю ширину
+func Cells(cfg *config.Config) []Cell {
+	if len(cfg.Keyboard.Geometry) > 0 {
+		return geometryCells(cfg.Keyboard.Geometry)
+	}
+	return rowCells(cfg.Keyboard.Rows)
+}
+
+// rowCells строит равномерную сетку по рядам
+func rowCells(rows [][]int) []Cell {
+	var cells []Cell
+	numRows := float64(len(rows))
+	for r, row := range rows {
+		n := float64(len(row))
+		for j, led := range row {
+			cells = append(cells, Cell{
+				LED: led,
+				X0:  float64(j) / n,
+				Y0:  float64(r) / numRows,
+				X1:  float64(j+1) / n,
+				Y1:  float64(r+1) / numRows,
+			})
+		}
+	}
+	return cells
+}
+
+// geometryCells нормализует координаты клавиш по габаритам клавиатуры
+func geometryCells(keys []config.KeyGeometry) []Cell {
+	minX, minY, maxX, maxY := keyBounds(keys)
+
+	width := maxX - minX
+	height := maxY - minY
+
+	cells := make([]Cell, len(keys))
+	for i, k := range keys {
+		w, h := k.Size()
+		cells[i] = Cell{
+			LED: k.LED,
+			X0:  (k.X - minX) / width,
+			Y0:  (k.Y - minY) / height,
+			X1:  (k.X + w - minX) / width,
+			Y1:  (k.Y + h - minY) / height,
+		}
+	}
+	return cells
+}
+
+// keyBounds возвращает габариты клавиш (левый верхний и правый нижний углы)
+func keyBounds(keys []config.KeyGeometry) (minX, minY, maxX, maxY float64) {
+	if len(keys) == 0 {
+		return 0, 0, 0, 0
+	}
+	minX, minY = keys[0].X, keys[0].Y
+	maxX, maxY = minX, minY
+	for _, k := range keys {
+		w, h := k.Size()
+		minX = min(minX, k.X)
+		minY = min(minY, k.Y)
+		maxX = max(maxX, k.X+w)
+		maxY = max(maxY, k.Y+h)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// layoutKeys возвращает клавиши для вывода картинки клавиатуры
+// keyboard.geometry используется как есть, ряды keyboard.rows превращаются
+// в сетку клавиш 1u, а без них LED раскладываются подряд строками по 16
+func layoutKeys(cfg *config.Config, ledCount int) []config.KeyGeometry {
+	if len(cfg.Keyboard.Geometry) > 0 {
+		return cfg.Keyboard.Geometry
+	}
+
+	var keys []config.KeyGeometry
+	if len(cfg.Keyboard.Rows) > 0 {
+		for r, row := range cfg.Keyboard.Rows {
+			for j, led := range row {
+				keys = append(keys, config.KeyGeometry{LED: led, X: float64(j), Y: float64(r)})
+			}
+		}
+		return keys
+	}
+
+	for i := 0; i < ledCount; i++ {
+		keys = append(keys, config.KeyGeometry{
+			LED: i,
+			X:   float64(i % fallbackRowWidth),
+			Y:   float64(i / fallbackRowWidth),
+		})
+	}
+	return keys
+}
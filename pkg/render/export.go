@@ -0,0 +1,175 @@
+package render
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+	"github.com/jidckii/kolor-keyboard/pkg/font"
+)
+
+const (
+	// exportUnit - размер клавиши 1u в пикселях (включая промежуток)
+	exportUnit = 56
+	// exportGap - промежуток между клавишами
+	exportGap = 4
+	// exportPadding - отступ от края картинки до клавиш
+	exportPadding = 12
+	// exportRadius - скругление углов клавиш в SVG
+	exportRadius = 5
+	// exportFontSize - размер шрифта подписей в SVG
+	exportFontSize = 12
+	// exportPixel - размер пикселя встроенного шрифта в подписях PNG
+	exportPixel = 2
+)
+
+// exportBackground - цвет фона картинки (корпус клавиатуры)
+var exportBackground = config.RGBColor{R: 0x20, G: 0x21, B: 0x24}
+
+// keyRect - клавиша на картинке в пикселях
+type keyRect struct {
+	LED        int
+	X, Y, W, H int
+	Name       string
+}
+
+// exportLayout раскладывает клавиши по картинке, возвращает клавиши и размер картинки
+func exportLayout(cfg *config.Config, ledCount int) ([]keyRect, int, int) {
+	keys := layoutKeys(cfg, ledCount)
+	minX, minY, maxX, maxY := keyBounds(keys)
+
+	px := func(v float64) int { return int(math.Round(v * exportUnit)) }
+
+	rects := make([]keyRect, len(keys))
+	for i, k := range keys {
+		w, h := k.Size()
+		x0, y0 := px(k.X-minX), px(k.Y-minY)
+		x1, y1 := px(k.X+w-minX), px(k.Y+h-minY)
+		rects[i] = keyRect{
+			LED:  k.LED,
+			X:    exportPadding + x0 + exportGap/2,
+			Y:    exportPadding + y0 + exportGap/2,
+			W:    x1 - x0 - exportGap,
+			H:    y1 - y0 - exportGap,
+			Name: k.Name,
+		}
+	}
+
+	width := px(maxX-minX) + 2*exportPadding
+	height := px(maxY-minY) + 2*exportPadding
+	return rects, width, height
+}
+
+// ledColor возвращает цвет LED или чёрный (выключен) для LED без цвета
+func ledColor(colors []config.RGBColor, led int) config.RGBColor {
+	if led < 0 || led >= len(colors) {
+		return config.RGBColor{}
+	}
+	return colors[led]
+}
+
+// labelColor выбирает чёрную или белую подпись в зависимости от яркости клавиши
+func labelColor(c config.RGBColor) config.RGBColor {
+	if 0.299*float64(c.R)+0.587*float64(c.G)+0.114*float64(c.B) > 140 {
+		return config.RGBColor{}
+	}
+	return config.RGBColor{R: 255, G: 255, B: 255}
+}
+
+// WriteSVG рисует клавиатуру с цветами LED в формате SVG
+// Клавиши расположены по keyboard.geometry (с подписями из name), иначе сеткой по keyboard.rows
+func WriteSVG(w io.Writer, cfg *config.Config, colors []config.RGBColor) error {
+	rects, width, height := exportLayout(cfg, len(colors))
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" rx="%d" fill="%s"/>`+"\n",
+		width, height, exportRadius*2, exportBackground)
+	fmt.Fprintf(bw, `<g font-family="sans-serif" font-size="%d" text-anchor="middle" dominant-baseline="central">`+"\n",
+		exportFontSize)
+
+	for _, r := range rects {
+		fill := ledColor(colors, r.LED)
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" rx="%d" fill="%s"/>`+"\n",
+			r.X, r.Y, r.W, r.H, exportRadius, fill)
+		if r.Name == "" {
+			continue
+		}
+		var name strings.Builder
+		if err := xml.EscapeText(&name, []byte(r.Name)); err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n",
+			r.X+r.W/2, r.Y+r.H/2, labelColor(fill), name.String())
+	}
+
+	bw.WriteString("</g>\n</svg>\n")
+	return bw.Flush()
+}
+
+// WritePNG рисует клавиатуру с цветами LED в формате PNG
+// Подписи клавиш рисуются встроенным шрифтом и пропускаются, если не помещаются в клавишу
+// или содержат символы, которых нет в шрифте (в SVG подписи остаются текстом)
+func WritePNG(w io.Writer, cfg *config.Config, colors []config.RGBColor) error {
+	return png.Encode(w, Image(cfg, colors))
+}
+
+// Image рисует клавиатуру с цветами LED в картинку (основа для WritePNG)
+func Image(cfg *config.Config, colors []config.RGBColor) *image.RGBA {
+	rects, width, height := exportLayout(cfg, len(colors))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(toNRGBA(exportBackground)), image.Point{}, draw.Src)
+
+	for _, r := range rects {
+		fill := ledColor(colors, r.LED)
+		rect := image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+		draw.Draw(img, rect, image.NewUniform(toNRGBA(fill)), image.Point{}, draw.Src)
+		if r.Name != "" {
+			drawLabel(img, rect, r.Name, labelColor(fill))
+		}
+	}
+
+	return img
+}
+
+// drawLabel рисует подпись по центру клавиши
+// Подпись с символами, которых нет во встроенном шрифте ("←", "&"), пропускается:
+// закрашенные блоки вместо букв только запутают
+func drawLabel(img *image.RGBA, rect image.Rectangle, text string, c config.RGBColor) {
+	if len(font.Missing(text)) > 0 {
+		return
+	}
+	bm := RasterizeText(text)
+	width, height := bm.Width*exportPixel, FontHeight*exportPixel
+	if width > rect.Dx()-2*exportPixel || height > rect.Dy() {
+		return
+	}
+
+	x0 := rect.Min.X + (rect.Dx()-width)/2
+	y0 := rect.Min.Y + (rect.Dy()-height)/2
+	ink := image.NewUniform(toNRGBA(c))
+	for y := 0; y < FontHeight; y++ {
+		for x := 0; x < bm.Width; x++ {
+			if !bm.At(x, y) {
+				continue
+			}
+			px := image.Rect(x0+x*exportPixel, y0+y*exportPixel, x0+(x+1)*exportPixel, y0+(y+1)*exportPixel)
+			draw.Draw(img, px, ink, image.Point{}, draw.Src)
+		}
+	}
+}
+
+// toNRGBA переводит цвет конфига в непрозрачный color.NRGBA
+func toNRGBA(c config.RGBColor) color.NRGBA {
+	return color.NRGBA{R: c.R, G: c.G, B: c.B, A: 255}
+}
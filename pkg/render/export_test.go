@@ -0,0 +1,151 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// exportCases - конфиги для golden-тестов SVG и PNG
+var exportCases = []struct {
+	name   string
+	cfg    *config.Config
+	colors []config.RGBColor
+}{
+	{
+		name: "rows",
+		cfg: &config.Config{Keyboard: config.KeyboardConfig{
+			Rows: [][]int{{0, 1, 2}, {3, 4}},
+		}},
+		colors: []config.RGBColor{red, white, blue, {R: 255, G: 200}, black},
+	},
+	{
+		name: "geometry",
+		cfg: &config.Config{Keyboard: config.KeyboardConfig{
+			Geometry: []config.KeyGeometry{
+				{LED: 0, X: 0, Y: 0, Name: "Esc"},
+				{LED: 1, X: 1.5, Y: 0, W: 1.5, Name: "Tab"},
+				{LED: 2, X: 0, Y: 1, W: 3, Name: "Space"},
+				{LED: 3, X: 3, Y: 0, H: 2, Name: "<&>"},
+			},
+		}},
+		colors: []config.RGBColor{white, blue, {G: 128}, red},
+	},
+}
+
+// golden сравнивает результат с файлом в testdata (с -update перезаписывает файл)
+func golden(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read golden file (run go test -update): %v", err)
+	}
+	return data
+}
+
+func TestWriteSVG(t *testing.T) {
+	for _, tt := range exportCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteSVG(&buf, tt.cfg, tt.colors); err != nil {
+				t.Fatalf("WriteSVG() error = %v", err)
+			}
+
+			name := tt.name + ".svg"
+			if *update {
+				if err := os.WriteFile(filepath.Join("testdata", name), buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if want := golden(t, name); !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("WriteSVG() differs from testdata/%s:\n%s", name, buf.String())
+			}
+		})
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	for _, tt := range exportCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WritePNG(&buf, tt.cfg, tt.colors); err != nil {
+				t.Fatalf("WritePNG() error = %v", err)
+			}
+
+			name := tt.name + ".png"
+			if *update {
+				if err := os.WriteFile(filepath.Join("testdata", name), buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Сравниваются пиксели, а не байты: сжатие PNG может меняться между версиями Go
+			want, err := png.Decode(bytes.NewReader(golden(t, name)))
+			if err != nil {
+				t.Fatalf("failed to decode golden PNG: %v", err)
+			}
+			got, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatalf("failed to decode PNG: %v", err)
+			}
+			if !samePixels(got, want) {
+				t.Errorf("WritePNG() differs from testdata/%s", name)
+			}
+		})
+	}
+}
+
+func TestExportKeyColors(t *testing.T) {
+	cfg := exportCases[1].cfg
+	img := Image(cfg, exportCases[1].colors)
+
+	// Угол клавиши Esc - белый, фон - цвет корпуса
+	if got := img.RGBAAt(exportPadding+exportGap, exportPadding+exportGap); got.R != 255 || got.G != 255 || got.B != 255 {
+		t.Errorf("Esc pixel = %v, want white", got)
+	}
+	if got := img.RGBAAt(0, 0); got.R != exportBackground.R || got.G != exportBackground.G || got.B != exportBackground.B {
+		t.Errorf("background pixel = %v, want %v", got, exportBackground)
+	}
+}
+
+func TestExportMissingGlyphLabel(t *testing.T) {
+	cfg := &config.Config{Keyboard: config.KeyboardConfig{
+		Geometry: []config.KeyGeometry{{LED: 0, X: 0, Y: 0, W: 2, Name: "←"}},
+	}}
+	img := Image(cfg, []config.RGBColor{blue})
+
+	// Подпись не рисуется: вся клавиша одного цвета
+	rects, _, _ := exportLayout(cfg, 1)
+	r := rects[0]
+	for y := r.Y; y < r.Y+r.H; y++ {
+		for x := r.X; x < r.X+r.W; x++ {
+			if got := img.RGBAAt(x, y); got.R != 0 || got.G != 0 || got.B != 255 {
+				t.Fatalf("pixel (%d, %d) = %v, want the key color", x, y, got)
+			}
+		}
+	}
+}
+
+func samePixels(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			r1, g1, b1, a1 := a.At(x, y).RGBA()
+			r2, g2, b2, a2 := b.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
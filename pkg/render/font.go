@@ -0,0 +1,63 @@
+package render
+
+import "github.com/jidckii/kolor-keyboard/pkg/font"
+
+// FontHeight - высота глифов встроенного шрифта
+const FontHeight = font.Height
+
+// glyphSpacing - пустые колонки между символами
+const glyphSpacing = 1
+
+// unknownGlyph - глиф для символов, которых нет в шрифте
+// Текст рисунков проверяется при загрузке конфига, а подписи PNG с такими символами
+// пропускаются (font.Missing), так что блок - только запасной вариант
+var unknownGlyph = []string{"###", "###", "###", "###", "###"}
+
+// glyph возвращает глиф символа или unknownGlyph, если его нет в шрифте
+func glyph(r rune) []string {
+	if g, ok := font.Glyph(r); ok {
+		return g
+	}
+	return unknownGlyph
+}
+
+// TextBitmap - растеризованная строка: FontHeight рядов по Width пикселей
+type TextBitmap struct {
+	Width  int
+	pixels [FontHeight][]bool
+}
+
+// RasterizeText растеризует строку встроенным шрифтом
+// Символы, которых нет в шрифте, рисуются закрашенным блоком (см. font.Missing)
+func RasterizeText(text string) *TextBitmap {
+	bm := &TextBitmap{}
+	for i, r := range []rune(text) {
+		if i > 0 {
+			bm.appendColumns(glyphSpacing)
+		}
+		g := glyph(r)
+		for row := 0; row < FontHeight; row++ {
+			for _, ch := range g[row] {
+				bm.pixels[row] = append(bm.pixels[row], ch == '#')
+			}
+		}
+		bm.Width += len(g[0])
+	}
+	return bm
+}
+
+// appendColumns добавляет пустые колонки справа
+func (bm *TextBitmap) appendColumns(n int) {
+	for row := 0; row < FontHeight; row++ {
+		bm.pixels[row] = append(bm.pixels[row], make([]bool, n)...)
+	}
+	bm.Width += n
+}
+
+// At сообщает, закрашен ли пиксель (вне границ - не закрашен)
+func (bm *TextBitmap) At(x, y int) bool {
+	if y < 0 || y >= FontHeight || x < 0 || x >= bm.Width {
+		return false
+	}
+	return bm.pixels[y][x]
+}
@@ -0,0 +1,79 @@
+package render
+
+import (
+	"image"
+	"math"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+// SampleImage рассчитывает цвет каждой клавиши, растягивая картинку на всю клавиатуру
+// Прозрачные пиксели считаются наложенными на чёрный фон
+func SampleImage(img image.Image, cells []Cell, sampling config.Sampling) map[int]config.RGBColor {
+	colors := make(map[int]config.RGBColor, len(cells))
+	b := img.Bounds()
+	if b.Empty() {
+		return colors
+	}
+
+	w := float64(b.Dx())
+	h := float64(b.Dy())
+
+	for _, cell := range cells {
+		x0 := b.Min.X + int(math.Floor(cell.X0*w))
+		y0 := b.Min.Y + int(math.Floor(cell.Y0*h))
+		x1 := b.Min.X + int(math.Ceil(cell.X1*w))
+		y1 := b.Min.Y + int(math.Ceil(cell.Y1*h))
+
+		if sampling == config.SamplingArea {
+			colors[cell.LED] = averagePixels(img, image.Rect(x0, y0, x1, y1).Intersect(b))
+			continue
+		}
+
+		// nearest - пиксель в центре клавиши
+		cx := b.Min.X + int((cell.X0+cell.X1)/2*w)
+		cy := b.Min.Y + int((cell.Y0+cell.Y1)/2*h)
+		colors[cell.LED] = pixel(img, clampPoint(cx, cy, b))
+	}
+
+	return colors
+}
+
+// averagePixels возвращает средний цвет пикселей в прямоугольнике
+func averagePixels(img image.Image, rect image.Rectangle) config.RGBColor {
+	if rect.Empty() {
+		return config.RGBColor{}
+	}
+
+	var sumR, sumG, sumB uint64
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sumR += uint64(r)
+			sumG += uint64(g)
+			sumB += uint64(b)
+		}
+	}
+
+	n := uint64(rect.Dx() * rect.Dy())
+	return config.RGBColor{
+		R: uint8(sumR / n >> 8),
+		G: uint8(sumG / n >> 8),
+		B: uint8(sumB / n >> 8),
+	}
+}
+
+// pixel возвращает цвет одного пикселя
+func pixel(img image.Image, p image.Point) config.RGBColor {
+	// RGBA() возвращает premultiplied значения - это и есть наложение на чёрный
+	r, g, b, _ := img.At(p.X, p.Y).RGBA()
+	return config.RGBColor{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8)}
+}
+
+// clampPoint ограничивает точку границами картинки
+func clampPoint(x, y int, b image.Rectangle) image.Point {
+	return image.Pt(
+		min(max(x, b.Min.X), b.Max.X-1),
+		min(max(y, b.Min.Y), b.Max.Y-1),
+	)
+}
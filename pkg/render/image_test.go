@@ -0,0 +1,102 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+// splitImage - картинка 4x2: левая половина красная, правая синяя
+func splitImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCellsRows(t *testing.T) {
+	cfg := &config.Config{Keyboard: config.KeyboardConfig{Rows: [][]int{{0, 1}, {2}}}}
+	cells := Cells(cfg)
+
+	want := []Cell{
+		{LED: 0, X0: 0, Y0: 0, X1: 0.5, Y1: 0.5},
+		{LED: 1, X0: 0.5, Y0: 0, X1: 1, Y1: 0.5},
+		{LED: 2, X0: 0, Y0: 0.5, X1: 1, Y1: 1},
+	}
+	if len(cells) != len(want) {
+		t.Fatalf("len = %d, want %d", len(cells), len(want))
+	}
+	for i := range want {
+		if cells[i] != want[i] {
+			t.Errorf("cell[%d] = %+v, want %+v", i, cells[i], want[i])
+		}
+	}
+}
+
+func TestCellsGeometry(t *testing.T) {
+	cfg := &config.Config{Keyboard: config.KeyboardConfig{
+		Rows: [][]int{{0, 1}},
+		Geometry: []config.KeyGeometry{
+			{LED: 0, X: 0, Y: 0, W: 2},
+			{LED: 1, X: 2, Y: 0, W: 2, H: 2},
+		},
+	}}
+	cells := Cells(cfg)
+
+	want := []Cell{
+		{LED: 0, X0: 0, Y0: 0, X1: 0.5, Y1: 0.5},
+		{LED: 1, X0: 0.5, Y0: 0, X1: 1, Y1: 1},
+	}
+	for i := range want {
+		if cells[i] != want[i] {
+			t.Errorf("cell[%d] = %+v, want %+v", i, cells[i], want[i])
+		}
+	}
+}
+
+func TestSampleImage(t *testing.T) {
+	cells := []Cell{
+		{LED: 0, X0: 0, Y0: 0, X1: 0.5, Y1: 1},
+		{LED: 1, X0: 0.5, Y0: 0, X1: 1, Y1: 1},
+		{LED: 2, X0: 0, Y0: 0, X1: 1, Y1: 1},
+	}
+
+	nearest := SampleImage(splitImage(), cells, config.SamplingNearest)
+	if nearest[0] != red || nearest[1] != blue {
+		t.Errorf("nearest = %v, %v, want red, blue", nearest[0], nearest[1])
+	}
+
+	area := SampleImage(splitImage(), cells, config.SamplingArea)
+	if area[0] != red || area[1] != blue {
+		t.Errorf("area = %v, %v, want red, blue", area[0], area[1])
+	}
+	// Клавиша на всю ширину - среднее красного и синего
+	if want := (config.RGBColor{R: 127, B: 127}); area[2] != want {
+		t.Errorf("area full = %v, want %v", area[2], want)
+	}
+}
+
+func TestFlagImage(t *testing.T) {
+	cfg := &config.Config{Keyboard: config.KeyboardConfig{Rows: [][]int{{0, 1}}}}
+	flag := &config.FlagMapping{
+		Frames:  []config.ImageFrame{{Image: splitImage()}},
+		Stripes: []config.FlagStripe{{LEDs: []int{1}, Color: white}},
+	}
+
+	got := Flag(cfg, flag, 2)
+	if got[0] != red {
+		t.Errorf("led[0] = %v, want red from image", got[0])
+	}
+	if got[1] != white {
+		t.Errorf("led[1] = %v, want white stripe over image", got[1])
+	}
+}
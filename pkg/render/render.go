@@ -0,0 +1,117 @@
+package render
+
+import (
+	"image"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+// defaultFrameDelay - длительность кадра GIF без указанной задержки
+const defaultFrameDelay = 100 * time.Millisecond
+
+// Still - момент для статичного показа рисунка (preview, render):
+// бегущая строка стоит там же, где стоял бы неподвижный текст, GIF показывает первый кадр
+const Still time.Duration = -1
+
+// Flag рассчитывает цвета всех LED для флага (draw режим) в статичном показе (см. Still)
+// Индекс в результате = индекс LED. LED, не затронутые рисунком, остаются чёрными
+// Порядок отрисовки: image, затем stripes, grid и text поверх них
+func Flag(cfg *config.Config, flag *config.FlagMapping, ledCount int) []config.RGBColor {
+	return FlagAt(cfg, flag, ledCount, Still)
+}
+
+// FlagAt рассчитывает цвета LED в момент t от начала показа рисунка (или Still)
+// Время влияет только на анимации: кадры GIF и бегущую строку
+func FlagAt(cfg *config.Config, flag *config.FlagMapping, ledCount int, t time.Duration) []config.RGBColor {
+	leds := make([]config.RGBColor, ledCount)
+
+	set := func(ledIdx int, color config.RGBColor) {
+		if ledIdx >= 0 && ledIdx < ledCount {
+			leds[ledIdx] = color
+		}
+	}
+
+	if img := frameAt(flag.Frames, t); img != nil {
+		colors := SampleImage(img, Cells(cfg), flag.Sampling)
+		for ledIdx, color := range colors {
+			set(ledIdx, color)
+		}
+	}
+
+	for _, stripe := range flag.Stripes {
+		// Если указаны конкретные LED - используем их
+		if len(stripe.LEDs) > 0 {
+			for _, ledIdx := range stripe.LEDs {
+				set(ledIdx, stripe.Color)
+			}
+			continue
+		}
+		// Иначе используем ряды
+		for _, rowIdx := range stripe.Rows {
+			for _, ledIdx := range cfg.GetLEDsForRow(rowIdx) {
+				set(ledIdx, stripe.Color)
+			}
+		}
+	}
+
+	for rowIdx, line := range flag.Grid {
+		cells := []rune(line)
+		_, columns := cfg.GridColumns(rowIdx)
+		for j, ledIdx := range cfg.GetLEDsForRow(rowIdx) {
+			col := columns[j]
+			if col < 0 || col >= len(cells) || config.IsGridBlank(cells[col]) {
+				continue
+			}
+			if color, ok := flag.Palette[string(cells[col])]; ok {
+				set(ledIdx, color)
+			}
+		}
+	}
+
+	if flag.Text != nil {
+		for ledIdx, color := range TextColors(cfg, flag.Text, t) {
+			set(ledIdx, color)
+		}
+	}
+
+	return leds
+}
+
+// Animated сообщает, меняется ли рисунок со временем
+func Animated(flag *config.FlagMapping) bool {
+	return len(flag.Frames) > 1 || (flag.Text != nil && flag.Text.Speed > 0)
+}
+
+// frameAt возвращает кадр картинки в момент t (анимация зациклена)
+func frameAt(frames []config.ImageFrame, t time.Duration) image.Image {
+	if len(frames) == 0 {
+		return nil
+	}
+	if len(frames) == 1 || t == Still {
+		return frames[0].Image
+	}
+
+	var total time.Duration
+	for _, f := range frames {
+		total += frameDelay(f)
+	}
+
+	t %= total
+	for _, f := range frames {
+		d := frameDelay(f)
+		if t < d {
+			return f.Image
+		}
+		t -= d
+	}
+	return frames[len(frames)-1].Image
+}
+
+// frameDelay возвращает длительность кадра
+func frameDelay(f config.ImageFrame) time.Duration {
+	if f.Delay <= 0 {
+		return defaultFrameDelay
+	}
+	return f.Delay
+}
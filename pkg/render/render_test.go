@@ -0,0 +1,107 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+var (
+	red   = config.RGBColor{R: 255}
+	blue  = config.RGBColor{B: 255}
+	white = config.RGBColor{R: 255, G: 255, B: 255}
+	black = config.RGBColor{}
+)
+
+func TestFlag(t *testing.T) {
+	cfg := &config.Config{
+		Keyboard: config.KeyboardConfig{
+			Rows: [][]int{{0, 1, 2}, {3, 4}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		flag     config.FlagMapping
+		ledCount int
+		want     []config.RGBColor
+	}{
+		{
+			name: "stripes by rows",
+			flag: config.FlagMapping{Stripes: []config.FlagStripe{
+				{Rows: []int{0}, Color: white},
+				{Rows: []int{1}, Color: red},
+			}},
+			ledCount: 6,
+			want:     []config.RGBColor{white, white, white, red, red, black},
+		},
+		{
+			name: "stripes by leds",
+			flag: config.FlagMapping{Stripes: []config.FlagStripe{
+				{LEDs: []int{1, 4, 99}, Color: blue},
+			}},
+			ledCount: 5,
+			want:     []config.RGBColor{black, blue, black, black, blue},
+		},
+		{
+			name: "grid",
+			flag: config.FlagMapping{
+				Palette: map[string]config.RGBColor{"R": red, "B": blue},
+				Grid:    []string{"RB.", "BR"},
+			},
+			ledCount: 5,
+			want:     []config.RGBColor{red, blue, black, blue, red},
+		},
+		{
+			name: "grid over stripes",
+			flag: config.FlagMapping{
+				Stripes: []config.FlagStripe{{Rows: []int{0, 1}, Color: white}},
+				Palette: map[string]config.RGBColor{"R": red},
+				Grid:    []string{".R.", " R"},
+			},
+			ledCount: 5,
+			want:     []config.RGBColor{white, red, white, white, red},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Flag(cfg, &tt.flag, tt.ledCount)
+			if len(got) != len(tt.want) {
+				t.Fatalf("len = %d, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("led[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFlagGridGeometry(t *testing.T) {
+	// Ряд 0: клавиши в колонках 0 и 2, ряд 1: клавиша 2u (центр в колонке 1) и клавиша в колонке 2
+	cfg := &config.Config{
+		Keyboard: config.KeyboardConfig{
+			Rows: [][]int{{0, 1}, {2, 3}},
+			Geometry: []config.KeyGeometry{
+				{LED: 0, X: 0, Y: 0},
+				{LED: 1, X: 2, Y: 0},
+				{LED: 2, X: 0, Y: 1, W: 2},
+				{LED: 3, X: 2, Y: 1},
+			},
+		},
+	}
+	flag := config.FlagMapping{
+		Palette: map[string]config.RGBColor{"R": red, "B": blue},
+		Grid:    []string{"BRR", "RB."},
+	}
+
+	got := Flag(cfg, &flag, 4)
+	want := []config.RGBColor{blue, red, blue, black}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("led[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
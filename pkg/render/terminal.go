@@ -0,0 +1,98 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+const (
+	// terminalColsPerUnit - ширина клавиши 1u в символах терминала (включая промежуток)
+	terminalColsPerUnit = 4
+	// terminalLinesPerUnit - высота клавиши 1u в строках терминала
+	terminalLinesPerUnit = 1
+)
+
+// WriteANSI рисует цвета LED в терминале блоками truecolor ANSI
+// Клавиши расположены по keyboard.geometry, иначе по keyboard.rows,
+// а без них LED выводятся подряд строками по 16
+func WriteANSI(w io.Writer, cfg *config.Config, colors []config.RGBColor) error {
+	canvas := terminalCanvas(layoutKeys(cfg, len(colors)), len(colors))
+
+	bw := bufio.NewWriter(w)
+	for _, line := range canvas {
+		current := -1
+		for _, led := range line {
+			if led != current {
+				if led < 0 {
+					bw.WriteString("\x1b[0m")
+				} else {
+					c := colors[led]
+					fmt.Fprintf(bw, "\x1b[38;2;%d;%d;%dm", c.R, c.G, c.B)
+				}
+				current = led
+			}
+			if led < 0 {
+				bw.WriteByte(' ')
+			} else {
+				bw.WriteString("█")
+			}
+		}
+		if current >= 0 {
+			bw.WriteString("\x1b[0m")
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// terminalCanvas раскладывает клавиши по сетке символов
+// Значение ячейки - индекс LED или -1 для пустого места (и для LED за пределами ledCount)
+// Последняя колонка каждой клавиши остаётся пустой, чтобы клавиши не сливались
+func terminalCanvas(keys []config.KeyGeometry, ledCount int) [][]int {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	minX, minY, maxX, maxY := keyBounds(keys)
+
+	toCol := func(x float64) int { return int(math.Round((x - minX) * terminalColsPerUnit)) }
+	toLine := func(y float64) int { return int(math.Round((y - minY) * terminalLinesPerUnit)) }
+
+	canvas := make([][]int, toLine(maxY))
+	width := toCol(maxX)
+	for i := range canvas {
+		canvas[i] = make([]int, width)
+		for j := range canvas[i] {
+			canvas[i][j] = -1
+		}
+	}
+
+	for _, k := range keys {
+		if k.LED < 0 || k.LED >= ledCount {
+			continue
+		}
+		w, h := k.Size()
+		x0, x1 := toCol(k.X), toCol(k.X+w)-1
+		y0, y1 := toLine(k.Y), max(toLine(k.Y+h), toLine(k.Y)+1)
+		for y := y0; y < y1 && y < len(canvas); y++ {
+			for x := x0; x < x1 && x < width; x++ {
+				canvas[y][x] = k.LED
+			}
+		}
+	}
+
+	// Пробелы в конце строки не нужны
+	for i, line := range canvas {
+		end := len(line)
+		for end > 0 && line[end-1] < 0 {
+			end--
+		}
+		canvas[i] = line[:end]
+	}
+
+	return canvas
+}
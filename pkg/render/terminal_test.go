@@ -0,0 +1,72 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+func TestWriteANSI(t *testing.T) {
+	const (
+		redFG   = "\x1b[38;2;255;0;0m"
+		blueFG  = "\x1b[38;2;0;0;255m"
+		whiteFG = "\x1b[38;2;255;255;255m"
+		reset   = "\x1b[0m"
+	)
+
+	tests := []struct {
+		name   string
+		cfg    *config.Config
+		colors []config.RGBColor
+		want   string
+	}{
+		{
+			name: "rows",
+			cfg: &config.Config{Keyboard: config.KeyboardConfig{
+				Rows: [][]int{{0, 1}, {2}},
+			}},
+			colors: []config.RGBColor{red, blue, white},
+			want: redFG + "███" + reset + " " + blueFG + "███" + reset + "\n" +
+				whiteFG + "███" + reset + "\n",
+		},
+		{
+			name: "geometry",
+			cfg: &config.Config{Keyboard: config.KeyboardConfig{
+				Geometry: []config.KeyGeometry{
+					{LED: 0, X: 0, Y: 0, W: 2},
+					{LED: 1, X: 0.5, Y: 1},
+				},
+			}},
+			colors: []config.RGBColor{red, blue},
+			want: redFG + "███████" + reset + "\n" +
+				"  " + blueFG + "███" + reset + "\n",
+		},
+		{
+			name:   "no rows",
+			cfg:    &config.Config{},
+			colors: []config.RGBColor{red, red},
+			want:   redFG + "███" + reset + " " + redFG + "███" + reset + "\n",
+		},
+		{
+			name: "leds beyond colors are blank",
+			cfg: &config.Config{Keyboard: config.KeyboardConfig{
+				Rows: [][]int{{0, 5}},
+			}},
+			colors: []config.RGBColor{white},
+			want:   whiteFG + "███" + reset + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteANSI(&buf, tt.cfg, tt.colors); err != nil {
+				t.Fatalf("WriteANSI() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteANSI() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package render
+
+import (
+	"math"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+// TextColors рассчитывает цвета клавиш, закрашенных текстом в момент t (или Still)
+// Клавиатура рассматривается как экран из колонок и рядов (см. gridSize),
+// шрифт прижат к нижнему краю - на 6-рядных клавиатурах ряд F-клавиш остаётся фоном
+func TextColors(cfg *config.Config, text *config.TextDrawing, t time.Duration) map[int]config.RGBColor {
+	colors := make(map[int]config.RGBColor)
+	bm := RasterizeText(text.Value)
+	width, height := gridSize(cfg)
+	if width == 0 || height == 0 {
+		return colors
+	}
+
+	offsetY := max(height-FontHeight, 0)
+	startX := textStart(bm.Width, width, text.Speed, t)
+
+	for _, cell := range Cells(cfg) {
+		px := int((cell.X0 + cell.X1) / 2 * float64(width))
+		py := int((cell.Y0 + cell.Y1) / 2 * float64(height))
+		if bm.At(px-startX, py-offsetY) {
+			colors[cell.LED] = text.Color
+		}
+	}
+
+	return colors
+}
+
+// textStart возвращает колонку экрана, с которой начинается текст
+// Без прокрутки (и в статичном показе) короткий текст выравнивается по центру,
+// с прокруткой текст въезжает справа и полностью уходит влево
+func textStart(textWidth, screenWidth int, speed float64, t time.Duration) int {
+	if speed <= 0 || t == Still {
+		if textWidth <= screenWidth {
+			return (screenWidth - textWidth) / 2
+		}
+		return 0
+	}
+
+	period := textWidth + screenWidth
+	shift := int(t.Seconds()*speed) % period
+	return screenWidth - shift
+}
+
+// gridSize возвращает размер "экрана" клавиатуры в колонках и рядах
+// Для keyboard.rows - самый длинный ряд, для geometry - габариты в единицах клавиш
+func gridSize(cfg *config.Config) (width, height int) {
+	if keys := cfg.Keyboard.Geometry; len(keys) > 0 {
+		minX, minY, maxX, maxY := keyBounds(keys)
+		return int(math.Round(maxX - minX)), int(math.Round(maxY - minY))
+	}
+
+	for _, row := range cfg.Keyboard.Rows {
+		width = max(width, len(row))
+	}
+	return width, len(cfg.Keyboard.Rows)
+}
@@ -0,0 +1,140 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/config"
+)
+
+func TestRasterizeText(t *testing.T) {
+	tests := []struct {
+		text      string
+		wantWidth int
+	}{
+		{"RU", 3 + 1 + 3},
+		{"ru", 3 + 1 + 3},
+		{"EN", 3 + 1 + 4},
+		{"日本", 4 + 1 + 5},
+		{"한", 3}, // нет в шрифте - блок 3x5
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			bm := RasterizeText(tt.text)
+			if bm.Width != tt.wantWidth {
+				t.Errorf("Width = %d, want %d", bm.Width, tt.wantWidth)
+			}
+		})
+	}
+
+	// "I": верхний ряд закрашен целиком, средний - только центр
+	bm := RasterizeText("I")
+	if !bm.At(0, 0) || !bm.At(2, 0) || bm.At(0, 2) || !bm.At(1, 2) {
+		t.Error("unexpected pixels for glyph 'I'")
+	}
+	if bm.At(-1, 0) || bm.At(3, 0) || bm.At(0, FontHeight) {
+		t.Error("pixels outside bitmap must be empty")
+	}
+}
+
+// textConfig - клавиатура 5x5: ряды по 5 LED, индекс = ряд*5 + колонка
+func textConfig() *config.Config {
+	rows := make([][]int, 5)
+	for r := range rows {
+		for c := 0; c < 5; c++ {
+			rows[r] = append(rows[r], r*5+c)
+		}
+	}
+	return &config.Config{Keyboard: config.KeyboardConfig{Rows: rows}}
+}
+
+func TestTextColorsStatic(t *testing.T) {
+	cfg := textConfig()
+	text := &config.TextDrawing{Value: "I", Color: red}
+
+	colors := TextColors(cfg, text, 0)
+
+	// "I" шириной 3 по центру экрана шириной 5: колонки 1-3
+	want := map[int]bool{1: true, 2: true, 3: true, 7: true, 12: true, 17: true, 21: true, 22: true, 23: true}
+	if len(colors) != len(want) {
+		t.Errorf("lit %d LEDs, want %d: %v", len(colors), len(want), colors)
+	}
+	for led := range want {
+		if colors[led] != red {
+			t.Errorf("led %d = %v, want red", led, colors[led])
+		}
+	}
+}
+
+func TestTextColorsScroll(t *testing.T) {
+	cfg := textConfig()
+	text := &config.TextDrawing{Value: "I", Color: red, Speed: 1}
+
+	// t=0: текст ещё за правым краем
+	if colors := TextColors(cfg, text, 0); len(colors) != 0 {
+		t.Errorf("t=0: lit %d LEDs, want 0", len(colors))
+	}
+
+	// t=1s: сдвиг на одну колонку - видна левая колонка "I" в последней колонке экрана
+	colors := TextColors(cfg, text, time.Second)
+	if colors[4] != red || colors[24] != red || len(colors) != 2 {
+		t.Errorf("t=1s: colors = %v, want LEDs 4 and 24", colors)
+	}
+
+	// Период = ширина текста + ширина экрана = 8 секунд
+	if got := TextColors(cfg, text, 9*time.Second); len(got) != len(colors) {
+		t.Errorf("t=9s: lit %d LEDs, want %d (same as t=1s)", len(got), len(colors))
+	}
+}
+
+func TestTextColorsStill(t *testing.T) {
+	cfg := textConfig()
+	static := TextColors(cfg, &config.TextDrawing{Value: "I", Color: red}, 0)
+
+	// Бегущая строка в статичном показе стоит там же, где неподвижный текст
+	scrolling := TextColors(cfg, &config.TextDrawing{Value: "I", Color: red, Speed: 1}, Still)
+	if len(scrolling) != len(static) {
+		t.Fatalf("Still: lit %d LEDs, want %d", len(scrolling), len(static))
+	}
+	for led := range static {
+		if scrolling[led] != red {
+			t.Errorf("Still: led %d = %v, want red", led, scrolling[led])
+		}
+	}
+}
+
+func TestFlagAtFrames(t *testing.T) {
+	solid := func(c color.Color) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, c)
+		return img
+	}
+
+	cfg := &config.Config{Keyboard: config.KeyboardConfig{Rows: [][]int{{0}}}}
+	flag := &config.FlagMapping{Frames: []config.ImageFrame{
+		{Image: solid(color.RGBA{R: 255, A: 255}), Delay: 100 * time.Millisecond},
+		{Image: solid(color.RGBA{B: 255, A: 255}), Delay: 200 * time.Millisecond},
+	}}
+
+	if !Animated(flag) {
+		t.Error("Animated() = false, want true for multi-frame image")
+	}
+
+	tests := []struct {
+		t    time.Duration
+		want config.RGBColor
+	}{
+		{0, red},
+		{150 * time.Millisecond, blue},
+		{350 * time.Millisecond, red}, // анимация зациклена (период 300ms)
+	}
+	for _, tt := range tests {
+		if got := FlagAt(cfg, flag, 1, tt.t)[0]; got != tt.want {
+			t.Errorf("FlagAt(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
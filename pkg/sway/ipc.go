@@ -0,0 +1,126 @@
+package sway
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// Типы сообщений i3-ipc (https://i3wm.org/docs/ipc.html, sway-ipc(7))
+const (
+	msgSubscribe = 2
+	msgGetInputs = 100
+
+	// eventInput - событие input (sway), у событий установлен старший бит типа
+	eventInput = 0x80000015
+)
+
+// ipcMagic - заголовок каждого сообщения i3-ipc
+const ipcMagic = "i3-ipc"
+
+// maxPayload - ограничение размера сообщения, защита от мусора в сокете
+const maxPayload = 16 << 20
+
+// conn - соединение с IPC сокетом sway
+// Формат сообщения: "i3-ipc", длина и тип (uint32, порядок байт машины), JSON
+type conn struct {
+	c  net.Conn
+	mu sync.Mutex // запись запросов из разных горутин
+}
+
+// dial подключается к IPC сокету
+func dial(path string) (*conn, error) {
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to sway IPC: %w", err)
+	}
+	return &conn{c: c}, nil
+}
+
+// writeMessage отправляет сообщение
+func writeMessage(w io.Writer, msgType uint32, payload []byte) error {
+	buf := make([]byte, len(ipcMagic)+8+len(payload))
+	copy(buf, ipcMagic)
+	binary.NativeEndian.PutUint32(buf[len(ipcMagic):], uint32(len(payload)))
+	binary.NativeEndian.PutUint32(buf[len(ipcMagic)+4:], msgType)
+	copy(buf[len(ipcMagic)+8:], payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readMessage читает сообщение: ответ на запрос или событие
+func readMessage(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, len(ipcMagic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(ipcMagic)]) != ipcMagic {
+		return 0, nil, fmt.Errorf("invalid IPC magic %q", header[:len(ipcMagic)])
+	}
+
+	length := binary.NativeEndian.Uint32(header[len(ipcMagic):])
+	msgType := binary.NativeEndian.Uint32(header[len(ipcMagic)+4:])
+	if length > maxPayload {
+		return 0, nil, fmt.Errorf("IPC message too large: %d bytes", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return msgType, payload, nil
+}
+
+// request отправляет запрос и разбирает ответ в reply
+// Только для соединений без подписки: иначе вместо ответа может прийти событие
+func (c *conn) request(msgType uint32, payload []byte, reply any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := writeMessage(c.c, msgType, payload); err != nil {
+		return fmt.Errorf("failed to send IPC message: %w", err)
+	}
+	gotType, data, err := readMessage(c.c)
+	if err != nil {
+		return fmt.Errorf("failed to read IPC reply: %w", err)
+	}
+	if gotType != msgType {
+		return fmt.Errorf("unexpected IPC reply type %d, want %d", gotType, msgType)
+	}
+	if err := json.Unmarshal(data, reply); err != nil {
+		return fmt.Errorf("failed to parse IPC reply: %w", err)
+	}
+	return nil
+}
+
+// subscribe подписывает соединение на события
+// После подписки соединение только читает события через readEvent
+func (c *conn) subscribe(events ...string) error {
+	payload, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	var reply struct {
+		Success bool `json:"success"`
+	}
+	if err := c.request(msgSubscribe, payload, &reply); err != nil {
+		return err
+	}
+	if !reply.Success {
+		return fmt.Errorf("sway refused subscription to %v", events)
+	}
+	return nil
+}
+
+// readEvent читает следующее событие
+func (c *conn) readEvent() (uint32, []byte, error) {
+	return readMessage(c.c)
+}
+
+// Close закрывает соединение
+func (c *conn) Close() error {
+	return c.c.Close()
+}
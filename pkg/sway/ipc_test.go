@@ -0,0 +1,197 @@
+package sway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSway - IPC сервер Sway для тестов: отвечает на GET_INPUTS и SUBSCRIBE,
+// события рассылает подписанным соединениям
+type fakeSway struct {
+	t      *testing.T
+	socket string
+
+	mu          sync.Mutex
+	inputs      []input
+	subscribers []net.Conn
+	subscribed  chan struct{}
+}
+
+func newFakeSway(t *testing.T, inputs []input) *fakeSway {
+	t.Helper()
+	s := &fakeSway{
+		t:          t,
+		socket:     filepath.Join(t.TempDir(), "sway-ipc.sock"),
+		inputs:     inputs,
+		subscribed: make(chan struct{}, 10),
+	}
+
+	l, err := net.Listen("unix", s.socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		l.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, c := range s.subscribers {
+			c.Close()
+		}
+	})
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSway) serve(c net.Conn) {
+	for {
+		msgType, payload, err := readMessage(c)
+		if err != nil {
+			c.Close()
+			return
+		}
+
+		var reply any
+		switch msgType {
+		case msgGetInputs:
+			s.mu.Lock()
+			reply = s.inputs
+			s.mu.Unlock()
+		case msgSubscribe:
+			var events []string
+			json.Unmarshal(payload, &events)
+			ok := len(events) == 1 && events[0] == "input"
+			reply = map[string]bool{"success": ok}
+			if ok {
+				s.mu.Lock()
+				s.subscribers = append(s.subscribers, c)
+				s.mu.Unlock()
+				defer func() { s.subscribed <- struct{}{} }()
+			}
+		default:
+			reply = map[string]any{"success": false, "error": "unsupported"}
+		}
+
+		data, _ := json.Marshal(reply)
+		s.mu.Lock()
+		err = writeMessage(c, msgType, data)
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+		if msgType == msgSubscribe {
+			// Подписанное соединение только получает события
+			return
+		}
+	}
+}
+
+// emit рассылает событие input и обновляет состояние устройства
+func (s *fakeSway) emit(change string, in input) {
+	s.t.Helper()
+	data, err := json.Marshal(inputEvent{Change: change, Input: in})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.inputs {
+		if s.inputs[i].Identifier == in.Identifier {
+			s.inputs[i] = in
+		}
+	}
+	for _, c := range s.subscribers {
+		if err := writeMessage(c, eventInput, data); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+}
+
+func TestMessageFraming(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeMessage(&buf, msgSubscribe, []byte(`["input"]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := buf.Bytes()
+	if !bytes.HasPrefix(raw, []byte("i3-ipc")) || len(raw) != 6+8+9 {
+		t.Fatalf("message = %q", raw)
+	}
+	if n := binary.NativeEndian.Uint32(raw[6:]); n != 9 {
+		t.Errorf("length = %d, want 9", n)
+	}
+
+	msgType, payload, err := readMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgType != msgSubscribe || string(payload) != `["input"]` {
+		t.Errorf("readMessage() = %d, %q", msgType, payload)
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	header := func(magic string, length uint32) []byte {
+		b := append([]byte(magic), make([]byte, 8)...)
+		binary.NativeEndian.PutUint32(b[6:], length)
+		return b
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"bad magic", header("i4-ipc", 0), "invalid IPC magic"},
+		{"too large", header("i3-ipc", maxPayload+1), "too large"},
+		{"truncated payload", append(header("i3-ipc", 10), "abc"...), "EOF"},
+		{"truncated header", []byte("i3-i"), "EOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readMessage(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readMessage() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequest(t *testing.T) {
+	name := "English (US)"
+	s := newFakeSway(t, []input{{Identifier: "1:1:kbd", Type: "keyboard", ActiveLayoutName: &name}})
+
+	c, err := dial(s.socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var inputs []input
+	if err := c.request(msgGetInputs, nil, &inputs); err != nil {
+		t.Fatalf("request() error = %v", err)
+	}
+	if len(inputs) != 1 || inputs[0].Identifier != "1:1:kbd" {
+		t.Errorf("inputs = %+v", inputs)
+	}
+
+	if err := c.subscribe("window"); err == nil {
+		t.Error("subscribe(window) succeeded, want refusal")
+	}
+}
@@ -0,0 +1,242 @@
+package sway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+// input - устройство ввода из GET_INPUTS и событий input
+type input struct {
+	Identifier        string   `json:"identifier"`
+	Name              string   `json:"name"`
+	Type              string   `json:"type"`
+	ActiveLayoutName  *string  `json:"xkb_active_layout_name"`
+	ActiveLayoutIndex *int     `json:"xkb_active_layout_index"`
+	LayoutNames       []string `json:"xkb_layout_names"`
+}
+
+// inputEvent - событие input: added, removed, xkb_keymap, xkb_layout, libinput_config
+type inputEvent struct {
+	Change string `json:"change"`
+	Input  input  `json:"input"`
+}
+
+// isKeyboard сообщает, что у устройства есть раскладка
+func (i *input) isKeyboard() bool {
+	return i.Type == "keyboard" && i.ActiveLayoutName != nil
+}
+
+// Watcher реализует LayoutWatcher для Sway через IPC сокет ($SWAYSOCK)
+// В Sway у каждой клавиатуры своя раскладка, поэтому событие берётся с клавиатуры,
+// подходящей под фильтр (см. SetInput). Если такой клавиатуры нет - с любой
+type Watcher struct {
+	socket   string
+	query    *conn
+	registry *xkb.Registry
+	cancel   context.CancelFunc
+
+	mu        sync.Mutex
+	filter    string
+	keyboards map[string]bool // идентификаторы подключённых клавиатур
+}
+
+// NewWatcher подключается к Sway по $SWAYSOCK
+// identifier - фильтр клавиатуры, см. SetInput
+func NewWatcher(identifier string) (*Watcher, error) {
+	socket := os.Getenv("SWAYSOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("SWAYSOCK is not set")
+	}
+	return newWatcher(socket, identifier, xkb.Default())
+}
+
+func newWatcher(socket, identifier string, registry *xkb.Registry) (*Watcher, error) {
+	query, err := dial(socket)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		socket:    socket,
+		query:     query,
+		registry:  registry,
+		filter:    identifier,
+		keyboards: make(map[string]bool),
+	}, nil
+}
+
+// InputID возвращает фильтр для клавиатуры по VID/PID
+// Идентификатор устройства в Sway - "vendor:product:name" в десятичном виде
+func InputID(vendorID, productID uint16) string {
+	return fmt.Sprintf("%d:%d", vendorID, productID)
+}
+
+// SetInput задаёт фильтр клавиатуры: полный идентификатор ("13364:817:Keychron_Keychron_V3")
+// или его начало "vendor:product" (см. InputID). Пустой фильтр - любая клавиатура
+func (w *Watcher) SetInput(identifier string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.filter = identifier
+}
+
+// matches проверяет идентификатор по фильтру (вызывается под mu)
+func (w *Watcher) matches(id string) bool {
+	return w.filter == "" || id == w.filter || strings.HasPrefix(id, w.filter+":")
+}
+
+// track обновляет список клавиатур по событию (вызывается под mu)
+func (w *Watcher) track(change string, in *input) {
+	if !in.isKeyboard() {
+		return
+	}
+	if change == "removed" {
+		delete(w.keyboards, in.Identifier)
+		return
+	}
+	w.keyboards[in.Identifier] = true
+}
+
+// accept решает, брать ли раскладку с клавиатуры (вызывается под mu)
+// Если ни одна подключённая клавиатура не подходит под фильтр, подходит любая
+func (w *Watcher) accept(id string) bool {
+	if w.matches(id) {
+		return true
+	}
+	for kbd := range w.keyboards {
+		if w.matches(kbd) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCurrentLayout возвращает раскладку подходящей клавиатуры
+func (w *Watcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	in, err := w.keyboard()
+	if err != nil {
+		return layout.LayoutEvent{}, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.event(in), nil
+}
+
+// Layouts возвращает раскладки подходящей клавиатуры (xkb_layout_names)
+func (w *Watcher) Layouts() ([]layout.LayoutInfo, error) {
+	in, err := w.keyboard()
+	if err != nil {
+		return nil, err
+	}
+	layouts := make([]layout.LayoutInfo, len(in.LayoutNames))
+	for i, name := range in.LayoutNames {
+		layouts[i].Name = name
+		layouts[i].Code, layouts[i].Variant, _ = w.registry.Lookup(name)
+	}
+	return layouts, nil
+}
+
+// keyboard запрашивает устройства и возвращает подходящую клавиатуру
+func (w *Watcher) keyboard() (*input, error) {
+	var inputs []input
+	if err := w.query.request(msgGetInputs, nil, &inputs); err != nil {
+		return nil, fmt.Errorf("failed to get inputs: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.keyboards = make(map[string]bool)
+	for i := range inputs {
+		w.track("added", &inputs[i])
+	}
+	for i := range inputs {
+		if inputs[i].isKeyboard() && w.accept(inputs[i].Identifier) {
+			return &inputs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no keyboard inputs")
+}
+
+// event переводит состояние клавиатуры в событие
+// Sway сообщает только отображаемое имя, код и вариант ищутся в реестре XKB
+func (w *Watcher) event(in *input) layout.LayoutEvent {
+	event := layout.LayoutEvent{Index: -1, Name: *in.ActiveLayoutName}
+	if in.ActiveLayoutIndex != nil && *in.ActiveLayoutIndex >= 0 {
+		event.Index = *in.ActiveLayoutIndex
+	}
+	event.Layout, event.Variant, _ = w.registry.Lookup(event.Name)
+	return event
+}
+
+// Watch подписывается на события input и отслеживает смену раскладки
+func (w *Watcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	c, err := dial(w.socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.subscribe("input"); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to subscribe to input events: %w", err)
+	}
+
+	// Повтор текущей раскладки событием не считается
+	last, _ := w.GetCurrentLayout()
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	go func() {
+		// Закрытие соединения прерывает чтение
+		<-ctx.Done()
+		c.Close()
+	}()
+
+	events := make(chan layout.LayoutEvent, 10)
+	go func() {
+		defer close(events)
+		for {
+			msgType, data, err := c.readEvent()
+			if err != nil {
+				return
+			}
+			if msgType != eventInput {
+				continue
+			}
+
+			var e inputEvent
+			if err := json.Unmarshal(data, &e); err != nil || !e.Input.isKeyboard() {
+				continue
+			}
+
+			w.mu.Lock()
+			w.track(e.Change, &e.Input)
+			ok := e.Change != "removed" && w.accept(e.Input.Identifier)
+			event := w.event(&e.Input)
+			w.mu.Unlock()
+
+			if !ok || event == last {
+				continue
+			}
+			last = event
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Close закрывает соединения с Sway
+func (w *Watcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	return w.query.Close()
+}
@@ -0,0 +1,111 @@
+package sway
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb/xkbtest"
+)
+
+// keyboard описывает клавиатуру с активной раскладкой
+func keyboard(id, name string, index int) input {
+	return input{Identifier: id, Type: "keyboard", ActiveLayoutName: &name, ActiveLayoutIndex: &index}
+}
+
+// withLayouts задаёт список раскладок клавиатуры
+func withLayouts(in input, names ...string) input {
+	in.LayoutNames = names
+	return in
+}
+
+func TestWatcher(t *testing.T) {
+	const (
+		laptop   = "1:1:AT_Translated_Set_2_keyboard"
+		keychron = "13364:817:Keychron_Keychron_V3"
+	)
+	s := newFakeSway(t, []input{
+		{Identifier: "1267:12410:ELAN_Touchpad", Type: "touchpad"},
+		keyboard(laptop, "Russian", 1),
+		withLayouts(keyboard(keychron, "English (US)", 0), "English (US)", "Russian", "English (Dvorak)"),
+	})
+
+	registry := xkbtest.Registry(t)
+	w, err := newWatcher(s.socket, InputID(0x3434, 0x0331), registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	current, err := w.GetCurrentLayout()
+	if err != nil {
+		t.Fatalf("GetCurrentLayout() error = %v", err)
+	}
+	if want := (layout.LayoutEvent{Layout: "us", Name: "English (US)"}); current != want {
+		t.Errorf("GetCurrentLayout() = %+v, want %+v", current, want)
+	}
+	wantLayouts := []layout.LayoutInfo{
+		{Code: "us", Name: "English (US)"},
+		{Code: "ru", Name: "Russian"},
+		{Code: "us", Variant: "dvorak", Name: "English (Dvorak)"},
+	}
+	if got, err := w.Layouts(); err != nil || !slices.Equal(got, wantLayouts) {
+		t.Errorf("Layouts() = %+v, %v, want %+v", got, err, wantLayouts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	select {
+	case <-s.subscribed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not subscribe")
+	}
+
+	expect := func(want layout.LayoutEvent) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+
+	// Раскладка другой клавиатуры не интересна
+	s.emit("xkb_layout", keyboard(laptop, "English (US)", 0))
+	s.emit("xkb_layout", keyboard(keychron, "English (Dvorak)", 1))
+	expect(layout.LayoutEvent{Index: 1, Layout: "us", Variant: "dvorak", Name: "English (Dvorak)"})
+
+	// Неизвестное имя передаётся без кода: запись можно выбрать по name
+	s.emit("xkb_layout", keyboard(keychron, "Custom", 2))
+	expect(layout.LayoutEvent{Index: 2, Name: "Custom"})
+
+	// Без нужной клавиатуры раскладка берётся с любой
+	s.emit("removed", keyboard(keychron, "Custom", 2))
+	s.emit("xkb_layout", keyboard(laptop, "Russian", 1))
+	expect(layout.LayoutEvent{Index: 1, Layout: "ru", Name: "Russian"})
+
+	// Фильтр по полному идентификатору
+	w.SetInput(laptop)
+	s.emit("added", keyboard(keychron, "English (Dvorak)", 1))
+	s.emit("xkb_layout", keyboard(laptop, "English (US)", 0))
+	expect(layout.LayoutEvent{Layout: "us", Name: "English (US)"})
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("unexpected event after cancel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("events channel not closed after cancel")
+	}
+}
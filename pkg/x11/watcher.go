@@ -0,0 +1,172 @@
+package x11
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+// Watcher реализует LayoutWatcher для X11 через расширение XKB
+// Подходит для сессий без своего сервиса раскладок (Xfce, Cinnamon, MATE, оконные менеджеры):
+// индекс раскладки - группа XKB, коды - из _XKB_RULES_NAMES (то, что задал setxkbmap)
+type Watcher struct {
+	display  string
+	query    *client
+	registry *xkb.Registry
+	cancel   context.CancelFunc
+}
+
+// NewWatcher подключается к X серверу по $DISPLAY
+func NewWatcher() (*Watcher, error) {
+	display := os.Getenv("DISPLAY")
+	if display == "" {
+		return nil, fmt.Errorf("DISPLAY is not set")
+	}
+	return newWatcher(display, xkb.Default())
+}
+
+func newWatcher(display string, registry *xkb.Registry) (*Watcher, error) {
+	query, err := dial(display)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{display: display, query: query, registry: registry}, nil
+}
+
+// GetCurrentLayout возвращает текущую группу XKB
+func (w *Watcher) GetCurrentLayout() (layout.LayoutEvent, error) {
+	names, err := w.query.rulesNames()
+	if err != nil {
+		return layout.LayoutEvent{}, err
+	}
+	group, err := w.query.group()
+	if err != nil {
+		return layout.LayoutEvent{}, err
+	}
+	return w.event(names, group), nil
+}
+
+// Layouts возвращает раскладки из _XKB_RULES_NAMES по порядку групп
+func (w *Watcher) Layouts() ([]layout.LayoutInfo, error) {
+	names, err := w.query.rulesNames()
+	if err != nil {
+		return nil, err
+	}
+	layouts := make([]layout.LayoutInfo, len(names.Layouts))
+	for i := range names.Layouts {
+		event := w.event(names, i)
+		layouts[i] = layout.LayoutInfo{Code: event.Layout, Variant: event.Variant, Name: event.Name}
+	}
+	return layouts, nil
+}
+
+// event переводит группу XKB в событие
+// Если группы нет в _XKB_RULES_NAMES (раскладки заданы не через setxkbmap), известен только индекс
+func (w *Watcher) event(names rulesNames, group int) layout.LayoutEvent {
+	event := layout.LayoutEvent{Index: group}
+	event.Layout, event.Variant = names.layout(group)
+	if event.Layout == "" {
+		return event
+	}
+	event.Name = w.registry.Description(event.Layout, event.Variant)
+	if event.Name == "" {
+		event.Name = layoutID(event.Layout, event.Variant)
+	}
+	return event
+}
+
+// layoutID возвращает раскладку в записи конфига: "us" или "us(dvorak)"
+func layoutID(code, variant string) string {
+	if variant == "" {
+		return code
+	}
+	return code + "(" + variant + ")"
+}
+
+// Watch подписывается на события XKB и отслеживает смену группы
+// и изменение списка раскладок (setxkbmap)
+func (w *Watcher) Watch(ctx context.Context) (<-chan layout.LayoutEvent, error) {
+	c, err := dial(w.display)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.selectEvents(); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	// Состояние читается после подписки, чтобы не пропустить смену между ними
+	names, err := c.rulesNames()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	group, err := c.group()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	last := w.event(names, group)
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	go func() {
+		// Закрытие соединения прерывает ожидание события
+		<-ctx.Done()
+		c.Close()
+	}()
+
+	events := make(chan layout.LayoutEvent, 10)
+	go func() {
+		defer close(events)
+		for {
+			ev, xerr := c.conn.WaitForEvent()
+			if ev == nil && xerr == nil {
+				return
+			}
+
+			switch e := ev.(type) {
+			case stateNotifyEvent:
+				if e.Changed&xkbGroupStateMask == 0 {
+					continue
+				}
+				group = e.Group
+			case xproto.PropertyNotifyEvent:
+				if e.Atom != c.rules {
+					continue
+				}
+				// Запрос через второе соединение: ответ в этом ждал бы за очередью событий
+				if names, err = w.query.rulesNames(); err != nil {
+					return
+				}
+			default:
+				continue
+			}
+
+			event := w.event(names, group)
+			if event == last {
+				continue
+			}
+			last = event
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Close закрывает соединения с X сервером
+func (w *Watcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.query.Close()
+	return nil
+}
@@ -0,0 +1,163 @@
+package x11
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jidckii/kolor-keyboard/pkg/layout"
+	"github.com/jidckii/kolor-keyboard/pkg/xkb/xkbtest"
+)
+
+func TestWatcherEvent(t *testing.T) {
+	w := &Watcher{registry: xkbtest.Registry(t)}
+	names := rulesNames{Layouts: []string{"us", "ru", "xx"}, Variants: []string{"dvorak", "phonetic"}}
+
+	tests := []struct {
+		group int
+		want  layout.LayoutEvent
+	}{
+		{0, layout.LayoutEvent{Index: 0, Layout: "us", Variant: "dvorak", Name: "English (Dvorak)"}},
+		{1, layout.LayoutEvent{Index: 1, Layout: "ru", Variant: "phonetic", Name: "Russian (phonetic)"}},
+		{2, layout.LayoutEvent{Index: 2, Layout: "xx", Name: "xx"}}, // нет в реестре
+		{3, layout.LayoutEvent{Index: 3}},                           // нет в _XKB_RULES_NAMES
+	}
+
+	for _, tt := range tests {
+		if got := w.event(names, tt.group); got != tt.want {
+			t.Errorf("event(%d) = %+v, want %+v", tt.group, got, tt.want)
+		}
+	}
+}
+
+// startXvfb запускает Xvfb и возвращает имя дисплея
+// Без Xvfb и setxkbmap тест пропускается
+func startXvfb(t *testing.T) string {
+	t.Helper()
+	for _, tool := range []string{"Xvfb", "setxkbmap"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+
+	// Xvfb пишет номер свободного дисплея в -displayfd, когда готов принимать клиентов
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("Xvfb", "-displayfd", "3", "-nolisten", "tcp")
+	cmd.ExtraFiles = []*os.File{w}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	ready := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		ready <- strings.TrimSpace(line)
+	}()
+	select {
+	case n := <-ready:
+		if n == "" {
+			t.Fatal("Xvfb did not report a display")
+		}
+		return ":" + n
+	case <-time.After(10 * time.Second):
+		t.Fatal("Xvfb did not start")
+	}
+	return ""
+}
+
+// setxkbmap задаёт раскладки X сервера
+func setxkbmap(t *testing.T, display string, args ...string) {
+	t.Helper()
+	out, err := exec.Command("setxkbmap", append([]string{"-display", display}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("setxkbmap: %v: %s", err, out)
+	}
+}
+
+// lockGroup переключает раскладку (XkbLatchLockState), как это делает сочетание клавиш
+func lockGroup(t *testing.T, c *client, group int) {
+	t.Helper()
+	buf := make([]byte, 16)
+	buf[1] = 5 // XkbLatchLockState
+	xgb.Put16(buf[4:], xkbUseCoreKbd)
+	buf[8] = 1 // lockGroup
+	buf[9] = byte(group)
+	if _, err := c.request(buf, false); err != nil {
+		t.Fatalf("lock group %d: %v", group, err)
+	}
+}
+
+func TestWatcherXvfb(t *testing.T) {
+	display := startXvfb(t)
+	setxkbmap(t, display, "-layout", "us,ru", "-variant", ",phonetic")
+
+	w, err := newWatcher(display, xkbtest.Registry(t))
+	if err != nil {
+		t.Fatalf("newWatcher() error = %v", err)
+	}
+	defer w.Close()
+
+	current, err := w.GetCurrentLayout()
+	if err != nil {
+		t.Fatalf("GetCurrentLayout() error = %v", err)
+	}
+	if want := (layout.LayoutEvent{Index: 0, Layout: "us", Name: "English (US)"}); current != want {
+		t.Errorf("GetCurrentLayout() = %+v, want %+v", current, want)
+	}
+	layouts, err := w.Layouts()
+	if err != nil || len(layouts) != 2 || layouts[1] != (layout.LayoutInfo{Code: "ru", Variant: "phonetic", Name: "Russian (phonetic)"}) {
+		t.Errorf("Layouts() = %+v, %v, want us and ru(phonetic)", layouts, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	switcher, err := dial(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer switcher.Close()
+
+	// expect ждёт событие с нужным кодом, промежуточные события пропускаются
+	expect := func(want layout.LayoutEvent) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case got := <-events:
+				if got == want {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no event %+v", want)
+			}
+		}
+	}
+
+	lockGroup(t, switcher, 1)
+	expect(layout.LayoutEvent{Index: 1, Layout: "ru", Variant: "phonetic", Name: "Russian (phonetic)"})
+
+	lockGroup(t, switcher, 0)
+	expect(layout.LayoutEvent{Index: 0, Layout: "us", Name: "English (US)"})
+
+	// Новый список раскладок приходит через _XKB_RULES_NAMES
+	setxkbmap(t, display, "-layout", "de,us")
+	expect(layout.LayoutEvent{Index: 0, Layout: "de", Name: "German"})
+}
@@ -0,0 +1,283 @@
+package x11
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// Запросы и события расширения XKB (XKBproto, "The X Keyboard Extension: Protocol Specification")
+// В xgb нет пакета для XKB, поэтому нужные запросы собираются вручную
+const (
+	xkbExtension = "XKEYBOARD"
+
+	xkbUseExtension = 0
+	xkbSelectEvents = 1
+	xkbGetState     = 4
+
+	xkbMajorVersion = 1
+	xkbMinorVersion = 0
+
+	// xkbUseCoreKbd - основная клавиатура X сервера
+	xkbUseCoreKbd = 0x0100
+
+	// xkbStateNotify - тип события XKB (второй байт события)
+	xkbStateNotify     = 2
+	xkbStateNotifyMask = 1 << xkbStateNotify
+
+	// xkbGroupStateMask - в StateNotify изменилась группа (раскладка)
+	xkbGroupStateMask = 1 << 4
+)
+
+// rulesNamesProperty - свойство корневого окна с параметрами setxkbmap:
+// rules, model, layout, variant, options через \0
+const rulesNamesProperty = "_XKB_RULES_NAMES"
+
+// rulesNames - содержимое _XKB_RULES_NAMES
+type rulesNames struct {
+	Rules    string
+	Model    string
+	Layouts  []string // "us,ru" -> [us ru], i-я раскладка - группа i
+	Variants []string // ",phonetic" -> ["" phonetic]
+	Options  string
+}
+
+// parseRulesNames разбирает значение _XKB_RULES_NAMES
+func parseRulesNames(data []byte) rulesNames {
+	fields := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+	fields = append(fields, make([]string, 5)...)
+
+	names := rulesNames{Rules: fields[0], Model: fields[1], Options: fields[4]}
+	if fields[2] != "" {
+		names.Layouts = strings.Split(fields[2], ",")
+	}
+	if fields[3] != "" {
+		names.Variants = strings.Split(fields[3], ",")
+	}
+	return names
+}
+
+// layout возвращает код и вариант раскладки группы ("" - группы нет в списке)
+func (n rulesNames) layout(group int) (string, string) {
+	if group < 0 || group >= len(n.Layouts) {
+		return "", ""
+	}
+	variant := ""
+	if group < len(n.Variants) {
+		variant = n.Variants[group]
+	}
+	return strings.TrimSpace(n.Layouts[group]), strings.TrimSpace(variant)
+}
+
+// stateNotifyEvent - XkbStateNotify: изменилось состояние клавиатуры
+type stateNotifyEvent struct {
+	Group   int    // текущая группа (раскладка)
+	Changed uint16 // что изменилось (xkbGroupStateMask и др.)
+	buf     []byte
+}
+
+func (e stateNotifyEvent) Bytes() []byte { return e.buf }
+
+func (e stateNotifyEvent) String() string {
+	return fmt.Sprintf("XkbStateNotify {Group: %d, Changed: %#x}", e.Group, e.Changed)
+}
+
+// otherEvent - прочие события XKB, которые watcher не разбирает
+type otherEvent struct {
+	buf []byte
+}
+
+func (e otherEvent) Bytes() []byte { return e.buf }
+
+func (e otherEvent) String() string {
+	return fmt.Sprintf("XkbEvent {Type: %d}", e.buf[1])
+}
+
+// newEvent разбирает событие XKB: у расширения один номер события, тип - во втором байте
+func newEvent(buf []byte) xgb.Event {
+	if buf[1] != xkbStateNotify {
+		return otherEvent{buf: buf}
+	}
+	return stateNotifyEvent{
+		Group:   int(buf[13]),
+		Changed: xgb.Get16(buf[26:]),
+		buf:     buf,
+	}
+}
+
+// xkbError - ошибка XKB (BadKeyboard)
+// Без конструктора xgb не сопоставит ошибку с запросом и ответ не придёт
+type xkbError struct {
+	Sequence uint16
+	Value    uint32
+}
+
+func newError(buf []byte) xgb.Error {
+	return xkbError{Sequence: xgb.Get16(buf[2:]), Value: xgb.Get32(buf[4:])}
+}
+
+func (e xkbError) SequenceId() uint16 { return e.Sequence }
+func (e xkbError) BadId() uint32      { return e.Value }
+
+func (e xkbError) Error() string {
+	return fmt.Sprintf("BadKeyboard {Sequence: %d, Value: %#x}", e.Sequence, e.Value)
+}
+
+// client - соединение с X сервером с включённым XKB
+type client struct {
+	conn   *xgb.Conn
+	root   xproto.Window
+	opcode byte        // major opcode расширения XKB
+	rules  xproto.Atom // _XKB_RULES_NAMES
+}
+
+// dial подключается к X серверу и включает XKB
+// display - ":0" и т.п., "" - $DISPLAY
+func dial(display string) (*client, error) {
+	conn, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to X server: %w", err)
+	}
+	c := &client{conn: conn, root: xproto.Setup(conn).DefaultScreen(conn).Root}
+	if err := c.init(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// init регистрирует расширение XKB в соединении (как Init пакетов расширений xgb)
+func (c *client) init() error {
+	ext, err := xproto.QueryExtension(c.conn, uint16(len(xkbExtension)), xkbExtension).Reply()
+	if err != nil {
+		return fmt.Errorf("failed to query XKB extension: %w", err)
+	}
+	if !ext.Present {
+		return fmt.Errorf("X server has no XKB extension")
+	}
+	c.opcode = ext.MajorOpcode
+	if err := register(ext); err != nil {
+		return err
+	}
+
+	if err := c.useExtension(); err != nil {
+		return err
+	}
+
+	atom, err := xproto.InternAtom(c.conn, false, uint16(len(rulesNamesProperty)), rulesNamesProperty).Reply()
+	if err != nil {
+		return fmt.Errorf("failed to intern %s: %w", rulesNamesProperty, err)
+	}
+	c.rules = atom.Atom
+	return nil
+}
+
+var (
+	registerOnce sync.Once
+	firstEvent   byte // номер события XKB, под которым зарегистрирован newEvent
+	firstError   byte // номер ошибки XKB, под которым зарегистрирован newError
+)
+
+// register добавляет разбор событий и ошибок XKB в xgb
+// xgb.NewEventFuncs и xgb.NewErrorFuncs общие для всех соединений и читаются без блокировки
+// их горутинами, поэтому пишутся один раз (пакеты расширений xgb пишут их в init(),
+// но номера XKB известны только после QueryExtension)
+func register(ext *xproto.QueryExtensionReply) error {
+	registerOnce.Do(func() {
+		firstEvent, firstError = ext.FirstEvent, ext.FirstError
+		xgb.NewEventFuncs[int(firstEvent)] = newEvent
+		xgb.NewErrorFuncs[int(firstError)] = newError
+	})
+	if ext.FirstEvent != firstEvent || ext.FirstError != firstError {
+		return fmt.Errorf("XKB event numbers differ from the first X connection (%d, want %d)",
+			ext.FirstEvent, firstEvent)
+	}
+	return nil
+}
+
+// request отправляет запрос XKB и ждёт ответ (reply=false - только подтверждение)
+func (c *client) request(buf []byte, reply bool) ([]byte, error) {
+	buf[0] = c.opcode
+	xgb.Put16(buf[2:], uint16(len(buf)/4))
+	cookie := c.conn.NewCookie(true, reply)
+	c.conn.NewRequest(buf, cookie)
+	if !reply {
+		return nil, cookie.Check()
+	}
+	return cookie.Reply()
+}
+
+// useExtension - XkbUseExtension, без него сервер отклоняет остальные запросы XKB
+func (c *client) useExtension() error {
+	buf := make([]byte, 8)
+	buf[1] = xkbUseExtension
+	xgb.Put16(buf[4:], xkbMajorVersion)
+	xgb.Put16(buf[6:], xkbMinorVersion)
+
+	reply, err := c.request(buf, true)
+	if err != nil {
+		return fmt.Errorf("failed to enable XKB: %w", err)
+	}
+	if reply[1] == 0 {
+		return fmt.Errorf("X server does not support XKB %d.%d (server has %d.%d)",
+			xkbMajorVersion, xkbMinorVersion, xgb.Get16(reply[8:]), xgb.Get16(reply[10:]))
+	}
+	return nil
+}
+
+// group возвращает текущую группу (индекс раскладки) - XkbGetState
+func (c *client) group() (int, error) {
+	buf := make([]byte, 8)
+	buf[1] = xkbGetState
+	xgb.Put16(buf[4:], xkbUseCoreKbd)
+
+	reply, err := c.request(buf, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get XKB state: %w", err)
+	}
+	return stateGroup(reply), nil
+}
+
+// stateGroup возвращает группу из ответа XkbGetState
+func stateGroup(reply []byte) int {
+	return int(reply[12])
+}
+
+// rulesNames читает _XKB_RULES_NAMES с корневого окна
+func (c *client) rulesNames() (rulesNames, error) {
+	reply, err := xproto.GetProperty(c.conn, false, c.root, c.rules, xproto.AtomString, 0, 1024).Reply()
+	if err != nil {
+		return rulesNames{}, fmt.Errorf("failed to read %s: %w", rulesNamesProperty, err)
+	}
+	return parseRulesNames(reply.Value), nil
+}
+
+// selectEvents подписывается на смену группы (XkbStateNotify)
+// и на изменение свойств корневого окна (setxkbmap меняет _XKB_RULES_NAMES)
+func (c *client) selectEvents() error {
+	buf := make([]byte, 20)
+	buf[1] = xkbSelectEvents
+	xgb.Put16(buf[4:], xkbUseCoreKbd)
+	xgb.Put16(buf[6:], xkbStateNotifyMask) // affectWhich
+	// clear, selectAll, affectMap, map - 0: события выбираются подробно
+	xgb.Put16(buf[16:], xkbGroupStateMask) // affectState
+	xgb.Put16(buf[18:], xkbGroupStateMask) // stateDetails
+	if _, err := c.request(buf, false); err != nil {
+		return fmt.Errorf("failed to select XKB events: %w", err)
+	}
+
+	err := xproto.ChangeWindowAttributesChecked(c.conn, c.root, xproto.CwEventMask,
+		[]uint32{xproto.EventMaskPropertyChange}).Check()
+	if err != nil {
+		return fmt.Errorf("failed to select root window events: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает соединение
+func (c *client) Close() {
+	c.conn.Close()
+}
@@ -0,0 +1,132 @@
+package x11
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRulesNames(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want rulesNames
+	}{
+		{
+			name: "setxkbmap",
+			data: "evdev\x00pc105\x00us,ru\x00,phonetic\x00grp:alt_shift_toggle\x00",
+			want: rulesNames{Rules: "evdev", Model: "pc105", Layouts: []string{"us", "ru"},
+				Variants: []string{"", "phonetic"}, Options: "grp:alt_shift_toggle"},
+		},
+		{
+			name: "no variants and options",
+			data: "evdev\x00pc105\x00us\x00\x00\x00",
+			want: rulesNames{Rules: "evdev", Model: "pc105", Layouts: []string{"us"}},
+		},
+		{
+			name: "truncated",
+			data: "evdev\x00pc105",
+			want: rulesNames{Rules: "evdev", Model: "pc105"},
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRulesNames([]byte(tt.data))
+			if got.Rules != tt.want.Rules || got.Model != tt.want.Model || got.Options != tt.want.Options ||
+				!slices.Equal(got.Layouts, tt.want.Layouts) || !slices.Equal(got.Variants, tt.want.Variants) {
+				t.Errorf("parseRulesNames() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesNamesLayout(t *testing.T) {
+	names := rulesNames{Layouts: []string{"us", "ru", "de"}, Variants: []string{"dvorak", "phonetic"}}
+
+	tests := []struct {
+		group           int
+		layout, variant string
+	}{
+		{0, "us", "dvorak"},
+		{1, "ru", "phonetic"},
+		{2, "de", ""}, // вариантов меньше, чем раскладок
+		{3, "", ""},
+		{-1, "", ""},
+	}
+
+	for _, tt := range tests {
+		layout, variant := names.layout(tt.group)
+		if layout != tt.layout || variant != tt.variant {
+			t.Errorf("layout(%d) = %q, %q, want %q, %q", tt.group, layout, variant, tt.layout, tt.variant)
+		}
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	buf := make([]byte, 32)
+	buf[0] = 85 // первое событие расширения
+	buf[1] = xkbStateNotify
+	buf[13] = 2                 // group
+	buf[26] = xkbGroupStateMask // changed
+
+	e, ok := newEvent(buf).(stateNotifyEvent)
+	if !ok {
+		t.Fatalf("newEvent() = %T, want stateNotifyEvent", newEvent(buf))
+	}
+	if e.Group != 2 || e.Changed != xkbGroupStateMask {
+		t.Errorf("newEvent() = %v, want group 2 with GroupState changed", e)
+	}
+
+	buf[1] = 0 // XkbNewKeyboardNotify
+	if _, ok := newEvent(buf).(otherEvent); !ok {
+		t.Errorf("newEvent() = %T, want otherEvent", newEvent(buf))
+	}
+}
+
+// Ответы X сервера (по XKBproto, little-endian) для "setxkbmap us,ru" после переключения на ru
+var (
+	// XkbGetState: group 1, baseGroup 0, lockedGroup 1
+	recordedGetState = []byte{
+		0x01, 0x03, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	// XkbStateNotify (первое событие XKB - 85): group 1, changed GroupState|GroupLock,
+	// вызван запросом XkbLatchLockState
+	recordedStateNotify = []byte{
+		0x55, 0x02, 0x07, 0x00, 0x3c, 0x8f, 0x1a, 0x00,
+		0x03, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+		0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x90, 0x00, 0x00, 0x00, 0x85, 0x05,
+	}
+	// BadKeyboard на запрос с несуществующим устройством 0x0200
+	recordedBadKeyboard = []byte{
+		0x00, 0x89, 0x09, 0x00, 0x00, 0x02, 0x00, 0x00,
+		0x04, 0x00, 0x85, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+)
+
+func TestRecordedReplies(t *testing.T) {
+	if got := stateGroup(recordedGetState); got != 1 {
+		t.Errorf("stateGroup() = %d, want 1", got)
+	}
+
+	e, ok := newEvent(recordedStateNotify).(stateNotifyEvent)
+	if !ok {
+		t.Fatalf("newEvent() = %T, want stateNotifyEvent", newEvent(recordedStateNotify))
+	}
+	if e.Group != 1 || e.Changed&xkbGroupStateMask == 0 {
+		t.Errorf("newEvent() = %v, want group 1 with GroupState changed", e)
+	}
+
+	err, ok := newError(recordedBadKeyboard).(xkbError)
+	if !ok || err.SequenceId() != 9 || err.BadId() != 0x0200 {
+		t.Errorf("newError() = %v, want BadKeyboard for sequence 9, value 0x200", err)
+	}
+}
@@ -0,0 +1,138 @@
+package xkb
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Файлы описания раскладок xkeyboard-config
+const (
+	RegistryPath       = "/usr/share/X11/xkb/rules/evdev.xml"
+	ExtrasRegistryPath = "/usr/share/X11/xkb/rules/evdev.extras.xml"
+)
+
+// Registry - отображаемые имена раскладок и вариантов XKB
+type Registry struct {
+	descriptions map[string]string // "us" -> "English (US)", "us(dvorak)" -> "English (Dvorak)"
+	ids          map[string]string // обратное отображение, первое вхождение имени
+}
+
+// configItem - описание раскладки или варианта в evdev.xml
+type configItem struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+}
+
+type registryXML struct {
+	Layouts []struct {
+		ConfigItem configItem `xml:"configItem"`
+		Variants   []struct {
+			ConfigItem configItem `xml:"configItem"`
+		} `xml:"variantList>variant"`
+	} `xml:"layoutList>layout"`
+}
+
+// ParseRegistry читает описание раскладок в формате evdev.xml
+func ParseRegistry(r io.Reader) (*Registry, error) {
+	var doc registryXML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse xkb registry: %w", err)
+	}
+
+	reg := newRegistry()
+	reg.add(&doc)
+	return reg, nil
+}
+
+// LoadRegistry читает файлы описания раскладок, более поздние дополняют ранние
+func LoadRegistry(paths ...string) (*Registry, error) {
+	reg := newRegistry()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xkb registry: %w", err)
+		}
+		part, err := ParseRegistry(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for id, desc := range part.descriptions {
+			reg.descriptions[id] = desc
+		}
+		for desc, id := range part.ids {
+			if _, ok := reg.ids[desc]; !ok {
+				reg.ids[desc] = id
+			}
+		}
+	}
+	return reg, nil
+}
+
+// Default возвращает системный реестр раскладок
+// Без xkeyboard-config реестр пустой: имена раскладок просто не будут известны
+var Default = sync.OnceValue(func() *Registry {
+	paths := []string{RegistryPath}
+	if _, err := os.Stat(ExtrasRegistryPath); err == nil {
+		paths = append(paths, ExtrasRegistryPath)
+	}
+	reg, err := LoadRegistry(paths...)
+	if err != nil {
+		return newRegistry()
+	}
+	return reg
+})
+
+func newRegistry() *Registry {
+	return &Registry{descriptions: make(map[string]string), ids: make(map[string]string)}
+}
+
+// add добавляет раскладки из разобранного файла
+func (r *Registry) add(doc *registryXML) {
+	for _, l := range doc.Layouts {
+		code := strings.TrimSpace(l.ConfigItem.Name)
+		r.set(code, l.ConfigItem.Description)
+		for _, v := range l.Variants {
+			r.set(code+"("+strings.TrimSpace(v.ConfigItem.Name)+")", v.ConfigItem.Description)
+		}
+	}
+}
+
+// set запоминает имя раскладки в обе стороны
+// Одно имя может быть у нескольких раскладок, обратно отображается первая
+func (r *Registry) set(id, description string) {
+	description = strings.TrimSpace(description)
+	r.descriptions[id] = description
+	if _, ok := r.ids[description]; !ok && description != "" {
+		r.ids[description] = id
+	}
+}
+
+// Description возвращает отображаемое имя раскладки ("" - раскладка неизвестна)
+func (r *Registry) Description(layout, variant string) string {
+	if variant != "" {
+		layout += "(" + variant + ")"
+	}
+	return r.descriptions[layout]
+}
+
+// Lookup ищет раскладку по отображаемому имени: "English (Dvorak)" -> us, dvorak
+// Так имена из Sway и Hyprland переводятся в коды XKB
+func (r *Registry) Lookup(description string) (layout, variant string, ok bool) {
+	id, ok := r.ids[strings.TrimSpace(description)]
+	if !ok {
+		return "", "", false
+	}
+	layout, variant, _ = strings.Cut(id, "(")
+	return layout, strings.TrimSuffix(variant, ")"), true
+}
+
+// SplitID разбирает идентификатор раскладки GNOME/Wayland "us+dvorak" на код и вариант
+func SplitID(id string) (layout, variant string) {
+	layout, variant, _ = strings.Cut(id, "+")
+	return layout, variant
+}
@@ -0,0 +1,114 @@
+package xkb
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const testRegistry = `<?xml version="1.0" encoding="UTF-8"?>
+<xkbConfigRegistry version="1.1">
+  <layoutList>
+    <layout>
+      <configItem>
+        <name>us</name>
+        <shortDescription>en</shortDescription>
+        <description>English (US)</description>
+      </configItem>
+      <variantList>
+        <variant>
+          <configItem>
+            <name>dvorak</name>
+            <description>English (Dvorak)</description>
+          </configItem>
+        </variant>
+      </variantList>
+    </layout>
+    <layout>
+      <configItem>
+        <name>ru</name>
+        <description>Russian</description>
+      </configItem>
+    </layout>
+  </layoutList>
+</xkbConfigRegistry>
+`
+
+func TestRegistryDescription(t *testing.T) {
+	reg, err := ParseRegistry(strings.NewReader(testRegistry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		layout, variant string
+		want            string
+	}{
+		{"us", "", "English (US)"},
+		{"us", "dvorak", "English (Dvorak)"},
+		{"ru", "", "Russian"},
+		{"ru", "phonetic", ""},
+		{"xx", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout+"("+tt.variant+")", func(t *testing.T) {
+			if got := reg.Description(tt.layout, tt.variant); got != tt.want {
+				t.Errorf("Description() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	reg, err := ParseRegistry(strings.NewReader(testRegistry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		layout, variant string
+		ok              bool
+	}{
+		{"English (US)", "us", "", true},
+		{"English (Dvorak)", "us", "dvorak", true},
+		{" Russian ", "ru", "", true},
+		{"Klingon", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, variant, ok := reg.Lookup(tt.name)
+			if layout != tt.layout || variant != tt.variant || ok != tt.ok {
+				t.Errorf("Lookup() = %q, %q, %v, want %q, %q, %v", layout, variant, ok, tt.layout, tt.variant, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSplitID(t *testing.T) {
+	tests := []struct {
+		id, layout, variant string
+	}{
+		{"us", "us", ""},
+		{"us+dvorak", "us", "dvorak"},
+		{"de+nodeadkeys", "de", "nodeadkeys"},
+	}
+
+	for _, tt := range tests {
+		layout, variant := SplitID(tt.id)
+		if layout != tt.layout || variant != tt.variant {
+			t.Errorf("SplitID(%q) = %q, %q, want %q, %q", tt.id, layout, variant, tt.layout, tt.variant)
+		}
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	if _, err := os.Stat(RegistryPath); err != nil {
+		t.Skip("xkeyboard-config is not installed")
+	}
+	if got := Default().Description("us", ""); got != "English (US)" {
+		t.Errorf("Description(us) = %q, want English (US)", got)
+	}
+}
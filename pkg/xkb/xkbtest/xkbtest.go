@@ -0,0 +1,33 @@
+// Package xkbtest - реестр раскладок XKB для тестов watcher'ов
+package xkbtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jidckii/kolor-keyboard/pkg/xkb"
+)
+
+// RegistryXML - небольшой evdev.xml: us (dvorak, intl), ru (phonetic) и de
+const RegistryXML = `<xkbConfigRegistry><layoutList>
+<layout><configItem><name>us</name><description>English (US)</description></configItem>
+<variantList>
+<variant><configItem><name>dvorak</name><description>English (Dvorak)</description></configItem></variant>
+<variant><configItem><name>intl</name><description>English (US, intl., with dead keys)</description></configItem></variant>
+</variantList>
+</layout>
+<layout><configItem><name>ru</name><description>Russian</description></configItem>
+<variantList><variant><configItem><name>phonetic</name><description>Russian (phonetic)</description></configItem></variant></variantList>
+</layout>
+<layout><configItem><name>de</name><description>German</description></configItem></layout>
+</layoutList></xkbConfigRegistry>`
+
+// Registry возвращает реестр из RegistryXML
+func Registry(t testing.TB) *xkb.Registry {
+	t.Helper()
+	registry, err := xkb.ParseRegistry(strings.NewReader(RegistryXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}